@@ -0,0 +1,341 @@
+package mockdns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// answerSet is the complete answer to a question, built once and reused
+// until zones change (see zoneCache).
+//
+// Slices are shared between replies, code modifying a reply must copy them
+// first.
+type answerSet struct {
+	answer []dns.RR
+	ns     []dns.RR
+	extra  []dns.RR
+	ad     bool
+
+	// Options the answer was built with.
+	skipCNAME     bool
+	negTTL        uint32
+	additionalSRV bool
+	additionalNS  bool
+	authorityNS   bool
+	filterHosts   bool
+	anyPolicy     ANYPolicy
+	flatten       bool
+
+	// ent is set for empty non-terminal answers, which depend on all zones
+	// and so are never reused.
+	ent bool
+}
+
+// sectionWriter replaces records of a reply section copy-on-write. Sections
+// and records of replies may be shared with cached answers, zones (e.g.
+// Zone.Misc) and other replies, so they are never modified in place: the
+// section is copied before the first replacement and modified records must
+// be replaced with copies (see dns.Copy).
+type sectionWriter struct {
+	section *[]dns.RR
+	copied  bool
+}
+
+// set replaces the i-th record of the section.
+func (w *sectionWriter) set(i int, rr dns.RR) {
+	if !w.copied {
+		*w.section = append([]dns.RR(nil), *w.section...)
+		w.copied = true
+	}
+	(*w.section)[i] = rr
+}
+
+type answerKey struct {
+	view   string
+	region string
+	name   string
+	qtype  uint16
+}
+
+// answer returns the answer for the question, using the cached one if it was
+// built with the same options. Cached answers are discarded with zoneCache
+// when zones change.
+func (s *Server) answer(view string, r *Resolver, qname string, qtype uint16) (*answerSet, error) {
+	cache := s.zoneCache()
+	key := answerKey{view: view, region: r.Region, name: qname, qtype: qtype}
+	v, cached := cache.answers.Load(key)
+	if cached {
+		set := v.(*answerSet)
+		if s.validAnswer(r, set) {
+			return set, nil
+		}
+	}
+
+	set, err := s.buildAnswer(r, qname, qtype)
+	if err != nil {
+		cache.answers.Delete(key)
+		return nil, err
+	}
+	if set.ent {
+		return set, nil
+	}
+	if !cached && atomic.AddInt32(&cache.size, 1) > maxCachedAnswers {
+		s.cache.Store(&zoneCache{gen: cache.gen})
+		return set, nil
+	}
+	cache.answers.Store(key, set)
+	return set, nil
+}
+
+func (s *Server) validAnswer(r *Resolver, set *answerSet) bool {
+	if set.skipCNAME != r.SkipCNAME || set.negTTL != s.NegativeTTL ||
+		set.additionalSRV != s.AdditionalSRV || set.additionalNS != s.AdditionalNS ||
+		set.authorityNS != s.AuthorityNS || set.filterHosts != s.FilterInvalidHosts ||
+		set.anyPolicy != s.ANY || set.flatten != s.FlattenCNAME {
+		return false
+	}
+	return true
+}
+
+func (s *Server) buildAnswer(r *Resolver, qname string, qtype uint16) (*answerSet, error) {
+	set := &answerSet{
+		skipCNAME: r.SkipCNAME,
+		negTTL:    s.NegativeTTL,
+
+		additionalSRV: s.AdditionalSRV,
+		additionalNS:  s.AdditionalNS,
+		authorityNS:   s.AuthorityNS,
+		filterHosts:   s.FilterInvalidHosts,
+		anyPolicy:     s.ANY,
+		flatten:       s.FlattenCNAME,
+	}
+
+	qnameZone, ok := r.zone(qname)
+	if !ok && s.EmptyNonTerminals && hasDescendants(r, qname) {
+		set.ent = true
+		set.ns = []dns.RR{s.negativeSOA(r, qname)}
+		return set, nil
+	}
+	if !ok || qnameZone.NXDOMAIN {
+		return nil, notFound(qname)
+	}
+
+	ad, rname, rzone, err := r.targetZone(context.Background(), qname)
+	if err != nil {
+		return nil, err
+	}
+	set.ad = ad
+
+	if rname != qname {
+		set.answer = append(set.answer, mkCname(qname, rname, qnameZone.ttl()))
+	}
+
+	if qtype == dns.TypeA && rzone.AErr != nil {
+		return nil, rzone.AErr
+	}
+	if qtype == dns.TypeAAAA && rzone.AAAAErr != nil {
+		return nil, rzone.AAAAErr
+	}
+
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		rrs := s.compiledRRs(rname, rzone)
+		if rrs.err != nil {
+			return nil, rrs.err
+		}
+		set.answer = append(set.answer, rrs.byType[qtype]...)
+	case dns.TypeMX, dns.TypePTR:
+		set.answer = append(set.answer, s.compiledRRs(rname, rzone).byType[qtype]...)
+	case dns.TypeSRV:
+		set.answer = append(set.answer, s.compiledRRs(rname, rzone).byType[dns.TypeSRV]...)
+		if s.AdditionalSRV {
+			targets := make([]string, 0, len(rzone.SRV))
+			for _, srv := range rzone.SRV {
+				targets = append(targets, srv.Target)
+			}
+			s.addAdditional(r, set, targets)
+		}
+	case dns.TypeNS:
+		// CNAME is not followed but is still returned to the client.
+		if r.SkipCNAME && qnameZone.CNAME != "" {
+			set.answer = append(set.answer, mkCname(qname, qnameZone.CNAME, qnameZone.ttl()))
+		}
+		set.answer = append(set.answer, s.compiledRRs(rname, rzone).byType[dns.TypeNS]...)
+		if s.AdditionalNS {
+			hosts := make([]string, 0, len(rzone.NS))
+			for _, ns := range rzone.NS {
+				hosts = append(hosts, ns.Host)
+			}
+			s.addAdditional(r, set, hosts)
+		}
+	case dns.TypeCNAME:
+		set.ad = qnameZone.AD
+	case dns.TypeTXT:
+		set.answer = append(set.answer, s.compiledRRs(rname, rzone).byType[dns.TypeTXT]...)
+		// Misc records are sent as is, keeping the character-strings
+		// structure.
+		set.answer = append(set.answer, rzone.Misc[dns.Type(dns.TypeTXT)]...)
+	case dns.TypeSOA:
+		set.answer = []dns.RR{mkSOA(qname)}
+	case dns.TypeANY:
+		set.answer = append(set.answer, s.anyRRs(rname, qnameZone, rzone)...)
+	default:
+		set.answer = append(set.answer, qnameZone.Misc[dns.Type(qtype)]...)
+	}
+	if s.FilterInvalidHosts {
+		set.answer = filterHosts(set.answer)
+	}
+	if rname != qname && qtype != dns.TypeCNAME && (s.FlattenCNAME || qnameZone.FlattenCNAME) {
+		set.answer = flattenCNAME(qname, set.answer)
+	}
+
+	if !hasType(set.answer, qtype) && (qtype != dns.TypeANY || len(set.answer) == 0) {
+		// NODATA response
+		set.ns = []dns.RR{s.negativeSOA(r, rname)}
+	} else if s.AuthorityNS && qtype != dns.TypeNS {
+		s.addAuthorityNS(r, set, rname)
+	}
+
+	// Make sure appending to the reply sections does not modify the shared
+	// array.
+	set.answer = set.answer[:len(set.answer):len(set.answer)]
+
+	return set, nil
+}
+
+// flattenCNAME returns rrs without CNAME records and with other records
+// renamed to qname, see Zone.FlattenCNAME.
+func flattenCNAME(qname string, rrs []dns.RR) []dns.RR {
+	flat := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeCNAME {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = qname
+		flat = append(flat, rr)
+	}
+	return flat
+}
+
+// addAdditional adds A and AAAA records of names present in zones to the
+// additional section of the answer.
+func (s *Server) addAdditional(r *Resolver, set *answerSet, names []string) {
+	for _, name := range names {
+		name = normalizeName(name)
+		zone, ok := r.zone(name)
+		if !ok || zone.NXDOMAIN || zone.Err != nil {
+			continue
+		}
+
+		rrs := s.compiledRRs(name, zone)
+		set.extra = append(set.extra, rrs.byType[dns.TypeA]...)
+		set.extra = append(set.extra, rrs.byType[dns.TypeAAAA]...)
+	}
+	set.extra = set.extra[:len(set.extra):len(set.extra)]
+}
+
+// addAuthorityNS adds NS records of the closest zone enclosing name that has
+// them to the authority section of the answer.
+func (s *Server) addAuthorityNS(r *Resolver, set *answerSet, name string) {
+	for {
+		zone, ok := r.zone(name)
+		if ok && len(zone.NS) != 0 {
+			set.ns = append(set.ns, s.compiledRRs(name, zone).byType[dns.TypeNS]...)
+			set.ns = set.ns[:len(set.ns):len(set.ns)]
+			if s.AdditionalNS {
+				hosts := make([]string, 0, len(zone.NS))
+				for _, ns := range zone.NS {
+					hosts = append(hosts, ns.Host)
+				}
+				s.addAdditional(r, set, hosts)
+			}
+			return
+		}
+
+		if name == "." {
+			return
+		}
+		labels := dns.SplitDomainName(name)
+		name = dns.Fqdn(strings.Join(labels[1:], "."))
+	}
+}
+
+// hasDescendants reports whether there are zones for names below name.
+func hasDescendants(r *Resolver, name string) bool {
+	suffix := "." + name
+	if name == "." {
+		suffix = name
+	}
+	found := false
+	r.rangeZoneNames(func(zoneName string) {
+		if !found && strings.HasSuffix(normalizeName(zoneName), suffix) {
+			found = true
+		}
+	})
+	return found
+}
+
+// sameZoneFull is similar to sameZone but also compares all other Zone
+// fields affecting answers.
+func sameZoneFull(a, b Zone) bool {
+	return sameZone(a, b) &&
+		a.CNAME == b.CNAME &&
+		a.AD == b.AD &&
+		a.NXDOMAIN == b.NXDOMAIN &&
+		a.Sinkhole == b.Sinkhole &&
+		a.FlattenCNAME == b.FlattenCNAME &&
+		a.NegativeTTL == b.NegativeTTL &&
+		sameErr(a.Err, b.Err) &&
+		sameErr(a.AErr, b.AErr) &&
+		sameErr(a.AAAAErr, b.AAAAErr) &&
+		sameMisc(a.Misc, b.Misc) &&
+		sameRegions(a.Regions, b.Regions)
+}
+
+func sameMisc(a, b map[dns.Type][]dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for t, rrs := range a {
+		other, ok := b[t]
+		if !ok || len(other) != len(rrs) {
+			return false
+		}
+		for i, rr := range rrs {
+			if rr.Header().Ttl != other[i].Header().Ttl || !dns.IsDuplicate(rr, other[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sameRegions(a, b map[string]Zone) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, zone := range a {
+		other, ok := b[name]
+		if !ok || !sameZoneFull(zone, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameErr compares errors without panicking on non-comparable types.
+func sameErr(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,322 @@
+package mockdns
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_AnswerCache(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			CNAME: "target.example.org.",
+		},
+		"target.example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+
+	first, err := s.answer("", &s.r, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.answer) != 2 {
+		t.Fatalf("Wrong answer: %v", first.answer)
+	}
+	if again, _ := s.answer("", &s.r, "example.org.", dns.TypeA); again != first {
+		t.Fatal("Answer is rebuilt for unchanged zones")
+	}
+
+	// Change at the end of the CNAME chain should be noticed.
+	s.AddZone("target.example.org.", Zone{A: []string{"192.0.2.2"}})
+	changed, err := s.answer("", &s.r, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Fatal("Answer is not rebuilt after CNAME target change")
+	}
+	if a := changed.answer[1].(*dns.A); a.A.String() != "192.0.2.2" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.2", a.A)
+	}
+
+	s.r.SkipCNAME = true
+	skipped, err := s.answer("", &s.r, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped.answer) != 0 || len(skipped.ns) != 1 {
+		t.Fatalf("SkipCNAME change is not noticed: %v", skipped.answer)
+	}
+
+	s.RemoveZone("example.org.")
+	if _, err := s.answer("", &s.r, "example.org.", dns.TypeA); err == nil {
+		t.Fatal("Answer for removed zone is returned")
+	}
+}
+
+func TestServer_AnswerCache_InPlace(t *testing.T) {
+	txt, err := dns.NewRR("example.org. 300 IN TXT \"a\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := map[string]Zone{
+		"example.org.": {
+			Misc: map[dns.Type][]dns.RR{dns.Type(dns.TypeTXT): {txt}},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+
+	first, err := s.answer("", &s.r, "example.org.", dns.TypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changes made in place are seen after Resolver is called.
+	s.Resolver()
+	txt.(*dns.TXT).Txt[0] = "b"
+	changed, err := s.answer("", &s.r, "example.org.", dns.TypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Fatal("Answer is not rebuilt after in-place Misc change")
+	}
+}
+
+func TestServer_AnswerCache_Bounded(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+
+	for i := 0; i < maxCachedAnswers+10; i++ {
+		if _, err := s.answer(strconv.Itoa(i), &s.r, "example.org.", dns.TypeA); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size := atomic.LoadInt32(&s.zoneCache().size); size > maxCachedAnswers {
+		t.Fatalf("Wrong result, want at most %v, got %v", maxCachedAnswers, size)
+	}
+}
+
+func TestServer_AdditionalNS(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			NS: []net.NS{{Host: "ns1.example.org."}, {Host: "ns.example.net."}},
+		},
+		"ns1.example.org.": {
+			A:    []string{"192.0.2.53"},
+			AAAA: []string{"2001:db8::53"},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+
+	set, err := s.answer("", &s.r, "example.org.", dns.TypeNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.extra) != 0 {
+		t.Fatalf("Glue is added without AdditionalNS: %v", set.extra)
+	}
+
+	s.AdditionalNS = true
+	set, err = s.answer("", &s.r, "example.org.", dns.TypeNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.answer) != 2 || len(set.extra) != 2 {
+		t.Fatalf("Wrong answer: %v, extra: %v", set.answer, set.extra)
+	}
+
+	// Glue change should be noticed.
+	s.AddZone("ns1.example.org.", Zone{A: []string{"192.0.2.54"}})
+	set, err = s.answer("", &s.r, "example.org.", dns.TypeNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.extra) != 1 || set.extra[0].(*dns.A).A.String() != "192.0.2.54" {
+		t.Fatalf("Wrong extra: %v", set.extra)
+	}
+}
+
+func TestServer_AuthorityNS(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			NS: []net.NS{{Host: "ns1.example.org."}},
+		},
+		"www.example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+		"ns1.example.org.": {
+			A: []string{"192.0.2.53"},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}, AuthorityNS: true}
+
+	set, err := s.answer("", &s.r, "www.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.ns) != 1 || set.ns[0].(*dns.NS).Ns != "ns1.example.org." {
+		t.Fatalf("Wrong authority: %v", set.ns)
+	}
+	if len(set.extra) != 0 {
+		t.Fatalf("Glue is added without AdditionalNS: %v", set.extra)
+	}
+
+	s.AdditionalNS = true
+	set, err = s.answer("", &s.r, "www.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.extra) != 1 {
+		t.Fatalf("Wrong extra: %v", set.extra)
+	}
+
+	// Closer delegation should be noticed.
+	s.AddZone("www.example.org.", Zone{
+		A:  []string{"192.0.2.1"},
+		NS: []net.NS{{Host: "ns.example.net."}},
+	})
+	set, err = s.answer("", &s.r, "www.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.ns) != 1 || set.ns[0].(*dns.NS).Ns != "ns.example.net." {
+		t.Fatalf("Wrong authority: %v", set.ns)
+	}
+
+	// NODATA has SOA only.
+	set, err = s.answer("", &s.r, "www.example.org.", dns.TypeMX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.ns) != 1 || set.ns[0].Header().Rrtype != dns.TypeSOA {
+		t.Fatalf("Wrong authority: %v", set.ns)
+	}
+}
+
+func TestServer_EmptyNonTerminals(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			NS: []net.NS{{Host: "ns1.example.org."}},
+		},
+		"www.a.b.example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+
+	if _, err := s.answer("", &s.r, "b.example.org.", dns.TypeNS); err == nil {
+		t.Fatal("Empty non-terminal is answered without EmptyNonTerminals")
+	}
+
+	s.EmptyNonTerminals = true
+	for _, name := range []string{"org.", "b.example.org.", "a.b.example.org."} {
+		set, err := s.answer("", &s.r, name, dns.TypeNS)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(set.answer) != 0 || len(set.ns) != 1 || set.ns[0].Header().Rrtype != dns.TypeSOA {
+			t.Fatalf("%s: not a NODATA answer: %v %v", name, set.answer, set.ns)
+		}
+	}
+
+	set, err := s.answer("", &s.r, "example.org.", dns.TypeNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.answer) != 1 {
+		t.Fatalf("Wrong answer: %v", set.answer)
+	}
+
+	if _, err := s.answer("", &s.r, "c.example.org.", dns.TypeNS); err == nil {
+		t.Fatal("Name without descendants is answered")
+	}
+
+	delete(zones, "www.a.b.example.org.")
+	if _, err := s.answer("", &s.r, "b.example.org.", dns.TypeNS); err == nil {
+		t.Fatal("Empty non-terminal answer is reused after descendant removal")
+	}
+}
+
+func TestServer_FlattenCNAME(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			CNAME:        "lb.example.net.",
+			FlattenCNAME: true,
+		},
+		"www.example.org.": {
+			CNAME: "lb.example.net.",
+		},
+		"lb.example.net.": {
+			CNAME: "lb-1.example.net.",
+		},
+		"lb-1.example.net.": {
+			A: []string{"192.0.2.1"},
+		},
+	}
+	srv := newTestServer(t, zones, func(s *Server) {})
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	if a, ok := reply.Answer[0].(*dns.A); !ok || a.Hdr.Name != "example.org." || a.A.String() != "192.0.2.1" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+
+	reply = queryFrom(t, srv, "127.0.0.1", "www.example.org.", dns.TypeA)
+	if len(reply.Answer) != 2 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+
+	flattening := newTestServer(t, zones, func(s *Server) {
+		s.FlattenCNAME = true
+	})
+	defer flattening.Close()
+	reply = queryFrom(t, flattening, "127.0.0.1", "www.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].Header().Name != "www.example.org." {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+
+	// CNAME queries are not affected.
+	reply = queryFrom(t, flattening, "127.0.0.1", "example.org.", dns.TypeCNAME)
+	if len(reply.Answer) != 1 || reply.Answer[0].Header().Rrtype != dns.TypeCNAME {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+}
+
+func TestServer_AnswerCache_Regions(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+			Regions: map[string]Zone{
+				"eu": {A: []string{"192.0.2.2"}},
+			},
+		},
+	}
+	s := Server{r: Resolver{Zones: zones, src: NewZoneSource(zones)}}
+	eu := s.r
+	eu.Region = "eu"
+
+	def, err := s.answer("", &s.r, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regional, err := s.answer("", &eu, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := regional.answer[0].(*dns.A); a.A.String() != "192.0.2.2" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.2", a.A)
+	}
+	if again, _ := s.answer("", &s.r, "example.org.", dns.TypeA); again != def {
+		t.Fatal("Answer is rebuilt after query from another region")
+	}
+}
@@ -0,0 +1,68 @@
+package mockdns
+
+import (
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// ANYPolicy specifies how Server answers queries for the ANY (255) type.
+type ANYPolicy int
+
+const (
+	// ANYNoData answers ANY queries with NODATA, unless Zone.Misc contains
+	// records of the ANY type. This is the default.
+	ANYNoData ANYPolicy = iota
+	// ANYAll answers ANY queries with all records of the name, as classic
+	// servers do.
+	ANYAll
+	// ANYMinimal answers ANY queries with a single synthesized HINFO record
+	// as described in RFC 8482.
+	ANYMinimal
+)
+
+// anyRRs returns the records to answer the ANY query according to
+// Server.ANY. rname and rzone are the name and zone the queried name (with
+// qnameZone) resolves to.
+func (s *Server) anyRRs(rname string, qnameZone, rzone Zone) []dns.RR {
+	switch s.ANY {
+	case ANYAll:
+		rrs := s.compiledRRs(rname, rzone)
+		types := make([]int, 0, len(rrs.byType))
+		for rrtype := range rrs.byType {
+			types = append(types, int(rrtype))
+		}
+		sort.Ints(types)
+
+		var out []dns.RR
+		for _, rrtype := range types {
+			t := uint16(rrtype)
+			if t == dns.TypeA && rzone.AErr != nil || t == dns.TypeAAAA && rzone.AAAAErr != nil {
+				continue
+			}
+			out = append(out, rrs.byType[t]...)
+		}
+
+		miscTypes := make([]int, 0, len(rzone.Misc))
+		for rrtype := range rzone.Misc {
+			miscTypes = append(miscTypes, int(rrtype))
+		}
+		sort.Ints(miscTypes)
+		for _, rrtype := range miscTypes {
+			out = append(out, rzone.Misc[dns.Type(rrtype)]...)
+		}
+		return out
+	case ANYMinimal:
+		return []dns.RR{&dns.HINFO{
+			Hdr: dns.RR_Header{
+				Name:   rname,
+				Rrtype: dns.TypeHINFO,
+				Class:  dns.ClassINET,
+				Ttl:    rzone.ttl(),
+			},
+			Cpu: "RFC8482",
+		}}
+	default:
+		return qnameZone.Misc[dns.Type(dns.TypeANY)]
+	}
+}
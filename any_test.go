@@ -0,0 +1,58 @@
+package mockdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_ANY(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			TXT: []string{"text"},
+		},
+	}
+
+	query := func(policy ANYPolicy) *dns.Msg {
+		t.Helper()
+		srv := newTestServer(t, zones, func(s *Server) {
+			s.ANY = policy
+		})
+		defer srv.Close()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeANY)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	reply := query(ANYNoData)
+	if len(reply.Answer) != 0 || len(reply.Ns) != 1 {
+		t.Fatalf("Wrong reply for the default policy: %v", reply)
+	}
+
+	reply = query(ANYAll)
+	if len(reply.Answer) != 3 {
+		t.Fatalf("Wrong amount of records, want %v, got %v", 3, len(reply.Answer))
+	}
+	for i, rrtype := range []uint16{dns.TypeA, dns.TypeMX, dns.TypeTXT} {
+		if got := reply.Answer[i].Header().Rrtype; got != rrtype {
+			t.Errorf("Wrong type of record %d, want %v, got %v", i, dns.Type(rrtype), dns.Type(got))
+		}
+	}
+
+	reply = query(ANYMinimal)
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Wrong amount of records, want %v, got %v", 1, len(reply.Answer))
+	}
+	hinfo, ok := reply.Answer[0].(*dns.HINFO)
+	if !ok || hinfo.Cpu != "RFC8482" || hinfo.Os != "" {
+		t.Fatalf("Wrong RFC 8482 record: %v", reply.Answer[0])
+	}
+}
@@ -0,0 +1,108 @@
+package mockdns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// Blocklist is a set of blocked names, checked by Server before zones. Names
+// in it are answered as if they had Zone.Sinkhole set. See Server.Blocklist.
+//
+// The zero value is an empty Blocklist ready to use. Blocklist must not be
+// modified while it is used by Server.
+type Blocklist struct {
+	names map[string]struct{}
+}
+
+// NewBlocklist returns the Blocklist containing the specified names.
+func NewBlocklist(names ...string) *Blocklist {
+	b := &Blocklist{names: make(map[string]struct{}, len(names))}
+	b.Add(names...)
+	return b
+}
+
+// Add adds names to the set. Only exact names are blocked, not their
+// subdomains.
+func (b *Blocklist) Add(names ...string) {
+	b.init()
+	for _, name := range names {
+		b.names[normalizeName(name)] = struct{}{}
+	}
+}
+
+func (b *Blocklist) init() {
+	if b.names == nil {
+		b.names = make(map[string]struct{})
+	}
+}
+
+// hostsSkip contains names commonly found in hosts-format blocklists that
+// are not meant to be blocked.
+var hostsSkip = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+	"ip6-localnet.":          true,
+	"ip6-mcastprefix.":       true,
+	"ip6-allnodes.":          true,
+	"ip6-allrouters.":        true,
+	"ip6-allhosts.":          true,
+	"0.0.0.0.":               true,
+}
+
+// LoadHosts adds names from the hosts-format blocklist read from r, such as:
+//
+//	# comment
+//	0.0.0.0 ads.example.org
+//	127.0.0.1 tracker.example.org tracker2.example.org # comment
+//
+// Addresses are ignored, as are localhost and similar entries. The amount of
+// added names is returned.
+func (b *Blocklist) LoadHosts(r io.Reader) (int, error) {
+	b.init()
+	scnr := bufio.NewScanner(r)
+	lineNo, added := 0, 0
+	for scnr.Scan() {
+		lineNo++
+		line := scnr.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) == nil {
+			return added, fmt.Errorf("line %d: malformed IP: %v", lineNo, fields[0])
+		}
+
+		for _, name := range fields[1:] {
+			name = normalizeName(name)
+			if hostsSkip[name] {
+				continue
+			}
+			if _, ok := b.names[name]; !ok {
+				added++
+			}
+			b.names[name] = struct{}{}
+		}
+	}
+	return added, scnr.Err()
+}
+
+// Contains reports whether the name is blocked.
+func (b *Blocklist) Contains(name string) bool {
+	_, ok := b.names[normalizeName(name)]
+	return ok
+}
+
+// Len returns the amount of names in the set.
+func (b *Blocklist) Len() int {
+	return len(b.names)
+}
@@ -0,0 +1,72 @@
+package mockdns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestBlocklist_LoadHosts(t *testing.T) {
+	b := NewBlocklist("manual.example.org")
+	n, err := b.LoadHosts(strings.NewReader(`# Title: test list
+127.0.0.1 localhost
+::1 ip6-localhost
+0.0.0.0 0.0.0.0
+
+0.0.0.0 ads.example.org
+0.0.0.0 Tracker.Example.ORG tracker2.example.org # inline comment
+0.0.0.0 ads.example.org
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("Wrong amount of added names, want %v, got %v", 3, n)
+	}
+	if b.Len() != 4 {
+		t.Fatalf("Wrong length, want %v, got %v", 4, b.Len())
+	}
+	for _, name := range []string{"ads.example.org", "tracker.example.org.", "manual.example.org"} {
+		if !b.Contains(name) {
+			t.Errorf("%s is not blocked", name)
+		}
+	}
+	for _, name := range []string{"localhost", "sub.ads.example.org"} {
+		if b.Contains(name) {
+			t.Errorf("%s is blocked", name)
+		}
+	}
+
+	if _, err := b.LoadHosts(strings.NewReader("example.org\n")); err == nil {
+		t.Error("Expected error for line without address")
+	}
+}
+
+func TestBlocklist_Zero(t *testing.T) {
+	var b Blocklist
+	if b.Contains("ads.example.org") {
+		t.Fatal("ads.example.org is blocked")
+	}
+	b.Add("ads.example.org")
+	if _, err := b.LoadHosts(strings.NewReader("0.0.0.0 tracker.example.org\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 2 {
+		t.Fatalf("Wrong length, want %v, got %v", 2, b.Len())
+	}
+}
+
+func TestServer_Blocklist(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"ads.example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.Blocklist = NewBlocklist("ads.example.org")
+	})
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "ads.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "0.0.0.0" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+}
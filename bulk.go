@@ -0,0 +1,176 @@
+package mockdns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// BulkHosts is a compact set of names with A and AAAA records only. It is
+// meant for tests that need millions of names, where a Zone per name is too
+// heavy. See Resolver.Bulk.
+//
+// BulkHosts must not be modified while it is used for lookups.
+type BulkHosts struct {
+	index map[string]bulkEntry
+	// addrs contains IPv4 (4 bytes) addresses followed by IPv6 (16 bytes)
+	// addresses of all entries back-to-back.
+	addrs []byte
+	// unused is the amount of bytes in addrs no longer referenced by
+	// entries, since they were replaced by Add.
+	unused int
+}
+
+type bulkEntry struct {
+	off    uint32
+	n4, n6 uint16
+}
+
+// NewBulkHosts returns the empty BulkHosts.
+func NewBulkHosts() *BulkHosts {
+	return &BulkHosts{
+		index: make(map[string]bulkEntry),
+	}
+}
+
+// Add adds addresses for the specified name. Addresses are appended to the
+// existing ones if the name was added before. Since addresses of a name are
+// stored contiguously, they are copied in that case; storage is compacted
+// once most of it contains such old copies.
+func (b *BulkHosts) Add(name string, ips ...net.IP) error {
+	name = normalizeName(name)
+
+	var ips4, ips6 []net.IP
+	old, replaced := b.index[name]
+	if replaced {
+		ips4, ips6 = b.entryIPs(old)
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			ips4 = append(ips4, ip4)
+		} else if len(ip) == net.IPv6len {
+			ips6 = append(ips6, ip)
+		} else {
+			return fmt.Errorf("malformed IP for %s: %v", name, ip)
+		}
+	}
+	if len(ips4) > 0xFFFF || len(ips6) > 0xFFFF {
+		return fmt.Errorf("too many addresses for %s", name)
+	}
+	if len(b.addrs)+len(ips4)*net.IPv4len+len(ips6)*net.IPv6len > 0xFFFFFFFF {
+		return fmt.Errorf("too many addresses in total")
+	}
+
+	entry := bulkEntry{
+		off: uint32(len(b.addrs)),
+		n4:  uint16(len(ips4)),
+		n6:  uint16(len(ips6)),
+	}
+	for _, ip := range ips4 {
+		b.addrs = append(b.addrs, ip...)
+	}
+	for _, ip := range ips6 {
+		b.addrs = append(b.addrs, ip...)
+	}
+	b.index[name] = entry
+
+	if replaced {
+		b.unused += entrySize(old)
+		if b.unused > len(b.addrs)/2 {
+			b.compact()
+		}
+	}
+	return nil
+}
+
+func entrySize(entry bulkEntry) int {
+	return int(entry.n4)*net.IPv4len + int(entry.n6)*net.IPv6len
+}
+
+// compact copies addresses referenced by entries to the new storage,
+// dropping unused ones.
+func (b *BulkHosts) compact() {
+	addrs := make([]byte, 0, len(b.addrs)-b.unused)
+	for name, entry := range b.index {
+		size := entrySize(entry)
+		off := int(entry.off)
+		b.index[name] = bulkEntry{off: uint32(len(addrs)), n4: entry.n4, n6: entry.n6}
+		addrs = append(addrs, b.addrs[off:off+size]...)
+	}
+	b.addrs = addrs
+	b.unused = 0
+}
+
+// LoadCSV adds entries read from r. Each line contains the name followed by
+// one or more addresses, separated by commas. Empty lines and lines starting
+// with '#' are ignored.
+func (b *BulkHosts) LoadCSV(r io.Reader) error {
+	scnr := bufio.NewScanner(r)
+	lineNo := 0
+	for scnr.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: no addresses", lineNo)
+		}
+		ips := make([]net.IP, 0, len(fields)-1)
+		for _, f := range fields[1:] {
+			ip := net.ParseIP(strings.TrimSpace(f))
+			if ip == nil {
+				return fmt.Errorf("line %d: malformed IP: %v", lineNo, f)
+			}
+			ips = append(ips, ip)
+		}
+		if err := b.Add(strings.TrimSpace(fields[0]), ips...); err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+	return scnr.Err()
+}
+
+// Len returns the amount of names in the set.
+func (b *BulkHosts) Len() int {
+	return len(b.index)
+}
+
+func (b *BulkHosts) entryIPs(entry bulkEntry) (ips4, ips6 []net.IP) {
+	off := int(entry.off)
+	for i := 0; i < int(entry.n4); i++ {
+		ips4 = append(ips4, net.IP(b.addrs[off:off+net.IPv4len:off+net.IPv4len]))
+		off += net.IPv4len
+	}
+	for i := 0; i < int(entry.n6); i++ {
+		ips6 = append(ips6, net.IP(b.addrs[off:off+net.IPv6len:off+net.IPv6len]))
+		off += net.IPv6len
+	}
+	return ips4, ips6
+}
+
+// zone returns the Zone for the (normalized) name, if it is present in the
+// set.
+func (b *BulkHosts) zone(name string) (Zone, bool) {
+	entry, ok := b.index[name]
+	if !ok {
+		return Zone{}, false
+	}
+
+	ips4, ips6 := b.entryIPs(entry)
+	zone := Zone{
+		A:    make([]string, len(ips4)),
+		AAAA: make([]string, len(ips6)),
+	}
+	for i, ip := range ips4 {
+		zone.A[i] = ip.String()
+	}
+	for i, ip := range ips6 {
+		zone.AAAA[i] = ip.String()
+	}
+	return zone, true
+}
@@ -0,0 +1,103 @@
+package mockdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBulkHosts(t *testing.T) {
+	b := NewBulkHosts()
+	err := b.LoadCSV(strings.NewReader(`
+# name,addresses...
+host1.example.org,192.0.2.1,2001:db8::1
+Host2.example.org.,192.0.2.2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := b.Add(fmt.Sprintf("gen%d.example.org", i), net.IPv4(10, 0, byte(i>>8), byte(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Add("host2.example.org", net.ParseIP("192.0.2.3")); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 1002 {
+		t.Fatalf("Wrong Len, want %v, got %v", 1002, b.Len())
+	}
+
+	r := Resolver{
+		Zones: map[string]Zone{
+			"host1.example.org.": {A: []string{"198.51.100.1"}},
+		},
+		Bulk: b,
+	}
+
+	cases := map[string][]string{
+		"host1.example.org":   {"198.51.100.1"},
+		"host2.example.org":   {"192.0.2.2", "192.0.2.3"},
+		"gen513.example.org.": {"10.0.2.1"},
+	}
+	for name, want := range cases {
+		addrs, err := r.LookupHost(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(addrs, want) {
+			t.Errorf("Wrong result for %v, want %v, got %v", name, want, addrs)
+		}
+	}
+
+	srv := newTestServer(t, nil, func(s *Server) {
+		s.Resolver().Bulk = b
+	})
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+	addrs, err := netR.LookupHost(context.Background(), "host1.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.1", "2001:db8::1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong net.Resolver result, want %v, got %v", want, addrs)
+	}
+
+	if err := b.LoadCSV(strings.NewReader("bad.example.org,not-an-ip\n")); err == nil {
+		t.Fatal("Expected error for malformed IP")
+	}
+}
+
+func TestBulkHosts_Compact(t *testing.T) {
+	b := NewBulkHosts()
+	if err := b.Add("other.example.org", net.IPv4(192, 0, 2, 100)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 100; i++ {
+		if err := b.Add("example.org", net.IPv4(192, 0, 2, byte(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 101 addresses are referenced.
+	if want := 101 * net.IPv4len; len(b.addrs) > 2*want {
+		t.Fatalf("Storage is not compacted, want at most %v bytes, got %v", 2*want, len(b.addrs))
+	}
+
+	r := Resolver{Bulk: b}
+	addrs, err := r.LookupHost(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 100 || addrs[0] != "192.0.2.1" || addrs[99] != "192.0.2.100" {
+		t.Fatalf("Wrong result: %v", addrs)
+	}
+	if addrs, err := r.LookupHost(context.Background(), "other.example.org"); err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.100" {
+		t.Fatalf("Wrong result: %v, %v", addrs, err)
+	}
+}
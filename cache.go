@@ -0,0 +1,258 @@
+package mockdns
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultNegativeTTL matches the MINIMUM field of generated SOA records.
+const defaultNegativeTTL = 60 * time.Second
+
+// CachingResolver is a caching wrapper around Resolver, similar to a caching
+// stub resolver. It can be used to test code that relies on lookups being
+// cached.
+//
+// Results are cached for the TTL of zones they were produced from (the
+// smallest one if CNAMEs were followed). "No such host" errors are cached
+// for Zone.NegativeTTL of the zone or NegativeTTL, other errors are not
+// cached. Results for Resolver.Hosts entries use the default zone TTL.
+//
+// Cached slices are copied before being returned, but values they point to
+// (e.g. *net.MX) are shared and should not be modified.
+type CachingResolver struct {
+	Resolver *Resolver
+
+	// MaxEntries limits the amount of cached results. Least recently used
+	// ones are evicted first. Zero means no limit.
+	MaxEntries int
+
+	// NegativeTTL is the time "no such host" errors are cached for if the
+	// zone does not specify it. Defaults to 60 seconds.
+	NegativeTTL time.Duration
+
+	// Now is used to get the current time, making it possible to test
+	// expiration without waiting. Defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver returns CachingResolver for r.
+func NewCachingResolver(r *Resolver) *CachingResolver {
+	return &CachingResolver{Resolver: r}
+}
+
+func (c *CachingResolver) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Hits returns the amount of lookups served from the cache.
+func (c *CachingResolver) Hits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+// Misses returns the amount of lookups passed to the Resolver.
+func (c *CachingResolver) Misses() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.misses
+}
+
+// Len returns the amount of cached results, including expired ones that
+// were not evicted yet.
+func (c *CachingResolver) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Flush removes all cached results.
+func (c *CachingResolver) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.Init()
+}
+
+// lookup returns the cached result for key or calls f and caches its result
+// for the TTL of zone name.
+func (c *CachingResolver) lookup(key, name string, f func() (interface{}, error)) (interface{}, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if now.Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			c.hits++
+			c.mu.Unlock()
+			return entry.value, entry.err
+		}
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	value, err := f()
+	ttl, cache := c.ttl(name, err)
+	if !cache {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		value:   value,
+		err:     err,
+		expires: now.Add(ttl),
+	})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return value, err
+}
+
+// ttl returns the time the lookup result for name should be cached for.
+func (c *CachingResolver) ttl(name string, err error) (time.Duration, bool) {
+	name = normalizeName(name)
+	zone, ok := c.Resolver.zone(name)
+
+	if err != nil {
+		dnsErr, isDNSErr := err.(*net.DNSError)
+		if !isDNSErr || !isNotFound(dnsErr) {
+			return 0, false
+		}
+		if ok && zone.NegativeTTL != 0 {
+			return time.Duration(zone.NegativeTTL) * time.Second, true
+		}
+		if c.NegativeTTL != 0 {
+			return c.NegativeTTL, true
+		}
+		return defaultNegativeTTL, true
+	}
+
+	ttl := zone.ttl()
+	for i := 0; ok && zone.CNAME != "" && !c.Resolver.SkipCNAME && i < c.Resolver.maxCNAMEDepth(); i++ {
+		zone, ok = c.Resolver.zone(normalizeName(zone.CNAME))
+		if ok && zone.ttl() < ttl {
+			ttl = zone.ttl()
+		}
+	}
+	return time.Duration(ttl) * time.Second, true
+}
+
+func (c *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	v, err := c.lookup("host/"+normalizeName(host), host, func() (interface{}, error) {
+		return c.Resolver.LookupHost(ctx, host)
+	})
+	addrs, _ := v.([]string)
+	return append([]string(nil), addrs...), err
+}
+
+func (c *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	v, err := c.lookup("ipaddr/"+normalizeName(host), host, func() (interface{}, error) {
+		return c.Resolver.LookupIPAddr(ctx, host)
+	})
+	addrs, _ := v.([]net.IPAddr)
+	return append([]net.IPAddr(nil), addrs...), err
+}
+
+func (c *CachingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	v, err := c.lookup("ip/"+network+"/"+normalizeName(host), host, func() (interface{}, error) {
+		return c.Resolver.LookupIP(ctx, network, host)
+	})
+	addrs, _ := v.([]net.IP)
+	return append([]net.IP(nil), addrs...), err
+}
+
+func (c *CachingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name := ReverseName(net.ParseIP(addr))
+	if name == "" {
+		// Let Resolver report the error.
+		return c.Resolver.LookupAddr(ctx, addr)
+	}
+	v, err := c.lookup("addr/"+name, name, func() (interface{}, error) {
+		return c.Resolver.LookupAddr(ctx, addr)
+	})
+	names, _ := v.([]string)
+	return append([]string(nil), names...), err
+}
+
+func (c *CachingResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	v, err := c.lookup("cname/"+normalizeName(host), host, func() (interface{}, error) {
+		return c.Resolver.LookupCNAME(ctx, host)
+	})
+	cname, _ := v.(string)
+	return cname, err
+}
+
+func (c *CachingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	v, err := c.lookup("mx/"+normalizeName(name), name, func() (interface{}, error) {
+		return c.Resolver.LookupMX(ctx, name)
+	})
+	mxs, _ := v.([]*net.MX)
+	return append([]*net.MX(nil), mxs...), err
+}
+
+func (c *CachingResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	v, err := c.lookup("ns/"+normalizeName(name), name, func() (interface{}, error) {
+		return c.Resolver.LookupNS(ctx, name)
+	})
+	nss, _ := v.([]*net.NS)
+	return append([]*net.NS(nil), nss...), err
+}
+
+func (c *CachingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	v, err := c.lookup("txt/"+normalizeName(name), name, func() (interface{}, error) {
+		return c.Resolver.LookupTXT(ctx, name)
+	})
+	txts, _ := v.([]string)
+	return append([]string(nil), txts...), err
+}
+
+type srvResult struct {
+	cname string
+	addrs []*net.SRV
+}
+
+func (c *CachingResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	v, err := c.lookup("srv/"+normalizeName(target), target, func() (interface{}, error) {
+		cname, addrs, err := c.Resolver.LookupSRV(ctx, service, proto, name)
+		return srvResult{cname: cname, addrs: addrs}, err
+	})
+	res, _ := v.(srvResult)
+	return res.cname, append([]*net.SRV(nil), res.addrs...), err
+}
@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCachingResolver(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1"},
+			TTL: 300,
+		},
+		"alias.example.org.": {
+			CNAME: "example.org.",
+			TTL:   30,
+		},
+	}
+	now := time.Unix(1000, 0)
+	c := NewCachingResolver(&Resolver{Zones: zones})
+	c.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	lookup := func(name, want string) {
+		t.Helper()
+		addrs, err := c.LookupHost(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 1 || addrs[0] != want {
+			t.Fatalf("Wrong result, want %v, got %v", want, addrs)
+		}
+	}
+
+	lookup("example.org", "192.0.2.1")
+	zones["example.org."] = Zone{A: []string{"192.0.2.2"}, TTL: 300}
+	lookup("example.org", "192.0.2.1")
+	if c.Hits() != 1 || c.Misses() != 1 {
+		t.Fatalf("Wrong stats: %d hits, %d misses", c.Hits(), c.Misses())
+	}
+	// Names that differ only in case or the trailing dot share the entry.
+	lookup("Example.ORG.", "192.0.2.1")
+	if c.Hits() != 2 || c.Misses() != 1 {
+		t.Fatalf("Wrong stats: %d hits, %d misses", c.Hits(), c.Misses())
+	}
+
+	now = now.Add(301 * time.Second)
+	lookup("example.org", "192.0.2.2")
+
+	// CNAME TTL is smaller than the target one.
+	lookup("alias.example.org", "192.0.2.2")
+	zones["example.org."] = Zone{A: []string{"192.0.2.3"}, TTL: 300}
+	now = now.Add(31 * time.Second)
+	lookup("alias.example.org", "192.0.2.3")
+
+	// Negative caching.
+	if _, err := c.LookupHost(ctx, "new.example.org"); err == nil {
+		t.Fatal("Expected error for missing name")
+	}
+	zones["new.example.org."] = Zone{A: []string{"192.0.2.4"}}
+	if _, err := c.LookupHost(ctx, "new.example.org"); err == nil {
+		t.Fatal("Negative result is not cached")
+	}
+	now = now.Add(61 * time.Second)
+	lookup("new.example.org", "192.0.2.4")
+
+	c.MaxEntries = 1
+	lookup("alias.example.org", "192.0.2.3")
+	if c.Len() != 1 {
+		t.Fatalf("Wrong cache size, want %v, got %v", 1, c.Len())
+	}
+}
@@ -0,0 +1,56 @@
+package mockdns
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// staleTTL is the TTL of stale records, see Server.ServeStale.
+const staleTTL = 30
+
+func (s *Server) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock()
+	}
+	return time.Now()
+}
+
+// simulateCache adjusts TTLs of answer records as if they were returned
+// from a cache. See Server.SimulateCache.
+func (s *Server) simulateCache(reply *dns.Msg) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cacheSim == nil {
+		s.cacheSim = make(map[queryKey]time.Time)
+	}
+
+	w := sectionWriter{section: &reply.Answer}
+	for i, rr := range reply.Answer {
+		hdr := rr.Header()
+		if hdr.Ttl == 0 {
+			continue
+		}
+
+		key := queryKey{name: strings.ToLower(hdr.Name), qtype: hdr.Rrtype}
+		cached, ok := s.cacheSim[key]
+		if !ok {
+			s.cacheSim[key] = now
+			continue
+		}
+
+		elapsed := uint32(now.Sub(cached) / time.Second)
+
+		rr = dns.Copy(rr)
+		if s.ServeStale && elapsed >= hdr.Ttl {
+			rr.Header().Ttl = staleTTL
+		} else {
+			rr.Header().Ttl = hdr.Ttl - elapsed%hdr.Ttl
+		}
+		w.set(i, rr)
+	}
+}
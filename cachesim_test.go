@@ -0,0 +1,128 @@
+package mockdns
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type testClock struct {
+	lck sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestServer_SimulateCache(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000000, 0)}
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			A:   []string{"1.2.3.4"},
+			TTL: 300,
+		},
+		"www.example.org.": {
+			CNAME: "example.org.",
+			TTL:   60,
+		},
+	}, func(s *Server) {
+		s.Clock = clock.Now
+		s.SimulateCache = true
+	})
+	defer srv.Close()
+
+	cl := dns.Client{}
+	ttls := func(name string) []uint32 {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeA)
+		reply, _, err := cl.Exchange(msg, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		res := make([]uint32, 0, len(reply.Answer))
+		for _, rr := range reply.Answer {
+			res = append(res, rr.Header().Ttl)
+		}
+		return res
+	}
+
+	check := func(name string, want ...uint32) {
+		t.Helper()
+		got := ttls(name)
+		if len(got) != len(want) {
+			t.Fatalf("Wrong amount of answers for %s: %v", name, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Wrong TTLs for %s, want %v, got %v", name, want, got)
+				return
+			}
+		}
+	}
+
+	check("example.org.", 300)
+	clock.Advance(100 * time.Second)
+	check("example.org.", 200)
+	check("www.example.org.", 60, 200)
+	clock.Advance(50 * time.Second)
+	check("www.example.org.", 10, 150)
+	clock.Advance(150 * time.Second)
+	// A record expired and is "refetched".
+	check("example.org.", 300)
+}
+
+func TestServer_ServeStale(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000000, 0)}
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			A:   []string{"1.2.3.4"},
+			TTL: 300,
+		},
+	}, func(s *Server) {
+		s.Clock = clock.Now
+		s.SimulateCache = true
+		s.ServeStale = true
+	})
+	defer srv.Close()
+
+	cl := dns.Client{}
+	ttl := func() uint32 {
+		msg := new(dns.Msg)
+		msg.SetQuestion("example.org.", dns.TypeA)
+		reply, _, err := cl.Exchange(msg, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if len(reply.Answer) != 1 {
+			t.Fatalf("Wrong amount of answers: %v", reply.Answer)
+		}
+		return reply.Answer[0].Header().Ttl
+	}
+
+	if got := ttl(); got != 300 {
+		t.Errorf("Wrong initial TTL: %v", got)
+	}
+	clock.Advance(299 * time.Second)
+	if got := ttl(); got != 1 {
+		t.Errorf("Wrong TTL before expiry: %v", got)
+	}
+	clock.Advance(time.Second)
+	if got := ttl(); got != 30 {
+		t.Errorf("Wrong TTL after expiry: %v", got)
+	}
+	clock.Advance(time.Hour)
+	if got := ttl(); got != 30 {
+		t.Errorf("Wrong stale TTL: %v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package mockdns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// ClassPolicy specifies how Server handles queries with QCLASS ANY (255).
+// Queries with classes other than IN and ANY are always answered with
+// NOTIMP.
+type ClassPolicy int
+
+const (
+	// ClassANYAsIN answers ANY class queries as if they were IN class ones.
+	// Records in the answer have IN class. This is the default.
+	ClassANYAsIN ClassPolicy = iota
+	// ClassANYNotImplemented answers ANY class queries with NOTIMP, same as
+	// queries with other non-IN classes.
+	ClassANYNotImplemented
+	// ClassANYRefused answers ANY class queries with REFUSED.
+	ClassANYRefused
+)
+
+// classRcode returns the rcode to answer the query with the qclass class
+// without looking at zones or RcodeSuccess if it should be answered as usual.
+func (s *Server) classRcode(qclass uint16) int {
+	switch qclass {
+	case dns.ClassINET:
+		return dns.RcodeSuccess
+	case dns.ClassANY:
+		switch s.ClassANY {
+		case ClassANYAsIN:
+			return dns.RcodeSuccess
+		case ClassANYRefused:
+			return dns.RcodeRefused
+		}
+	}
+	return dns.RcodeNotImplemented
+}
@@ -0,0 +1,53 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_ClassANY(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}
+
+	query := func(policy ClassPolicy, qclass uint16) *dns.Msg {
+		t.Helper()
+		srv := newTestServer(t, zones, func(s *Server) {
+			s.ClassANY = policy
+		})
+		defer srv.Close()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Question[0].Qclass = qclass
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	reply := query(ClassANYAsIN, dns.ClassANY)
+	if reply.Rcode != dns.RcodeSuccess || len(reply.Answer) != 1 {
+		t.Fatalf("Wrong reply for class ANY: %v", reply)
+	}
+	if class := reply.Answer[0].Header().Class; class != dns.ClassINET {
+		t.Fatalf("Wrong record class, want %v, got %v", dns.Class(dns.ClassINET), dns.Class(class))
+	}
+
+	for policy, rcode := range map[ClassPolicy]int{
+		ClassANYNotImplemented: dns.RcodeNotImplemented,
+		ClassANYRefused:        dns.RcodeRefused,
+	} {
+		if reply := query(policy, dns.ClassANY); reply.Rcode != rcode {
+			t.Errorf("Wrong rcode for policy %v, want %v, got %v", policy, dns.RcodeToString[rcode], dns.RcodeToString[reply.Rcode])
+		}
+	}
+
+	if reply := query(ClassANYAsIN, dns.ClassCHAOS); reply.Rcode != dns.RcodeNotImplemented {
+		t.Fatalf("Wrong rcode for class CHAOS, want %v, got %v", dns.RcodeToString[dns.RcodeNotImplemented], dns.RcodeToString[reply.Rcode])
+	}
+}
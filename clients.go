@@ -0,0 +1,138 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ClientBehavior overrides Server behavior for specific clients, see
+// SetClientBehavior.
+type ClientBehavior struct {
+	// Delay is the time to wait before sending each reply.
+	Delay time.Duration
+
+	// Rcode, if non-zero, is sent instead of the normal reply, with no
+	// records.
+	Rcode int
+
+	// Drop makes Server not send replies at all. Queries are still
+	// recorded.
+	Drop bool
+}
+
+type clientBehavior struct {
+	cidr string
+	net  *net.IPNet
+	b    ClientBehavior
+}
+
+// SetClientBehavior overrides Server behavior for clients with addresses
+// from cidr, which can be also a single IP address. Calling it again for the
+// same cidr replaces the behavior. If multiple networks match the client,
+// the one added first is used.
+//
+// This makes it possible to give different components of a system under test
+// different DNS behavior from one shared Server. Note that queries from
+// PatchNet'ed resolvers and most clients within a single process come from
+// 127.0.0.1.
+func (s *Server) SetClientBehavior(cidr string, b ClientBehavior) error {
+	ipNet, err := parseClientNet(cidr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, cb := range s.clientBehaviors {
+		if cb.cidr == cidr {
+			s.clientBehaviors[i].b = b
+			return nil
+		}
+	}
+	s.clientBehaviors = append(s.clientBehaviors, clientBehavior{cidr: cidr, net: ipNet, b: b})
+	return nil
+}
+
+// ClearClientBehavior removes the override set by SetClientBehavior for
+// cidr.
+func (s *Server) ClearClientBehavior(cidr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, cb := range s.clientBehaviors {
+		if cb.cidr == cidr {
+			s.clientBehaviors = append(s.clientBehaviors[:i:i], s.clientBehaviors[i+1:]...)
+			return
+		}
+	}
+}
+
+func parseClientNet(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("malformed client address: %s", cidr)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	return ipNet, err
+}
+
+// behaviorFor returns the behavior override for the client, if any.
+func (s *Server) behaviorFor(addr net.Addr) (ClientBehavior, bool) {
+	ip := addrIP(addr)
+	if ip == nil {
+		return ClientBehavior{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cb := range s.clientBehaviors {
+		if cb.net.Contains(ip) {
+			return cb.b, true
+		}
+	}
+	return ClientBehavior{}, false
+}
+
+// ClientQueries returns queries served by the Server to the client with the
+// specified IP address, in the order they were answered.
+func (s *Server) ClientQueries(ip string) []QueryInfo {
+	parsed := net.ParseIP(ip)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []QueryInfo
+	for _, q := range s.queries {
+		if clientIP := addrIP(q.RemoteAddr); clientIP != nil && clientIP.Equal(parsed) {
+			res = append(res, q)
+		}
+	}
+	return res
+}
+
+// Clients returns IP addresses of all clients that sent queries to the
+// Server so far, in the order of their first query.
+func (s *Server) Clients() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		res  []string
+		seen = make(map[string]bool)
+	)
+	for _, q := range s.queries {
+		clientIP := addrIP(q.RemoteAddr)
+		if clientIP == nil || seen[clientIP.String()] {
+			continue
+		}
+		seen[clientIP.String()] = true
+		res = append(res, clientIP.String())
+	}
+	return res
+}
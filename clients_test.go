@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_ClientBehavior(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	if err := srv.SetClientBehavior("127.0.0.2", ClientBehavior{Rcode: dns.RcodeServerFailure}); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeSuccess || len(reply.Answer) != 1 {
+		t.Fatalf("Wrong reply for client without override: %v", reply)
+	}
+	reply = queryFrom(t, srv, "127.0.0.2", "example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, reply.Rcode)
+	}
+
+	if err := srv.SetClientBehavior("127.0.0.2", ClientBehavior{Delay: 100 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	queryFrom(t, srv, "127.0.0.2", "example.org.", dns.TypeA)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Reply is not delayed: %v", elapsed)
+	}
+
+	if err := srv.SetClientBehavior("127.0.0.0/30", ClientBehavior{Drop: true}); err != nil {
+		t.Fatal(err)
+	}
+	cl := dns.Client{
+		Timeout: 100 * time.Millisecond,
+		Dialer:  &net.Dialer{LocalAddr: &net.UDPAddr{IP: net.ParseIP("127.0.0.3")}},
+	}
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, _, err := cl.Exchange(m, srv.LocalAddr().String()); err == nil {
+		t.Fatal("Reply is not dropped")
+	}
+
+	srv.ClearClientBehavior("127.0.0.2")
+	srv.ClearClientBehavior("127.0.0.0/30")
+	queryFrom(t, srv, "127.0.0.2", "example.org.", dns.TypeA)
+
+	if got := len(srv.ClientQueries("127.0.0.2")); got != 3 {
+		t.Errorf("Wrong amount of client queries, want %v, got %v", 3, got)
+	}
+	if got := len(srv.ClientQueries("127.0.0.3")); got != 1 {
+		t.Errorf("Wrong amount of client queries, want %v, got %v", 1, got)
+	}
+	clients := srv.Clients()
+	if len(clients) != 3 || clients[0] != "127.0.0.1" || clients[1] != "127.0.0.2" {
+		t.Errorf("Wrong clients: %v", clients)
+	}
+
+	if err := srv.SetClientBehavior("not-an-ip", ClientBehavior{}); err == nil {
+		t.Error("Expected error for malformed address")
+	}
+}
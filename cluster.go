@@ -0,0 +1,117 @@
+package mockdns
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Cluster is a set of Servers, useful to test clients configured with
+// multiple name servers (rotation, retrying the next server, etc.).
+type Cluster struct {
+	Servers []*Server
+}
+
+// ClusterQuery is a query served by one of Cluster servers.
+type ClusterQuery struct {
+	QueryInfo
+
+	// Server is the index of the server in Cluster.Servers.
+	Server int
+}
+
+// NewCluster starts n servers. If no zones are passed, servers have none.
+// If one map is passed, all servers serve it. Otherwise, there should be a
+// map for each server.
+//
+// Servers can be modified individually, e.g. to make one of them fail
+// queries with Zone.Err or drop them with SetClientBehavior.
+func NewCluster(n int, zonesPerServer ...map[string]Zone) (*Cluster, error) {
+	if n <= 0 {
+		return nil, errors.New("cluster size must be positive")
+	}
+	if len(zonesPerServer) > 1 && len(zonesPerServer) != n {
+		return nil, fmt.Errorf("zones for %d servers are passed, cluster size is %d", len(zonesPerServer), n)
+	}
+
+	c := &Cluster{Servers: make([]*Server, 0, n)}
+	for i := 0; i < n; i++ {
+		zones := map[string]Zone{}
+		switch len(zonesPerServer) {
+		case 0:
+		case 1:
+			zones = zonesPerServer[0]
+		default:
+			zones = zonesPerServer[i]
+		}
+
+		srv, err := NewServer(zones, false)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("server %d: %v", i, err)
+		}
+		c.Servers = append(c.Servers, srv)
+	}
+	return c, nil
+}
+
+// NewClusterWithSource starts n servers sharing src, so changes made using
+// it are visible on all servers at once.
+func NewClusterWithSource(n int, src *ZoneSource) (*Cluster, error) {
+	if n <= 0 {
+		return nil, errors.New("cluster size must be positive")
+	}
+
+	c := &Cluster{Servers: make([]*Server, 0, n)}
+	for i := 0; i < n; i++ {
+		srv, err := NewServerWithSource(src, defaultLogger(), false)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("server %d: %v", i, err)
+		}
+		c.Servers = append(c.Servers, srv)
+	}
+	return c, nil
+}
+
+// Addrs returns addresses of all servers, in order.
+func (c *Cluster) Addrs() []string {
+	addrs := make([]string, 0, len(c.Servers))
+	for _, srv := range c.Servers {
+		addrs = append(addrs, srv.LocalAddr().String())
+	}
+	return addrs
+}
+
+// Queries returns queries served by all servers so far, ordered by the time
+// they were received.
+func (c *Cluster) Queries() []ClusterQuery {
+	var res []ClusterQuery
+	for i, srv := range c.Servers {
+		for _, q := range srv.Queries() {
+			res = append(res, ClusterQuery{QueryInfo: q, Server: i})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Time.Before(res[j].Time)
+	})
+	return res
+}
+
+// ResetQueryCounts resets query logs and counters of all servers.
+func (c *Cluster) ResetQueryCounts() {
+	for _, srv := range c.Servers {
+		srv.ResetQueryCounts()
+	}
+}
+
+// Close stops all servers.
+func (c *Cluster) Close() error {
+	var firstErr error
+	for _, srv := range c.Servers {
+		if err := srv.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
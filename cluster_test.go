@@ -0,0 +1,60 @@
+package mockdns
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCluster(t *testing.T) {
+	c, err := NewCluster(3, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	addrs := c.Addrs()
+	if len(addrs) != 3 {
+		t.Fatalf("Wrong amount of addresses, want %v, got %v", 3, len(addrs))
+	}
+
+	c.Servers[0].AddZone("example.org.", Zone{Err: errors.New("broken")})
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	for i, addr := range []string{addrs[0], addrs[1], addrs[2], addrs[1]} {
+		reply, err := dns.Exchange(m, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantRcode := dns.RcodeSuccess
+		if i == 0 {
+			wantRcode = dns.RcodeServerFailure
+		}
+		if reply.Rcode != wantRcode {
+			t.Fatalf("Wrong rcode from %s, want %v, got %v", addr, wantRcode, reply.Rcode)
+		}
+	}
+
+	queries := c.Queries()
+	if len(queries) != 4 {
+		t.Fatalf("Wrong amount of queries, want %v, got %v", 4, len(queries))
+	}
+	for i, want := range []int{0, 1, 2, 1} {
+		if queries[i].Server != want {
+			t.Errorf("Wrong server for query %d, want %v, got %v", i, want, queries[i].Server)
+		}
+	}
+
+	c.ResetQueryCounts()
+	if len(c.Queries()) != 0 {
+		t.Error("Queries are not reset")
+	}
+
+	if _, err := NewCluster(3, map[string]Zone{}, map[string]Zone{}); err == nil {
+		t.Error("Expected error for mismatched zones count")
+	}
+}
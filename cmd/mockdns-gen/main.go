@@ -0,0 +1,233 @@
+// Command mockdns-gen converts RFC 1035 zone files and YAML (or JSON) zone
+// documents into Go source declaring
+// map[string]mockdns.Zone, so fixtures are type-checked at compile time and
+// are not parsed at run time.
+//
+// Usage:
+//
+//	//go:generate mockdns-gen -var Zones -o zones_gen.go testdata/example.org.zone
+//
+// Files with the ".yaml", ".yml" or ".json" extension are read using
+// mockdns.AddZonesYAML. For zone files, the origin is the base name with the
+// ".zone" or ".db" extension removed, same as for mockdns.LoadZonesFS.
+// Records stored in Zone.Misc are the only ones parsed at run time (once,
+// during package initialization).
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+func main() {
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file, $GOPACKAGE by default")
+	varName := flag.String("var", "Zones", "name of the declared variable")
+	out := flag.String("o", "", "output file, stdout by default")
+	flag.Parse()
+
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mockdns-gen [-pkg name] [-var name] [-o file] file...")
+		os.Exit(2)
+	}
+
+	zones := make(map[string]mockdns.Zone)
+	for _, file := range flag.Args() {
+		if err := loadFile(zones, file); err != nil {
+			fmt.Fprintln(os.Stderr, "mockdns-gen:", err)
+			os.Exit(1)
+		}
+	}
+
+	src, err := generate(*pkg, *varName, zones)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mockdns-gen:", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "mockdns-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func loadFile(zones map[string]mockdns.Zone, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml", ".json":
+		return mockdns.AddZonesYAML(zones, f, file)
+	}
+	origin := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".zone"), ".db")
+	return mockdns.AddZoneFile(zones, f, origin, file)
+}
+
+// generate returns the formatted source of the file declaring varName with
+// zones.
+func generate(pkg, varName string, zones map[string]mockdns.Zone) ([]byte, error) {
+	// The helper is named after the variable, so multiple generated files
+	// can be in the same package.
+	g := &generator{rrHelper: "mockdnsGen" + upperFirst(varName) + "RR"}
+
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.printf("var %s = map[string]mockdns.Zone{\n", varName)
+	for _, name := range names {
+		g.printf("%q: {\n", name)
+		g.zone(zones[name])
+		g.printf("},\n")
+	}
+	g.printf("}\n")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mockdns-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if g.usesNet {
+		buf.WriteString("\t\"net\"\n\n")
+	}
+	buf.WriteString("\t\"github.com/foxcpp/go-mockdns\"\n")
+	if g.usesDNS {
+		buf.WriteString("\t\"github.com/miekg/dns\"\n")
+	}
+	buf.WriteString(")\n\n")
+	buf.Write(g.buf.Bytes())
+	if g.usesDNS {
+		fmt.Fprintf(&buf, rrHelper, g.rrHelper)
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// upperFirst returns s with the first letter in upper case.
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+const rrHelper = `
+func %s(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+`
+
+type generator struct {
+	buf      bytes.Buffer
+	usesNet  bool
+	usesDNS  bool
+	rrHelper string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) strings(field string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	g.printf("%s: []string{", field)
+	for i, v := range values {
+		if i != 0 {
+			g.printf(", ")
+		}
+		g.printf("%q", v)
+	}
+	g.printf("},\n")
+}
+
+func (g *generator) zone(zone mockdns.Zone) {
+	if zone.TTL != 0 {
+		g.printf("TTL: %d,\n", zone.TTL)
+	}
+	g.strings("A", zone.A)
+	g.strings("AAAA", zone.AAAA)
+	g.strings("TXT", zone.TXT)
+	g.strings("PTR", zone.PTR)
+	if zone.CNAME != "" {
+		g.printf("CNAME: %q,\n", zone.CNAME)
+	}
+	if len(zone.MX) != 0 {
+		g.usesNet = true
+		g.printf("MX: []net.MX{\n")
+		for _, mx := range zone.MX {
+			g.printf("{Host: %q, Pref: %d},\n", mx.Host, mx.Pref)
+		}
+		g.printf("},\n")
+	}
+	if len(zone.NS) != 0 {
+		g.usesNet = true
+		g.printf("NS: []net.NS{\n")
+		for _, ns := range zone.NS {
+			g.printf("{Host: %q},\n", ns.Host)
+		}
+		g.printf("},\n")
+	}
+	if len(zone.SRV) != 0 {
+		g.usesNet = true
+		g.printf("SRV: []net.SRV{\n")
+		for _, srv := range zone.SRV {
+			g.printf("{Target: %q, Port: %d, Priority: %d, Weight: %d},\n", srv.Target, srv.Port, srv.Priority, srv.Weight)
+		}
+		g.printf("},\n")
+	}
+
+	if len(zone.Misc) == 0 {
+		return
+	}
+	g.usesDNS = true
+	types := make([]int, 0, len(zone.Misc))
+	for rrtype := range zone.Misc {
+		types = append(types, int(rrtype))
+	}
+	sort.Ints(types)
+	g.printf("Misc: map[dns.Type][]dns.RR{\n")
+	for _, rrtype := range types {
+		g.printf("dns.Type(%s): {\n", typeExpr(uint16(rrtype)))
+		for _, rr := range zone.Misc[dns.Type(rrtype)] {
+			g.printf("%s(%q),\n", g.rrHelper, rr.String())
+		}
+		g.printf("},\n")
+	}
+	g.printf("},\n")
+}
+
+// typeExpr returns the Go expression for the record type constant.
+func typeExpr(rrtype uint16) string {
+	if name, ok := dns.TypeToString[rrtype]; ok && !strings.ContainsAny(name, "-") {
+		return "dns.Type" + name
+	}
+	return strconv.Itoa(int(rrtype))
+}
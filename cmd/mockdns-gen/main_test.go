@@ -0,0 +1,109 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+func TestGenerate(t *testing.T) {
+	caa, err := dns.NewRR(`example.org. 300 IN CAA 0 issue "ca.example.net"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("fixtures", "Zones", map[string]mockdns.Zone{
+		"example.org.": {
+			TTL:  300,
+			A:    []string{"192.0.2.1"},
+			MX:   []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			Misc: map[dns.Type][]dns.RR{dns.Type(dns.TypeCAA): {caa}},
+		},
+		"www.example.org.": {
+			CNAME: "example.org.",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "zones_gen.go", src, 0); err != nil {
+		t.Fatalf("Generated source does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"package fixtures",
+		`A:   []string{"192.0.2.1"}`,
+		`{Host: "mx.example.org.", Pref: 10}`,
+		`CNAME: "example.org."`,
+		"dns.Type(dns.TypeCAA): {",
+		"func mockdnsGenZonesRR(s string) dns.RR {",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Generated source does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerate_HelperPerVar(t *testing.T) {
+	caa, err := dns.NewRR(`example.org. 300 IN CAA 0 issue "ca.example.net"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := map[string]mockdns.Zone{
+		"example.org.": {Misc: map[dns.Type][]dns.RR{dns.Type(dns.TypeCAA): {caa}}},
+	}
+
+	for varName, helper := range map[string]string{
+		"Zones":    "mockdnsGenZonesRR",
+		"fixtures": "mockdnsGenFixturesRR",
+	} {
+		src, err := generate("fixtures", varName, zones)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(src), helper+"("); got != 2 {
+			t.Errorf("Wrong result for %s, want %v uses of %s, got %v:\n%s", varName, 2, helper, got, src)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockdns-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"example.org.zone": "@ 300 IN A 192.0.2.1\n",
+		"example.net.yaml": "example.net.:\n  - 300 IN A 192.0.2.2\n",
+		"example.com.json": `{"example.com.": "300 IN A 192.0.2.3"}`,
+	}
+	zones := make(map[string]mockdns.Zone)
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadFile(zones, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]mockdns.Zone{
+		"example.org.": {TTL: 300, A: []string{"192.0.2.1"}},
+		"example.net.": {TTL: 300, A: []string{"192.0.2.2"}},
+		"example.com.": {TTL: 300, A: []string{"192.0.2.3"}},
+	}
+	if !reflect.DeepEqual(zones, want) {
+		t.Fatalf("Wrong result, want %+v, got %+v", want, zones)
+	}
+}
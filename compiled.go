@@ -0,0 +1,212 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// zoneRRs contains records built from a Zone once and reused for all
+// queries, so ServeDNS does not construct them on each query.
+//
+// Records are shared between replies and must not be modified.
+type zoneRRs struct {
+	src    Zone
+	byType map[uint16][]dns.RR
+
+	// err is set if some of the zone values are malformed and are not
+	// included in byType.
+	err error
+}
+
+// cacheGen identifies the state of zones caches were built from.
+type cacheGen struct {
+	// zones is the ZoneSource generation.
+	zones uint32
+	// views is incremented by AddView and RemoveView.
+	views uint32
+}
+
+// zoneCache contains records built from zones of one generation. It is
+// replaced with an empty one when zones change, so entries for removed or
+// changed zones are not kept.
+type zoneCache struct {
+	gen cacheGen
+	// compiled contains *zoneRRs for zones, keyed by zone name.
+	compiled sync.Map
+	// answers contains *answerSet for questions, keyed by answerKey. size
+	// is the amount of them, see maxCachedAnswers.
+	answers sync.Map
+	size    int32
+}
+
+// maxCachedAnswers is the amount of answers stored until the cache is
+// cleared, so queries for many different names do not make it grow forever.
+const maxCachedAnswers = 4096
+
+// zoneCache returns the cache for the current generation of zones.
+func (s *Server) zoneCache() *zoneCache {
+	gen := cacheGen{
+		zones: s.r.src.generation(),
+		views: atomic.LoadUint32(&s.viewGen),
+	}
+	if c, ok := s.cache.Load().(*zoneCache); ok && c.gen == gen {
+		return c
+	}
+	c := &zoneCache{gen: gen}
+	s.cache.Store(c)
+	return c
+}
+
+// compiledRRs returns the records for the zone with the specified
+// (normalized) name, building them if zone was not seen before or was
+// changed since then.
+func (s *Server) compiledRRs(name string, zone Zone) *zoneRRs {
+	cache := s.zoneCache()
+	if v, ok := cache.compiled.Load(name); ok {
+		rrs := v.(*zoneRRs)
+		// Zones can be also modified in place (e.g. Resolver().Zones) or
+		// differ between views.
+		if sameZone(rrs.src, zone) {
+			return rrs
+		}
+	}
+
+	rrs := compileZone(name, zone)
+	cache.compiled.Store(name, rrs)
+	return rrs
+}
+
+func compileZone(name string, zone Zone) *zoneRRs {
+	ttl := zone.ttl()
+	hdr := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{
+			Name:   name,
+			Rrtype: rrtype,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		}
+	}
+
+	rrs := &zoneRRs{
+		src:    copyZone(zone),
+		byType: make(map[uint16][]dns.RR),
+	}
+	for _, addr := range zone.A {
+		parsed := net.ParseIP(addr)
+		if parsed == nil || parsed.To4() == nil {
+			rrs.err = fmt.Errorf("malformed IP in records: %v", addr)
+			continue
+		}
+		rrs.byType[dns.TypeA] = append(rrs.byType[dns.TypeA], &dns.A{
+			Hdr: hdr(dns.TypeA),
+			A:   parsed,
+		})
+	}
+	for _, addr := range zone.AAAA {
+		// The zone identifier has no representation on the wire and is
+		// only meaningful to Resolver.
+		parsed, _ := parseIPZone(addr)
+		if parsed == nil {
+			rrs.err = fmt.Errorf("malformed IP in records: %v", addr)
+			continue
+		}
+		rrs.byType[dns.TypeAAAA] = append(rrs.byType[dns.TypeAAAA], &dns.AAAA{
+			Hdr:  hdr(dns.TypeAAAA),
+			AAAA: parsed,
+		})
+	}
+	for _, mx := range zone.MX {
+		rrs.byType[dns.TypeMX] = append(rrs.byType[dns.TypeMX], &dns.MX{
+			Hdr:        hdr(dns.TypeMX),
+			Preference: mx.Pref,
+			Mx:         mx.Host,
+		})
+	}
+	for _, ns := range zone.NS {
+		rrs.byType[dns.TypeNS] = append(rrs.byType[dns.TypeNS], &dns.NS{
+			Hdr: hdr(dns.TypeNS),
+			Ns:  ns.Host,
+		})
+	}
+	for _, srv := range zone.SRV {
+		rrs.byType[dns.TypeSRV] = append(rrs.byType[dns.TypeSRV], &dns.SRV{
+			Hdr:      hdr(dns.TypeSRV),
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+			Port:     srv.Port,
+			Target:   srv.Target,
+		})
+	}
+	for _, txt := range zone.TXT {
+		rrs.byType[dns.TypeTXT] = append(rrs.byType[dns.TypeTXT], &dns.TXT{
+			Hdr: hdr(dns.TypeTXT),
+			Txt: splitTXT(txt),
+		})
+	}
+	for _, ptr := range zone.PTR {
+		rrs.byType[dns.TypePTR] = append(rrs.byType[dns.TypePTR], &dns.PTR{
+			Hdr: hdr(dns.TypePTR),
+			Ptr: ptr,
+		})
+	}
+
+	return rrs
+}
+
+// sameZone reports whether records of b are the same as of a. a must be
+// created by copyZone, so in-place changes of b are noticed.
+func sameZone(a, b Zone) bool {
+	if a.TTL != b.TTL ||
+		!sameStrings(a.A, b.A) ||
+		!sameStrings(a.AAAA, b.AAAA) ||
+		!sameStrings(a.TXT, b.TXT) ||
+		!sameStrings(a.PTR, b.PTR) ||
+		len(a.MX) != len(b.MX) || len(a.NS) != len(b.NS) || len(a.SRV) != len(b.SRV) {
+		return false
+	}
+	for i := range a.MX {
+		if a.MX[i] != b.MX[i] {
+			return false
+		}
+	}
+	for i := range a.NS {
+		if a.NS[i] != b.NS[i] {
+			return false
+		}
+	}
+	for i := range a.SRV {
+		if a.SRV[i] != b.SRV[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyZone returns the copy of zone not sharing record slices and maps with
+// it.
+func copyZone(zone Zone) Zone {
+	zone.A = append([]string(nil), zone.A...)
+	zone.AAAA = append([]string(nil), zone.AAAA...)
+	zone.TXT = append([]string(nil), zone.TXT...)
+	zone.PTR = append([]string(nil), zone.PTR...)
+	zone.MX = append([]net.MX(nil), zone.MX...)
+	zone.NS = append([]net.NS(nil), zone.NS...)
+	zone.SRV = append([]net.SRV(nil), zone.SRV...)
+	return zone
+}
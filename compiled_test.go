@@ -0,0 +1,94 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_CompiledRRs(t *testing.T) {
+	var s Server
+
+	zone := Zone{A: []string{"192.0.2.1"}}
+	first := s.compiledRRs("example.org.", zone)
+	if len(first.byType[dns.TypeA]) != 1 {
+		t.Fatalf("Wrong amount of A records: %v", first.byType[dns.TypeA])
+	}
+	if again := s.compiledRRs("example.org.", zone); again != first {
+		t.Fatal("Records are rebuilt for unchanged zone")
+	}
+
+	zone.A = []string{"192.0.2.2"}
+	changed := s.compiledRRs("example.org.", zone)
+	if changed == first {
+		t.Fatal("Records are not rebuilt for changed zone")
+	}
+	if a := changed.byType[dns.TypeA][0].(*dns.A); a.A.String() != "192.0.2.2" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.2", a.A)
+	}
+
+	zone.TTL = 30
+	if ttl := s.compiledRRs("example.org.", zone).byType[dns.TypeA][0].Header().Ttl; ttl != 30 {
+		t.Fatalf("Wrong TTL, want %v, got %v", 30, ttl)
+	}
+
+	zone.A[0] = "192.0.2.3"
+	if a := s.compiledRRs("example.org.", zone).byType[dns.TypeA][0].(*dns.A); a.A.String() != "192.0.2.3" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.3", a.A)
+	}
+}
+
+func TestServer_CompiledRRs_Evicted(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	srv.compiledRRs("example.org.", srv.ZoneSource().Zones()["example.org."])
+	srv.RemoveZone("example.org.")
+	srv.AddZone("example.com.", Zone{A: []string{"192.0.2.2"}})
+	srv.compiledRRs("example.com.", srv.ZoneSource().Zones()["example.com."])
+
+	if _, ok := srv.zoneCache().compiled.Load("example.org."); ok {
+		t.Fatal("Records of the removed zone are kept")
+	}
+}
+
+func TestServer_ZoneReplaced(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	addrs, err := netR.LookupHost(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Fatalf("Wrong result: %v", addrs)
+	}
+
+	srv.AddZone("example.org.", Zone{A: []string{"192.0.2.2"}})
+
+	addrs, err = netR.LookupHost(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "192.0.2.2" {
+		t.Fatalf("Replaced zone is not used: %v", addrs)
+	}
+}
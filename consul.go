@@ -0,0 +1,102 @@
+package mockdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ConsulService is the service registered in Consul catalog, see
+// AddConsulService.
+type ConsulService struct {
+	Name string
+
+	// Datacenter defaults to "dc1".
+	Datacenter string
+
+	Instances []ConsulInstance
+}
+
+// ConsulInstance is the single instance of the Consul service.
+type ConsulInstance struct {
+	// Node is the name of the node the instance is running on.
+	Node    string
+	Address string
+	Port    uint16
+	Tags    []string
+}
+
+// AddConsulService adds records Consul DNS interface would serve for the
+// service to zones:
+//
+//   - A/AAAA and SRV records for <service>.service[.<dc>].consul,
+//   - the same for <tag>.<service>.service[.<dc>].consul for each tag,
+//   - SRV records for _<service>._<tag>.service[.<dc>].consul (RFC 2782
+//     lookups, "tcp" can be used as the tag matching all instances),
+//   - A/AAAA records for <node>.node[.<dc>].consul.
+//
+// SRV targets are node names. Set Server.AdditionalSRV to get node addresses
+// in the additional section, as Consul does.
+func AddConsulService(zones map[string]Zone, svc ConsulService) error {
+	if svc.Name == "" {
+		return errors.New("service name is required")
+	}
+	dc := svc.Datacenter
+	if dc == "" {
+		dc = "dc1"
+	}
+
+	for _, inst := range svc.Instances {
+		ip := net.ParseIP(inst.Address)
+		if ip == nil {
+			return fmt.Errorf("malformed instance address: %v", inst.Address)
+		}
+		if inst.Node == "" {
+			return errors.New("instance node name is required")
+		}
+
+		nodeName := normalizeName(inst.Node + ".node." + dc + ".consul")
+		addConsulAddr(zones, nodeName, ip)
+		addConsulAddr(zones, inst.Node+".node.consul", ip)
+
+		srv := net.SRV{
+			Target:   nodeName,
+			Port:     inst.Port,
+			Priority: 1,
+			Weight:   1,
+		}
+		prefixes := []string{"", "_" + svc.Name + "._tcp."}
+		for _, tag := range inst.Tags {
+			prefixes = append(prefixes, tag+".", "_"+svc.Name+"._"+tag+".")
+		}
+		for _, prefix := range prefixes {
+			base := svc.Name + ".service."
+			if prefix != "" && prefix[0] == '_' {
+				// RFC 2782 lookups already include the service name.
+				base = "service."
+			}
+			for _, suffix := range []string{"consul", dc + ".consul"} {
+				name := normalizeName(prefix + base + suffix)
+				if prefix == "" || prefix[0] != '_' {
+					addConsulAddr(zones, name, ip)
+				}
+				zone := zones[name]
+				zone.SRV = append(zone.SRV, srv)
+				zones[name] = zone
+			}
+		}
+	}
+
+	return nil
+}
+
+func addConsulAddr(zones map[string]Zone, name string, ip net.IP) {
+	name = normalizeName(name)
+	zone := zones[name]
+	if ip.To4() != nil {
+		zone.A = append(zone.A, ip.String())
+	} else {
+		zone.AAAA = append(zone.AAAA, ip.String())
+	}
+	zones[name] = zone
+}
@@ -0,0 +1,68 @@
+package mockdns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAddConsulService(t *testing.T) {
+	zones := map[string]Zone{}
+	err := AddConsulService(zones, ConsulService{
+		Name: "web",
+		Instances: []ConsulInstance{
+			{Node: "node1", Address: "10.0.0.1", Port: 8080, Tags: []string{"primary"}},
+			{Node: "node2", Address: "10.0.0.2", Port: 8081},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Resolver{Zones: zones}
+	for name, count := range map[string]int{
+		"web.service.consul":               2,
+		"web.service.dc1.consul":           2,
+		"primary.web.service.consul":       1,
+		"_web._tcp.service.consul":         2,
+		"_web._primary.service.consul":     1,
+		"_web._primary.service.dc1.consul": 1,
+	} {
+		_, srvs, err := r.LookupSRV(context.Background(), "", "", name)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(srvs) != count {
+			t.Errorf("Wrong amount of SRV records for %v, want %v, got %v", name, count, len(srvs))
+		}
+	}
+	addrs, err := r.LookupHost(context.Background(), "node2.node.consul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.0.0.2" {
+		t.Errorf("Wrong node address: %v", addrs)
+	}
+
+	srv := newTestServer(t, zones, func(s *Server) {
+		s.AdditionalSRV = true
+	})
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("web.service.consul.", dns.TypeSRV)
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Answer) != 2 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	if len(reply.Extra) != 2 {
+		t.Fatalf("Wrong additional section: %v", reply.Extra)
+	}
+	if a, ok := reply.Extra[0].(*dns.A); !ok || a.Hdr.Name != "node1.node.dc1.consul." || a.A.String() != "10.0.0.1" {
+		t.Errorf("Wrong additional record: %v", reply.Extra[0])
+	}
+}
@@ -0,0 +1,98 @@
+package mockdns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// clientCookieLen is the length of the hex-encoded client cookie (8 bytes).
+const clientCookieLen = 16
+
+// handleCookie adds the server cookie (RFC 7873) to the reply if the query
+// contains the client cookie and records queries that do not echo the server
+// cookie previously sent to the client. See Server.Cookies.
+func (s *Server) handleCookie(remote net.Addr, m, reply *dns.Msg) {
+	if !s.Cookies {
+		return
+	}
+	opt := m.IsEdns0()
+	if opt == nil {
+		return
+	}
+	var cookie string
+	for _, o := range opt.Option {
+		if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+			cookie = c.Cookie
+		}
+	}
+	if len(cookie) < clientCookieLen {
+		return
+	}
+	client := cookie[:clientCookieLen]
+	want := client + s.serverCookie(client, remote)
+
+	clientKey := remote.String()
+	if ip := addrIP(remote); ip != nil {
+		clientKey = ip.String()
+	}
+
+	s.mu.Lock()
+	if s.cookies == nil {
+		s.cookies = make(map[string]string)
+	}
+	if sent, ok := s.cookies[clientKey]; ok && sent == want && cookie != want {
+		var q dns.Question
+		if len(m.Question) != 0 {
+			q = m.Question[0]
+		}
+		s.cookieViolations = append(s.cookieViolations,
+			fmt.Errorf("query %s %v from %v: server cookie is not echoed (got %s, want %s)",
+				q.Name, dns.Type(q.Qtype), remote, cookie, want))
+	}
+	s.cookies[clientKey] = want
+	s.mu.Unlock()
+
+	replyOpt := replyOPT(reply)
+	replyOpt.Option = append(replyOpt.Option, &dns.EDNS0_COOKIE{
+		Code:   dns.EDNS0COOKIE,
+		Cookie: want,
+	})
+}
+
+// serverCookie returns the hex-encoded server cookie for the client cookie
+// and address.
+func (s *Server) serverCookie(client string, remote net.Addr) string {
+	s.mu.Lock()
+	if s.cookieSecret == nil {
+		s.cookieSecret = make([]byte, 16)
+		if _, err := rand.Read(s.cookieSecret); err != nil {
+			panic(err)
+		}
+	}
+	secret := s.cookieSecret
+	s.mu.Unlock()
+
+	h := fnv.New64a()
+	h.Write(secret)
+	h.Write([]byte(client))
+	if ip := addrIP(remote); ip != nil {
+		h.Write(ip)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CookieViolations returns the errors describing queries that did not echo
+// the server cookie sent to the same client with the same client cookie
+// before. Cookies must be enabled.
+func (s *Server) CookieViolations() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]error, len(s.cookieViolations))
+	copy(res, s.cookieViolations)
+	return res
+}
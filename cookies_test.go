@@ -0,0 +1,64 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_Cookies(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.Cookies = true
+	})
+	defer srv.Close()
+
+	const clientCookie = "0102030405060708"
+	query := func(cookie string) string {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opt := reply.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+					return c.Cookie
+				}
+			}
+		}
+		t.Fatal("No cookie in reply")
+		return ""
+	}
+
+	full := query(clientCookie)
+	if len(full) != 32 || full[:16] != clientCookie {
+		t.Fatalf("Wrong cookie in reply: %v", full)
+	}
+
+	// Correct echo.
+	if got := query(full); got != full {
+		t.Fatalf("Wrong cookie, want %v, got %v", full, got)
+	}
+	if v := srv.CookieViolations(); len(v) != 0 {
+		t.Fatalf("Unexpected violations: %v", v)
+	}
+
+	// Server cookie is dropped by the client.
+	query(clientCookie)
+	if v := srv.CookieViolations(); len(v) != 1 {
+		t.Fatalf("Wrong amount of violations, want %v, got %v", 1, len(v))
+	}
+
+	// New client cookie, e.g. after client restart, is not a violation.
+	query("1112131415161718")
+	if v := srv.CookieViolations(); len(v) != 1 {
+		t.Fatalf("Wrong amount of violations, want %v, got %v", 1, len(v))
+	}
+}
@@ -0,0 +1,92 @@
+package mockdns
+
+import (
+	"crypto/x509"
+	"errors"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// DANEParams specifies the TLSA record created by AddTLSA.
+type DANEParams struct {
+	// Usage, Selector and MatchingType are TLSA record fields, see RFC 6698.
+	// E.g. 3, 1, 1 for DANE-EE with SHA-256 of SubjectPublicKeyInfo.
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+
+	// Network is the transport protocol label, "tcp" if empty.
+	Network string
+
+	// Mismatch makes the certificate association data not match the
+	// certificate, to test validation failures.
+	Mismatch bool
+
+	// Insecure makes the TLSA record returned without the AD flag set, as
+	// if the zone was not signed.
+	Insecure bool
+}
+
+// AddTLSA adds the TLSA record for the certificate chain to zones and returns
+// the name it is added for ("_port._tcp.host.").
+//
+// The leaf certificate (chain[0]) is used for PKIX-EE (1) and DANE-EE (3)
+// usages, the last certificate of the chain is used for PKIX-TA (0) and
+// DANE-TA (2).
+func AddTLSA(zones map[string]Zone, host string, port int, chain []*x509.Certificate, params DANEParams) (string, error) {
+	if len(chain) == 0 {
+		return "", errors.New("empty certificate chain")
+	}
+
+	network := params.Network
+	if network == "" {
+		network = "tcp"
+	}
+	name, err := dns.TLSAName(dns.Fqdn(host), strconv.Itoa(port), network)
+	if err != nil {
+		return "", err
+	}
+	name = normalizeName(name)
+
+	cert := chain[0]
+	if params.Usage == 0 || params.Usage == 2 {
+		cert = chain[len(chain)-1]
+	}
+
+	rr := &dns.TLSA{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeTLSA,
+			Class:  dns.ClassINET,
+			Ttl:    defaultTTL,
+		},
+	}
+	if err := rr.Sign(int(params.Usage), int(params.Selector), int(params.MatchingType), cert); err != nil {
+		return "", err
+	}
+	if params.Mismatch {
+		rr.Certificate = corruptHex(rr.Certificate)
+	}
+
+	addMisc(zones, name, rr)
+	zone := zones[name]
+	zone.AD = !params.Insecure
+	zones[name] = zone
+
+	return name, nil
+}
+
+// corruptHex changes the last digit of the hex string.
+func corruptHex(s string) string {
+	if s == "" {
+		return s
+	}
+	last := s[len(s)-1]
+	if last == '0' {
+		last = '1'
+	} else {
+		last = '0'
+	}
+	return s[:len(s)-1] + string(last)
+}
@@ -0,0 +1,93 @@
+package mockdns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func testCert(t *testing.T) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mx.example.org"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestAddTLSA(t *testing.T) {
+	cert := testCert(t)
+	zones := map[string]Zone{}
+
+	name, err := AddTLSA(zones, "mx.example.org", 25, []*x509.Certificate{cert}, DANEParams{
+		Usage: 3, Selector: 1, MatchingType: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "_25._tcp.mx.example.org." {
+		t.Fatalf("Wrong name: %v", name)
+	}
+	badName, err := AddTLSA(zones, "bad.example.org", 25, []*x509.Certificate{cert}, DANEParams{
+		Usage: 3, Selector: 1, MatchingType: 1, Mismatch: true, Insecure: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	query := func(name string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeTLSA)
+		m.SetEdns0(4096, true)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reply.Answer) != 1 {
+			t.Fatalf("Wrong answer: %v", reply.Answer)
+		}
+		return reply
+	}
+
+	reply := query(name)
+	if !reply.AuthenticatedData {
+		t.Error("AD flag is not set")
+	}
+	if err := reply.Answer[0].(*dns.TLSA).Verify(cert); err != nil {
+		t.Errorf("TLSA record does not match the certificate: %v", err)
+	}
+
+	reply = query(badName)
+	if reply.AuthenticatedData {
+		t.Error("AD flag is set for insecure record")
+	}
+	if err := reply.Answer[0].(*dns.TLSA).Verify(cert); err == nil {
+		t.Error("Mismatched TLSA record matches the certificate")
+	}
+}
@@ -0,0 +1,72 @@
+package mockdns
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LatencyDist is the distribution of simulated latency, see Server.Latency.
+// It returns the delay using the provided random number generator.
+type LatencyDist func(rng *rand.Rand) time.Duration
+
+// FixedLatency returns the LatencyDist that always delays by d.
+func FixedLatency(d time.Duration) LatencyDist {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformLatency returns the LatencyDist with delays uniformly distributed
+// in [min, max).
+func UniformLatency(min, max time.Duration) LatencyDist {
+	return func(rng *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rng.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency returns the LatencyDist with normally distributed delays.
+// Negative values are treated as zero.
+func NormalLatency(mean, stddev time.Duration) LatencyDist {
+	return func(rng *rand.Rand) time.Duration {
+		d := time.Duration(rng.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// ExponentialLatency returns the LatencyDist with exponentially distributed
+// delays, which models occasional slow replies.
+func ExponentialLatency(mean time.Duration) LatencyDist {
+	return func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+}
+
+// simulatedLatency returns the delay to apply before sending the reply for
+// the query according to Zone.Latency and Server.Latency.
+func (s *Server) simulatedLatency(r *Resolver, qname string) time.Duration {
+	dist := s.Latency
+	if zone, ok := r.zone(qname); ok && zone.Latency != nil {
+		dist = zone.Latency
+	}
+	if dist == nil {
+		return 0
+	}
+
+	// Each delay is drawn from its own generator seeded from the shared one,
+	// so dist is not called with s.mu held and the sequence of delays still
+	// depends only on LatencySeed.
+	s.mu.Lock()
+	if s.latencyRand == nil {
+		s.latencyRand = rand.New(rand.NewSource(s.LatencySeed))
+	}
+	seed := s.latencyRand.Int63()
+	s.mu.Unlock()
+
+	return dist(rand.New(rand.NewSource(seed)))
+}
@@ -0,0 +1,76 @@
+package mockdns
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLatencyDist(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for name, c := range map[string]struct {
+		dist     LatencyDist
+		min, max time.Duration
+	}{
+		"fixed":       {FixedLatency(5 * time.Millisecond), 5 * time.Millisecond, 5 * time.Millisecond},
+		"uniform":     {UniformLatency(time.Millisecond, 2*time.Millisecond), time.Millisecond, 2 * time.Millisecond},
+		"normal":      {NormalLatency(10*time.Millisecond, 20*time.Millisecond), 0, time.Second},
+		"exponential": {ExponentialLatency(time.Millisecond), 0, time.Second},
+	} {
+		var sum time.Duration
+		for i := 0; i < 1000; i++ {
+			d := c.dist(rng)
+			if d < c.min || d > c.max {
+				t.Fatalf("%s: delay out of range: %v", name, d)
+			}
+			sum += d
+		}
+		if name == "exponential" {
+			if mean := sum / 1000; mean < 800*time.Microsecond || mean > 1200*time.Microsecond {
+				t.Errorf("%s: wrong mean, want ~%v, got %v", name, time.Millisecond, mean)
+			}
+		}
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	var self *Server
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+		"slow.example.org.": {
+			A:       []string{"192.0.2.2"},
+			Latency: FixedLatency(100 * time.Millisecond),
+		},
+		"reentrant.example.org.": {
+			A: []string{"192.0.2.3"},
+			// The distribution is called without locks held, so
+			// it can use the Server.
+			Latency: func(*rand.Rand) time.Duration {
+				self.Queries()
+				return 0
+			},
+		},
+	}, func(s *Server) {
+		self = s
+		s.Latency = UniformLatency(20*time.Millisecond, 30*time.Millisecond)
+	})
+	defer srv.Close()
+
+	timed := func(name string) time.Duration {
+		start := time.Now()
+		queryFrom(t, srv, "127.0.0.1", name, dns.TypeA)
+		return time.Since(start)
+	}
+
+	if d := timed("example.org."); d < 20*time.Millisecond {
+		t.Errorf("Server latency is not applied: %v", d)
+	}
+	if d := timed("slow.example.org."); d < 100*time.Millisecond {
+		t.Errorf("Zone latency is not applied: %v", d)
+	}
+	timed("reentrant.example.org.")
+}
@@ -0,0 +1,148 @@
+package mockdns
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneDelta is the difference between two sets of zones, see DiffZones.
+type ZoneDelta struct {
+	// Added contains records present only in the new zones.
+	Added []dns.RR
+	// Removed contains records present only in the old zones.
+	Removed []dns.RR
+	// Changed contains records present in both that differ only in TTL.
+	Changed []RRChange
+
+	// Flags contains names of zones present in both whose other fields (Err,
+	// AErr, AAAAErr, EDE, AD, NXDOMAIN, NegativeTTL, Sinkhole, FlattenCNAME
+	// or Regions) differ.
+	Flags []string
+}
+
+// RRChange is a record changed between two sets of zones.
+type RRChange struct {
+	Old, New dns.RR
+}
+
+// Empty reports whether there are no differences.
+func (d ZoneDelta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Flags) == 0
+}
+
+// String returns the difference in a diff-like form: added records are
+// prefixed with "+", removed with "-" and changed records and zones with
+// "~".
+func (d ZoneDelta) String() string {
+	var b strings.Builder
+	for _, rr := range d.Removed {
+		b.WriteString("-" + rr.String() + "\n")
+	}
+	for _, rr := range d.Added {
+		b.WriteString("+" + rr.String() + "\n")
+	}
+	for _, c := range d.Changed {
+		b.WriteString("~" + c.New.String() + "\n")
+	}
+	for _, name := range d.Flags {
+		b.WriteString("~" + name + " (zone flags)\n")
+	}
+	return b.String()
+}
+
+// DiffZones returns records added, removed and changed between the old and
+// new zones. Records are generated as Server would, so zones must be valid
+// (e.g. contain no malformed addresses). Results are sorted by record text.
+func DiffZones(old, new map[string]Zone) ZoneDelta {
+	oldRRs, oldZones := diffRecords(old)
+	newRRs, newZones := diffRecords(new)
+
+	var d ZoneDelta
+	for key, rr := range oldRRs {
+		newRR, ok := newRRs[key]
+		if !ok {
+			d.Removed = append(d.Removed, rr)
+		} else if newRR.Header().Ttl != rr.Header().Ttl {
+			d.Changed = append(d.Changed, RRChange{Old: rr, New: newRR})
+		}
+	}
+	for key, rr := range newRRs {
+		if _, ok := oldRRs[key]; !ok {
+			d.Added = append(d.Added, rr)
+		}
+	}
+	for name, oldZone := range oldZones {
+		newZone, ok := newZones[name]
+		if !ok {
+			continue
+		}
+		if !sameFlags(oldZone, newZone) {
+			d.Flags = append(d.Flags, name)
+		}
+	}
+
+	sortRRs(d.Added)
+	sortRRs(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return d.Changed[i].New.String() < d.Changed[j].New.String()
+	})
+	sort.Strings(d.Flags)
+	return d
+}
+
+func sameFlags(a, b Zone) bool {
+	if !sameErr(a.Err, b.Err) || !sameErr(a.AErr, b.AErr) || !sameErr(a.AAAAErr, b.AAAAErr) ||
+		a.AD != b.AD || a.NXDOMAIN != b.NXDOMAIN || a.NegativeTTL != b.NegativeTTL ||
+		a.Sinkhole != b.Sinkhole || a.FlattenCNAME != b.FlattenCNAME ||
+		len(a.EDE) != len(b.EDE) || !sameRegions(a.Regions, b.Regions) {
+		return false
+	}
+	for i := range a.EDE {
+		if a.EDE[i] != b.EDE[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffRecords returns all records of zones, keyed by their text with TTL
+// omitted and the owner name lowercased, and zones keyed by normalized
+// names. RDATA is compared as is, so changing its case is a change.
+func diffRecords(zones map[string]Zone) (map[string]dns.RR, map[string]Zone) {
+	rrs := make(map[string]dns.RR)
+	normalized := make(map[string]Zone, len(zones))
+
+	add := func(rr dns.RR) {
+		cpy := dns.Copy(rr)
+		cpy.Header().Ttl = 0
+		cpy.Header().Name = strings.ToLower(cpy.Header().Name)
+		rrs[cpy.String()] = rr
+	}
+	for name, zone := range zones {
+		name = normalizeName(name)
+		normalized[name] = zone
+
+		for _, typed := range compileZone(name, zone).byType {
+			for _, rr := range typed {
+				add(rr)
+			}
+		}
+		if zone.CNAME != "" {
+			add(mkCname(name, zone.CNAME, zone.ttl()))
+		}
+		for _, misc := range zone.Misc {
+			for _, rr := range misc {
+				add(rr)
+			}
+		}
+	}
+	return rrs, normalized
+}
+
+func sortRRs(rrs []dns.RR) {
+	sort.Slice(rrs, func(i, j int) bool {
+		return rrs[i].String() < rrs[j].String()
+	})
+}
@@ -0,0 +1,75 @@
+package mockdns
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestDiffZones(t *testing.T) {
+	old := map[string]Zone{
+		"example.org.": {
+			A:  []string{"192.0.2.1", "192.0.2.2"},
+			MX: []net.MX{{Host: "mx.example.org.", Pref: 10}},
+		},
+		"www.example.org.": {CNAME: "example.org."},
+		"old.example.org.": {TXT: []string{"old"}},
+	}
+	if d := DiffZones(old, old); !d.Empty() {
+		t.Fatalf("Non-empty diff for same zones: %v", d)
+	}
+
+	new := map[string]Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1", "192.0.2.3"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			TTL: 300,
+		},
+		"www.example.org": {CNAME: "example.org.", Err: errors.New("broken")},
+	}
+	d := DiffZones(old, new)
+
+	want := `-example.org.	9999	IN	A	192.0.2.2
+-old.example.org.	9999	IN	TXT	"old"
++example.org.	300	IN	A	192.0.2.3
+~example.org.	300	IN	A	192.0.2.1
+~example.org.	300	IN	MX	10 mx.example.org.
+~www.example.org. (zone flags)
+`
+	if got := d.String(); got != want {
+		t.Fatalf("Wrong result, want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestDiffZones_Case(t *testing.T) {
+	old := map[string]Zone{
+		"example.org.": {TXT: []string{"Hello"}},
+	}
+	if d := DiffZones(old, map[string]Zone{"EXAMPLE.org.": {TXT: []string{"Hello"}}}); !d.Empty() {
+		t.Fatalf("Non-empty diff for owner name case change: %v", d)
+	}
+
+	d := DiffZones(old, map[string]Zone{"example.org.": {TXT: []string{"hello"}}})
+	want := `-example.org.	9999	IN	TXT	"Hello"
++example.org.	9999	IN	TXT	"hello"
+`
+	if got := d.String(); got != want {
+		t.Fatalf("Wrong result, want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestDiffZones_Flags(t *testing.T) {
+	base := Zone{A: []string{"192.0.2.1"}}
+	for name, zone := range map[string]Zone{
+		"AErr":         {A: base.A, AErr: errors.New("broken")},
+		"AAAAErr":      {A: base.A, AAAAErr: errors.New("broken")},
+		"EDE":          {A: base.A, EDE: []ExtendedError{{Code: 1}}},
+		"FlattenCNAME": {A: base.A, FlattenCNAME: true},
+		"Regions":      {A: base.A, Regions: map[string]Zone{"eu": {A: []string{"192.0.2.2"}}}},
+	} {
+		d := DiffZones(map[string]Zone{"example.org.": base}, map[string]Zone{"example.org.": zone})
+		if len(d.Flags) != 1 {
+			t.Errorf("%s: Wrong result, want %v, got %v", name, []string{"example.org."}, d.Flags)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package mockdns
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DigLog returns the OnQuery callback that writes each served query and
+// the full reply in dig-like presentation format to w.
+//
+//	srv.OnQuery(mockdns.DigLog(os.Stderr))
+func DigLog(w io.Writer) func(QueryInfo) {
+	var lck sync.Mutex
+	return func(info QueryInfo) {
+		lck.Lock()
+		defer lck.Unlock()
+		io.WriteString(w, formatDig(info)+"\n")
+	}
+}
+
+// DigLogf is similar to DigLog but passes each entry to logf, which can be
+// the Logf method of testing.T.
+//
+//	srv.OnQuery(mockdns.DigLogf(t.Logf))
+func DigLogf(logf func(format string, args ...interface{})) func(QueryInfo) {
+	return func(info QueryInfo) {
+		logf("%s", formatDig(info))
+	}
+}
+
+func formatDig(info QueryInfo) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, ";; <<>> mockdns <<>> %s %s", info.Question.Name, dns.Type(info.Question.Qtype))
+	if info.RemoteAddr != nil {
+		fmt.Fprintf(&b, " from %v", info.RemoteAddr)
+	}
+	b.WriteString("\n")
+	if info.Reply != nil {
+		b.WriteString(";; Got answer:\n")
+		b.WriteString(strings.TrimRight(info.Reply.String(), "\n"))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package mockdns
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// lockedBuffer is bytes.Buffer safe to write from server goroutines.
+type lockedBuffer struct {
+	lck sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+	return b.buf.String()
+}
+
+func TestDigLog(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var buf lockedBuffer
+	srv.OnQuery(DigLog(&buf))
+	srv.OnQuery(DigLogf(t.Logf))
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.org.", dns.TypeA)
+	cl := dns.Client{}
+	if _, _, err := cl.Exchange(msg, srv.LocalAddr().String()); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		";; <<>> mockdns <<>> example.org. A from 127.0.0.1:",
+		"status: NOERROR",
+		";; ANSWER SECTION:",
+		"example.org.\t9999\tIN\tA\t1.2.3.4",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Log output does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DNSBLListing describes the listing of an address in DNSBL.
+type DNSBLListing struct {
+	// Codes are last octets of 127.0.0.x addresses returned for the listed
+	// address, e.g. 2 for Spamhaus SBL or 4 for XBL. Defaults to 2.
+	Codes []uint8
+
+	// Reason, if not empty, is returned as the TXT record.
+	Reason string
+}
+
+// AddDNSBL adds zones for the DNS blocklist with the specified domain (e.g.
+// "zen.spamhaus.org"). listed keys are IPv4 or IPv6 addresses, they are
+// listed using the reversed address convention (RFC 5782), e.g. 192.0.2.1
+// becomes 1.2.0.192.zen.spamhaus.org.
+//
+// Additionally the mandatory test entries (127.0.0.2 listed and 127.0.0.1
+// not listed) are added unless listed says otherwise.
+func AddDNSBL(zones map[string]Zone, list string, listed map[string]DNSBLListing) error {
+	list = strings.TrimSuffix(normalizeName(list), ".")
+
+	if _, ok := listed["127.0.0.2"]; !ok {
+		addDNSBLEntry(zones, "2.0.0.127."+list, DNSBLListing{Reason: "test entry"})
+	}
+
+	for addr, listing := range listed {
+		name, err := dnsblName(addr, list)
+		if err != nil {
+			return err
+		}
+		addDNSBLEntry(zones, name, listing)
+	}
+
+	return nil
+}
+
+func addDNSBLEntry(zones map[string]Zone, name string, listing DNSBLListing) {
+	codes := listing.Codes
+	if len(codes) == 0 {
+		codes = []uint8{2}
+	}
+
+	zone := Zone{
+		A: make([]string, 0, len(codes)),
+	}
+	for _, code := range codes {
+		zone.A = append(zone.A, "127.0.0."+strconv.Itoa(int(code)))
+	}
+	if listing.Reason != "" {
+		zone.TXT = []string{listing.Reason}
+	}
+	zones[normalizeName(name)] = zone
+}
+
+// dnsblName returns the DNSBL name to query for the address.
+func dnsblName(addr, list string) (string, error) {
+	arpa := ReverseName(net.ParseIP(addr))
+	if arpa == "" {
+		return "", fmt.Errorf("malformed address: %v", addr)
+	}
+	arpa = strings.TrimSuffix(arpa, "in-addr.arpa.")
+	arpa = strings.TrimSuffix(arpa, "ip6.arpa.")
+	return arpa + list + ".", nil
+}
@@ -0,0 +1,53 @@
+package mockdns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestAddDNSBL(t *testing.T) {
+	zones := map[string]Zone{}
+	err := AddDNSBL(zones, "zen.example.org", map[string]DNSBLListing{
+		"192.0.2.1":   {Codes: []uint8{2, 4}, Reason: "https://example.org/query/192.0.2.1"},
+		"2001:db8::1": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Resolver{Zones: zones}
+
+	addrs, err := r.LookupHost(context.Background(), "1.2.0.192.zen.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.2", "127.0.0.4"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, addrs)
+	}
+	txt, err := r.LookupTXT(context.Background(), "1.2.0.192.zen.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://example.org/query/192.0.2.1"}; !reflect.DeepEqual(txt, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, txt)
+	}
+
+	addrs, err = r.LookupHost(context.Background(), "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.zen.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.2"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	if _, err := r.LookupHost(context.Background(), "2.0.0.127.zen.example.org"); err != nil {
+		t.Errorf("Test entry is missing: %v", err)
+	}
+	if _, err := r.LookupHost(context.Background(), "1.0.0.127.zen.example.org"); err == nil {
+		t.Error("127.0.0.1 is listed")
+	}
+
+	if err := AddDNSBL(zones, "zen.example.org", map[string]DNSBLListing{"bad": {}}); err == nil {
+		t.Error("Expected error for malformed address")
+	}
+}
@@ -0,0 +1,140 @@
+package mockdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSDInstance is the DNS-SD (RFC 6763) service instance, see AddDNSSD.
+type DNSSDInstance struct {
+	// Instance is the user-visible instance name, e.g. "Office Printer". It
+	// may contain any characters, including dots and spaces.
+	Instance string
+
+	// Service is the service type, e.g. "_http._tcp".
+	Service string
+
+	// Domain is the domain services are browsed in, e.g. "example.org".
+	Domain string
+
+	// Host and Port are the SRV record target. Host should have addresses
+	// defined separately.
+	Host     string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+
+	// Text contains key/value pairs for the TXT record. Keys with empty
+	// values are added as boolean attributes (just "key"). Pairs are sorted
+	// by key.
+	Text map[string]string
+}
+
+// AddDNSSD adds records for the DNS-SD service instance to zones:
+//
+//   - PTR record <service>.<domain> -> <instance>.<service>.<domain>,
+//   - SRV and TXT records for <instance>.<service>.<domain>,
+//   - PTR record _services._dns-sd._udp.<domain> -> <service>.<domain> for
+//     service type enumeration.
+//
+// The instance name in presentation format (with special characters
+// escaped, as miekg/dns clients see it) is returned. Since it is generally not
+// a valid host name, net.Resolver refuses to look it up, use Server with
+// miekg/dns client to query it.
+func AddDNSSD(zones map[string]Zone, inst DNSSDInstance) (string, error) {
+	if inst.Instance == "" || inst.Service == "" || inst.Domain == "" {
+		return "", errors.New("instance, service and domain are required")
+	}
+	if len(inst.Instance) > 63 {
+		return "", fmt.Errorf("instance name is too long: %v", inst.Instance)
+	}
+
+	serviceName := normalizeName(strings.Trim(inst.Service, ".") + "." + strings.Trim(inst.Domain, "."))
+	// Instance names are case-preserving, so PTR records point to the name
+	// as is, while the zone key is normalized.
+	instName := escapeLabel(inst.Instance) + "." + serviceName
+	instKey := normalizeName(instName)
+
+	ptrZone := zones[serviceName]
+	ptrZone.PTR = append(ptrZone.PTR, instName)
+	zones[serviceName] = ptrZone
+
+	enumName := normalizeName("_services._dns-sd._udp." + strings.Trim(inst.Domain, "."))
+	enumZone := zones[enumName]
+	if !containsString(enumZone.PTR, serviceName) {
+		enumZone.PTR = append(enumZone.PTR, serviceName)
+	}
+	zones[enumName] = enumZone
+
+	keys := make([]string, 0, len(inst.Text))
+	for k := range inst.Text {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	txt := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := inst.Text[k]; v != "" {
+			txt = append(txt, k+"="+v)
+		} else {
+			txt = append(txt, k)
+		}
+	}
+
+	instZone := zones[instKey]
+	instZone.SRV = append(instZone.SRV, net.SRV{
+		Target:   dns.Fqdn(inst.Host),
+		Port:     inst.Port,
+		Priority: inst.Priority,
+		Weight:   inst.Weight,
+	})
+	// RFC 6763 requires the TXT record to be present even if there are no
+	// attributes. Misc is used to keep pairs as separate character-strings.
+	if len(txt) == 0 {
+		txt = []string{""}
+	}
+	zones[instKey] = instZone
+	addMisc(zones, instKey, &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   instKey,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    defaultTTL,
+		},
+		Txt: txt,
+	})
+
+	return instName, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLabel escapes special characters of the DNS label as miekg/dns does
+// in presentation format.
+func escapeLabel(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		switch {
+		case c == '.' || c == ' ' || c == '\'' || c == '@' || c == ';' || c == '(' || c == ')' || c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAddDNSSD(t *testing.T) {
+	zones := map[string]Zone{
+		"printer.example.org.": {A: []string{"192.0.2.10"}},
+	}
+	name, err := AddDNSSD(zones, DNSSDInstance{
+		Instance: "Office Printer 2.0",
+		Service:  "_ipp._tcp",
+		Domain:   "example.org",
+		Host:     "printer.example.org",
+		Port:     631,
+		Text:     map[string]string{"txtvers": "1", "rp": "ipp/print", "Color": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `Office\ Printer\ 2\.0._ipp._tcp.example.org.`; name != want {
+		t.Fatalf("Wrong name, want %v, got %v", want, name)
+	}
+
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	query := func(name string, qtype uint16) []dns.RR {
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Answer
+	}
+
+	ptrs := query("_ipp._tcp.example.org.", dns.TypePTR)
+	if len(ptrs) != 1 {
+		t.Fatalf("Wrong PTR answer: %v", ptrs)
+	}
+	// Browse the service using the name returned in PTR, the way clients
+	// do.
+	inst := ptrs[0].(*dns.PTR).Ptr
+	if inst != name {
+		t.Fatalf("Wrong PTR target, want %v, got %v", name, inst)
+	}
+
+	srvs := query(inst, dns.TypeSRV)
+	if len(srvs) != 1 || srvs[0].(*dns.SRV).Port != 631 || srvs[0].(*dns.SRV).Target != "printer.example.org." {
+		t.Fatalf("Wrong SRV answer: %v", srvs)
+	}
+
+	txts := query(inst, dns.TypeTXT)
+	if len(txts) != 1 {
+		t.Fatalf("Wrong TXT answer: %v", txts)
+	}
+	if want := []string{"Color", "rp=ipp/print", "txtvers=1"}; !reflect.DeepEqual(txts[0].(*dns.TXT).Txt, want) {
+		t.Errorf("Wrong TXT record, want %v, got %v", want, txts[0].(*dns.TXT).Txt)
+	}
+
+	types := query("_services._dns-sd._udp.example.org.", dns.TypePTR)
+	if len(types) != 1 || types[0].(*dns.PTR).Ptr != "_ipp._tcp.example.org." {
+		t.Errorf("Wrong service enumeration answer: %v", types)
+	}
+}
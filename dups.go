@@ -0,0 +1,96 @@
+package mockdns
+
+import (
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DuplicateQuery is a group of identical queries from the same client, see
+// Server.DuplicateQueries.
+type DuplicateQuery struct {
+	// Client is the IP address of the client.
+	Client   string
+	Question dns.Question
+
+	// Times contains times queries were received at, in order. Intervals
+	// between them show the retransmission schedule of the client.
+	Times []time.Time
+
+	// IDs contains message IDs of queries, in the same order. Same IDs
+	// indicate retransmissions of the same query rather than new lookups.
+	IDs []uint16
+}
+
+// SameID reports whether all queries in the group have the same message ID.
+func (d DuplicateQuery) SameID() bool {
+	for _, id := range d.IDs {
+		if id != d.IDs[0] {
+			return false
+		}
+	}
+	return true
+}
+
+type dupKey struct {
+	client string
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// DuplicateQueries returns groups of queries for the same question from the
+// same client, each received within window from the previous one. Names are
+// compared case-insensitively. Groups are sorted by the time of the first
+// query.
+func (s *Server) DuplicateQueries(window time.Duration) []DuplicateQuery {
+	queries := s.Queries()
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].Time.Before(queries[j].Time)
+	})
+
+	var (
+		groups []DuplicateQuery
+		open   = make(map[dupKey]int)
+	)
+	for _, q := range queries {
+		if q.Query == nil || len(q.Query.Question) == 0 {
+			continue
+		}
+		client := ""
+		if ip := addrIP(q.RemoteAddr); ip != nil {
+			client = ip.String()
+		}
+		key := dupKey{
+			client: client,
+			name:   normalizeName(q.Question.Name),
+			qtype:  q.Question.Qtype,
+			qclass: q.Question.Qclass,
+		}
+
+		if i, ok := open[key]; ok {
+			g := &groups[i]
+			if q.Time.Sub(g.Times[len(g.Times)-1]) <= window {
+				g.Times = append(g.Times, q.Time)
+				g.IDs = append(g.IDs, q.Query.Id)
+				continue
+			}
+		}
+		open[key] = len(groups)
+		groups = append(groups, DuplicateQuery{
+			Client:   client,
+			Question: q.Question,
+			Times:    []time.Time{q.Time},
+			IDs:      []uint16{q.Query.Id},
+		})
+	}
+
+	dups := groups[:0]
+	for _, g := range groups {
+		if len(g.Times) > 1 {
+			dups = append(dups, g)
+		}
+	}
+	return dups
+}
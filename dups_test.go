@@ -0,0 +1,51 @@
+package mockdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_DuplicateQueries(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	send := func(name string, id uint16) {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		m.Id = id
+		if _, err := dns.Exchange(m, srv.LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("example.org.", 1)
+	send("Example.ORG.", 1)
+	send("example.org.", 2)
+	send("other.example.org.", 3)
+	time.Sleep(100 * time.Millisecond)
+	send("example.org.", 4)
+
+	dups := srv.DuplicateQueries(50 * time.Millisecond)
+	if len(dups) != 1 {
+		t.Fatalf("Wrong amount of groups, want %v, got %v: %v", 1, len(dups), dups)
+	}
+	d := dups[0]
+	if d.Client != "127.0.0.1" || d.Question.Name != "example.org." || len(d.Times) != 3 {
+		t.Fatalf("Wrong group: %+v", d)
+	}
+	if d.SameID() {
+		t.Fatal("SameID is true for queries with different IDs")
+	}
+
+	if dups := srv.DuplicateQueries(time.Second); len(dups) != 1 || len(dups[0].Times) != 4 {
+		t.Fatalf("Wrong groups for larger window: %v", dups)
+	}
+}
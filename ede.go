@@ -0,0 +1,84 @@
+package mockdns
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// ednsUDPSize is the UDP payload size advertised in OPT records added to
+// replies.
+const ednsUDPSize = 1232
+
+// ExtendedError is the Extended DNS Error (RFC 8914) sent by Server, see
+// Zone.EDE.
+type ExtendedError struct {
+	// Code is the INFO-CODE, one of dns.ExtendedErrorCode* constants.
+	Code uint16
+	// Text is the optional EXTRA-TEXT.
+	Text string
+}
+
+// Error implements error, so ExtendedError can be used as Zone.Err to make
+// Server reply with SERVFAIL and the EDE. Resolver returns it as is.
+func (e *ExtendedError) Error() string {
+	code, ok := dns.ExtendedErrorCodeToString[e.Code]
+	if !ok {
+		code = strconv.Itoa(int(e.Code))
+	}
+	if e.Text == "" {
+		return "extended DNS error: " + code
+	}
+	return "extended DNS error: " + code + ": " + e.Text
+}
+
+// edeFor returns the Extended DNS Error describing the lookup error, if
+// there is a fitting one.
+func edeFor(err error) (ExtendedError, bool) {
+	switch err := err.(type) {
+	case *ExtendedError:
+		return *err, true
+	case *net.DNSError:
+		if err.IsTimeout {
+			return ExtendedError{Code: dns.ExtendedErrorCodeNetworkError}, true
+		}
+	}
+	return ExtendedError{}, false
+}
+
+// attachEDE adds Extended DNS Errors configured for qname to the reply and
+// reports whether there are any.
+func (s *Server) attachEDE(reply, m *dns.Msg, r *Resolver, qname string) bool {
+	zone, ok := r.zone(qname)
+	if !ok || len(zone.EDE) == 0 {
+		return false
+	}
+	addEDE(reply, m, zone.EDE...)
+	return true
+}
+
+// addEDE adds Extended DNS Errors to the reply. It does nothing if the query
+// does not use EDNS.
+func addEDE(reply, m *dns.Msg, edes ...ExtendedError) {
+	if m.IsEdns0() == nil {
+		return
+	}
+
+	opt := replyOPT(reply)
+	for _, ede := range edes {
+		opt.Option = append(opt.Option, &dns.EDNS0_EDE{
+			InfoCode:  ede.Code,
+			ExtraText: ede.Text,
+		})
+	}
+}
+
+// replyOPT returns the OPT record of the reply, adding it if needed.
+func replyOPT(reply *dns.Msg) *dns.OPT {
+	if opt := reply.IsEdns0(); opt != nil {
+		return opt
+	}
+	reply.SetEdns0(ednsUDPSize, false)
+	return reply.IsEdns0()
+}
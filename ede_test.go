@@ -0,0 +1,141 @@
+package mockdns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_EDE(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"blocked.example.org.": {
+			Sinkhole: true,
+			EDE:      []ExtendedError{{Code: dns.ExtendedErrorCodeBlocked, Text: "blocked by policy"}},
+		},
+		"stale.example.org.": {
+			A:   []string{"192.0.2.1"},
+			EDE: []ExtendedError{{Code: dns.ExtendedErrorCodeStaleAnswer}},
+		},
+		"bogus.example.org.": {
+			Err: errors.New("validation failure"),
+			EDE: []ExtendedError{{Code: dns.ExtendedErrorCodeDNSBogus}},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	query := func(name string, edns bool) *dns.Msg {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if edns {
+			m.SetEdns0(4096, false)
+		}
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+	ede := func(reply *dns.Msg) *dns.EDNS0_EDE {
+		t.Helper()
+		opt := reply.IsEdns0()
+		if opt == nil {
+			t.Fatal("No OPT record in reply")
+		}
+		for _, o := range opt.Option {
+			if ede, ok := o.(*dns.EDNS0_EDE); ok {
+				return ede
+			}
+		}
+		t.Fatal("No EDE option in reply")
+		return nil
+	}
+
+	reply := query("blocked.example.org.", true)
+	if e := ede(reply); e.InfoCode != dns.ExtendedErrorCodeBlocked || e.ExtraText != "blocked by policy" {
+		t.Fatalf("Wrong EDE: %v", e)
+	}
+
+	reply = query("stale.example.org.", true)
+	if e := ede(reply); e.InfoCode != dns.ExtendedErrorCodeStaleAnswer || len(reply.Answer) != 1 {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+
+	reply = query("bogus.example.org.", true)
+	if e := ede(reply); e.InfoCode != dns.ExtendedErrorCodeDNSBogus || reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+
+	if reply := query("stale.example.org.", false); reply.IsEdns0() != nil {
+		t.Fatal("OPT record is sent for non-EDNS query")
+	}
+}
+
+func TestServer_EDEFromErr(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"bogus.example.org.": {
+			Err: &ExtendedError{Code: dns.ExtendedErrorCodeDNSBogus, Text: "signature mismatch"},
+		},
+		"timeout.example.org.": {
+			Err: Timeout("timeout.example.org."),
+		},
+		"v6timeout.example.org.": {
+			A:       []string{"192.0.2.1"},
+			AAAAErr: Timeout("v6timeout.example.org."),
+		},
+		"other.example.org.": {
+			Err: errors.New("other"),
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	for _, c := range []struct {
+		name  string
+		qtype uint16
+		code  int
+	}{
+		{"bogus.example.org.", dns.TypeA, int(dns.ExtendedErrorCodeDNSBogus)},
+		{"timeout.example.org.", dns.TypeA, int(dns.ExtendedErrorCodeNetworkError)},
+		{"v6timeout.example.org.", dns.TypeAAAA, int(dns.ExtendedErrorCodeNetworkError)},
+		{"other.example.org.", dns.TypeA, -1},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(c.name, c.qtype)
+		m.SetEdns0(4096, false)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.Rcode != dns.RcodeServerFailure {
+			t.Errorf("%s: wrong rcode, want %v, got %v", c.name, dns.RcodeToString[dns.RcodeServerFailure], dns.RcodeToString[reply.Rcode])
+		}
+
+		code := -1
+		if opt := reply.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if ede, ok := o.(*dns.EDNS0_EDE); ok {
+					code = int(ede.InfoCode)
+				}
+			}
+		}
+		if code != c.code {
+			t.Errorf("%s: wrong EDE code, want %v, got %v", c.name, c.code, code)
+		}
+	}
+
+	r := Resolver{Zones: map[string]Zone{
+		"bogus.example.org.": {Err: &ExtendedError{Code: dns.ExtendedErrorCodeDNSBogus, Text: "signature mismatch"}},
+	}}
+	_, err = r.LookupHost(context.Background(), "bogus.example.org")
+	if want := "extended DNS error: DNSSEC Bogus: signature mismatch"; err == nil || err.Error() != want {
+		t.Fatalf("Wrong error, want %v, got %v", want, err)
+	}
+}
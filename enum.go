@@ -0,0 +1,85 @@
+package mockdns
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ENUMEntry is the single NAPTR record of the ENUM (RFC 6116) domain.
+type ENUMEntry struct {
+	Order      uint16
+	Preference uint16
+
+	// Service is the ENUM service, e.g. "E2U+sip" or "E2U+email:mailto".
+	Service string
+
+	// URI is the URI the number is mapped to, e.g.
+	// "sip:+441234567890@example.org" or "mailto:info@example.org".
+	URI string
+}
+
+// E164Name returns the e164.arpa domain name for the phone number in E.164
+// format. Separators (spaces, dashes, dots and parentheses) are ignored.
+func E164Name(number string) (string, error) {
+	number = strings.TrimPrefix(strings.TrimSpace(number), "+")
+
+	digits := make([]byte, 0, len(number))
+	for i := 0; i < len(number); i++ {
+		switch c := number[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", errors.New("malformed phone number")
+		}
+	}
+	if len(digits) == 0 || len(digits) > 15 {
+		return "", errors.New("malformed phone number")
+	}
+
+	var name strings.Builder
+	for i := len(digits) - 1; i >= 0; i-- {
+		name.WriteByte(digits[i])
+		name.WriteByte('.')
+	}
+	name.WriteString("e164.arpa.")
+	return name.String(), nil
+}
+
+// AddENUM adds NAPTR records mapping the phone number to entries URIs into
+// zones. The e164.arpa name used is returned.
+//
+// Records are added as terminal rules ("u" flag) with the regular expression
+// replacing the whole application unique string with the URI.
+func AddENUM(zones map[string]Zone, number string, entries ...ENUMEntry) (string, error) {
+	name, err := E164Name(number)
+	if err != nil {
+		return "", err
+	}
+
+	rrs := make([]dns.RR, 0, len(entries))
+	for _, e := range entries {
+		if strings.Contains(e.URI, "!") {
+			return "", errors.New("URI cannot contain '!'")
+		}
+		rrs = append(rrs, &dns.NAPTR{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypeNAPTR,
+				Class:  dns.ClassINET,
+				Ttl:    defaultTTL,
+			},
+			Order:       e.Order,
+			Preference:  e.Preference,
+			Flags:       "u",
+			Service:     e.Service,
+			Regexp:      "!^.*$!" + e.URI + "!",
+			Replacement: ".",
+		})
+	}
+	addMisc(zones, name, rrs...)
+
+	return name, nil
+}
@@ -0,0 +1,57 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestE164Name(t *testing.T) {
+	name, err := E164Name("+44 (20) 7946-0018")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "8.1.0.0.6.4.9.7.0.2.4.4.e164.arpa."; name != want {
+		t.Fatalf("Wrong result, want %v, got %v", want, name)
+	}
+
+	for _, bad := range []string{"", "+", "+44abc", "1234567890123456"} {
+		if _, err := E164Name(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestAddENUM(t *testing.T) {
+	zones := map[string]Zone{}
+	name, err := AddENUM(zones, "+441234",
+		ENUMEntry{Order: 10, Preference: 10, Service: "E2U+sip", URI: "sip:+441234@example.org"},
+		ENUMEntry{Order: 10, Preference: 20, Service: "E2U+email:mailto", URI: "mailto:info@example.org"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeNAPTR)
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Answer) != 2 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	naptr, ok := reply.Answer[0].(*dns.NAPTR)
+	if !ok {
+		t.Fatalf("Not a NAPTR record: %v", reply.Answer[0])
+	}
+	if naptr.Service != "E2U+sip" || naptr.Regexp != "!^.*$!sip:+441234@example.org!" || naptr.Flags != "u" {
+		t.Fatalf("Wrong NAPTR record: %v", naptr)
+	}
+}
@@ -0,0 +1,105 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// defaultErrServer is the DNSError.Server value of errors returned by
+// Resolver.
+const defaultErrServer = "127.0.0.1:53"
+
+// NotFound returns the error returned by net.Resolver for names that do not
+// exist (NXDOMAIN). Using it in Zone.Err is equivalent to setting
+// Zone.NXDOMAIN: Resolver lookups fail with it and Server replies with
+// NXDOMAIN.
+func NotFound(name string) error {
+	return notFound(name)
+}
+
+// Timeout returns the error returned by net.Resolver when the server does
+// not reply in time.
+func Timeout(name string) error {
+	return &net.DNSError{
+		Err:         "i/o timeout",
+		Name:        name,
+		Server:      defaultErrServer,
+		IsTimeout:   true,
+		IsTemporary: true,
+	}
+}
+
+// Temporary returns the error returned by net.Resolver when the server
+// fails to answer the query (SERVFAIL).
+func Temporary(name string) error {
+	return &net.DNSError{
+		Err:         "server misbehaving",
+		Name:        name,
+		Server:      defaultErrServer,
+		IsTemporary: true,
+	}
+}
+
+// errServer sets DNSError.Server of err to Resolver.ErrServer, if it is set.
+// Errors with Server set to something other than the default are returned as
+// is. err is copied, not modified.
+func (r *Resolver) errServer(err error) error {
+	if r.ErrServer == "" {
+		return err
+	}
+	dnsErr, ok := err.(*net.DNSError)
+	if !ok || (dnsErr.Server != "" && dnsErr.Server != defaultErrServer) {
+		return err
+	}
+	cpy := *dnsErr
+	cpy.Server = r.ErrServer
+	return &cpy
+}
+
+// defaultMaxCNAMEDepth is used if Resolver.MaxCNAMEDepth is zero.
+const defaultMaxCNAMEDepth = 16
+
+// CNAMEError is returned by Resolver lookups if the CNAME chain loops or is
+// longer than Resolver.MaxCNAMEDepth. Server replies with SERVFAIL in both
+// cases.
+type CNAMEError struct {
+	// Name is the queried name.
+	Name string
+
+	// Chain contains names followed so far, starting with Name. For loops,
+	// the last name is the one seen before.
+	Chain []string
+
+	// Loop is true if the chain loops and false if it is too long.
+	Loop bool
+}
+
+func (e *CNAMEError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("CNAME loop for %s: %s", e.Name, strings.Join(e.Chain, " -> "))
+	}
+	return fmt.Sprintf("CNAME chain for %s is too long (%d names)", e.Name, len(e.Chain))
+}
+
+func (r *Resolver) maxCNAMEDepth() int {
+	if r.MaxCNAMEDepth == 0 {
+		return defaultMaxCNAMEDepth
+	}
+	return r.MaxCNAMEDepth
+}
+
+// checkCNAMEChain returns *CNAMEError if following the CNAME to target after
+// chain would loop or exceed MaxCNAMEDepth.
+func (r *Resolver) checkCNAMEChain(chain []string, target string) error {
+	for _, name := range chain {
+		if name == target {
+			return &CNAMEError{Name: chain[0], Chain: append(append([]string(nil), chain...), target), Loop: true}
+		}
+	}
+
+	if len(chain) > r.maxCNAMEDepth() {
+		return &CNAMEError{Name: chain[0], Chain: append([]string(nil), chain...)}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestErrorHelpers(t *testing.T) {
+	r := Resolver{
+		Zones: map[string]Zone{
+			"timeout.example.org.":   {Err: Timeout("timeout.example.org")},
+			"temporary.example.org.": {Err: Temporary("temporary.example.org")},
+			"missing.example.org.":   {Err: NotFound("missing.example.org")},
+		},
+	}
+
+	check := func(name string, timeout, temporary, notFound bool) {
+		t.Helper()
+		_, err := r.LookupHost(context.Background(), name)
+		dnsErr, ok := err.(*net.DNSError)
+		if !ok {
+			t.Fatalf("%s: Wrong error type: %T", name, err)
+		}
+		if dnsErr.Timeout() != timeout || dnsErr.Temporary() != temporary || isNotFound(dnsErr) != notFound {
+			t.Errorf("%s: Wrong error: %#v", name, dnsErr)
+		}
+		if dnsErr.Name != name {
+			t.Errorf("%s: Wrong name: %v", name, dnsErr.Name)
+		}
+	}
+	check("timeout.example.org", true, true, false)
+	check("temporary.example.org", false, true, false)
+	check("missing.example.org", false, false, true)
+}
+
+func TestServer_NotFoundErr(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"missing.example.org.": {Err: NotFound("missing.example.org")},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeNameError {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeNameError, reply.Rcode)
+	}
+	if len(reply.Ns) != 1 || reply.Ns[0].Header().Name != "missing.example.org." {
+		t.Fatalf("Wrong authority section: %v", reply.Ns)
+	}
+}
+
+func TestResolver_ErrServer(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{}}
+	_, err := r.LookupHost(context.Background(), "example.org")
+	if dnsErr := err.(*net.DNSError); dnsErr.Server != "127.0.0.1:53" {
+		t.Fatalf("Wrong result, want %v, got %v", "127.0.0.1:53", dnsErr.Server)
+	}
+
+	r.ErrServer = "mockdns"
+	_, err = r.LookupTXT(context.Background(), "example.org")
+	if dnsErr := err.(*net.DNSError); dnsErr.Server != "mockdns" {
+		t.Fatalf("Wrong result, want %v, got %v", "mockdns", dnsErr.Server)
+	}
+
+	srv, err := NewServer(map[string]Zone{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	_, err = srv.Resolver().LookupMX(context.Background(), "example.org")
+	if dnsErr := err.(*net.DNSError); dnsErr.Server != srv.LocalAddr().String() {
+		t.Fatalf("Wrong result, want %v, got %v", srv.LocalAddr(), dnsErr.Server)
+	}
+}
+
+func TestResolver_CNAMEError(t *testing.T) {
+	r := Resolver{
+		Zones: map[string]Zone{
+			"a.example.org.": {CNAME: "b.example.org."},
+			"b.example.org.": {CNAME: "a.example.org."},
+			"1.example.org.": {CNAME: "2.example.org."},
+			"2.example.org.": {CNAME: "3.example.org."},
+			"3.example.org.": {A: []string{"192.0.2.1"}},
+		},
+	}
+
+	_, err := r.LookupHost(context.Background(), "a.example.org")
+	cnameErr, ok := err.(*CNAMEError)
+	if !ok {
+		t.Fatalf("Wrong error type: %T (%v)", err, err)
+	}
+	if !cnameErr.Loop || len(cnameErr.Chain) != 3 {
+		t.Fatalf("Wrong error: %#v", cnameErr)
+	}
+
+	if _, err := r.LookupHost(context.Background(), "1.example.org"); err != nil {
+		t.Fatal(err)
+	}
+	r.MaxCNAMEDepth = 1
+	_, err = r.LookupHost(context.Background(), "1.example.org")
+	if cnameErr, ok := err.(*CNAMEError); !ok || cnameErr.Loop {
+		t.Fatalf("Wrong error: %#v", err)
+	}
+}
+
+func TestServer_CNAMELoop(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"a.example.org.": {CNAME: "b.example.org."},
+		"b.example.org.": {CNAME: "a.example.org."},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "a.example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, reply.Rcode)
+	}
+}
+
+func TestResolver_PartialAnswer(t *testing.T) {
+	r := Resolver{
+		Zones: map[string]Zone{
+			"example.org.": {
+				A:       []string{"192.0.2.1"},
+				AAAA:    []string{"2001:db8::1"},
+				AAAAErr: Timeout("example.org"),
+			},
+		},
+	}
+
+	addrs, err := r.LookupHost(context.Background(), "example.org")
+	if err == nil || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Fatalf("Wrong result: %v, %v", addrs, err)
+	}
+	ips, err := r.LookupIPAddr(context.Background(), "example.org")
+	if err == nil || len(ips) != 1 {
+		t.Fatalf("Wrong result: %v, %v", ips, err)
+	}
+	if _, err := r.LookupIP(context.Background(), "ip4", "example.org"); err != nil {
+		t.Fatal("Unexpected error for IPv4-only lookup:", err)
+	}
+	if ips, err := r.LookupIP(context.Background(), "ip6", "example.org"); err == nil || len(ips) != 0 {
+		t.Fatalf("Wrong result: %v, %v", ips, err)
+	}
+
+	srv, err := NewServer(r.Zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA); len(reply.Answer) != 1 {
+		t.Fatalf("Wrong answer: %v", reply)
+	}
+	if reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeAAAA); reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, reply.Rcode)
+	}
+}
@@ -0,0 +1,42 @@
+package mockdns
+
+import (
+	"expvar"
+
+	"github.com/miekg/dns"
+)
+
+// PublishExpvar publishes counters of served queries via the expvar package.
+// Two maps are published: prefix + ".queries" with query counts by question
+// type and prefix + ".rcodes" with reply counts by response code.
+//
+// expvar variables cannot be removed once published, so if the maps with
+// these names already exist (e.g. published by another Server), they are
+// reused and counters are shared.
+func (s *Server) PublishExpvar(prefix string) {
+	queries := expvarMap(prefix + ".queries")
+	rcodes := expvarMap(prefix + ".rcodes")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expQueries = queries
+	s.expRcodes = rcodes
+}
+
+func expvarMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	return expvar.NewMap(name)
+}
+
+func updateExpvar(queries, rcodes *expvar.Map, m, reply *dns.Msg) {
+	if queries != nil {
+		for _, q := range m.Question {
+			queries.Add(dns.Type(q.Qtype).String(), 1)
+		}
+	}
+	if rcodes != nil {
+		rcodes.Add(dns.RcodeToString[reply.Rcode], 1)
+	}
+}
@@ -0,0 +1,45 @@
+package mockdns
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_PublishExpvar(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	srv.PublishExpvar("mockdns_test")
+	// Second call should not panic due to duplicate names.
+	srv.PublishExpvar("mockdns_test")
+
+	cl := dns.Client{}
+	for _, name := range []string{"example.org.", "example.com."} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeA)
+		if _, _, err := cl.Exchange(msg, srv.LocalAddr().String()); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+
+	queries := expvar.Get("mockdns_test.queries").(*expvar.Map)
+	if v := queries.Get("A"); v == nil || v.String() != "2" {
+		t.Errorf("Wrong A queries counter, want 2, got %v", v)
+	}
+	rcodes := expvar.Get("mockdns_test.rcodes").(*expvar.Map)
+	if v := rcodes.Get("NOERROR"); v == nil || v.String() != "1" {
+		t.Errorf("Wrong NOERROR counter, want 1, got %v", v)
+	}
+	if v := rcodes.Get("NXDOMAIN"); v == nil || v.String() != "1" {
+		t.Errorf("Wrong NXDOMAIN counter, want 1, got %v", v)
+	}
+}
@@ -0,0 +1,195 @@
+package mockdns
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Fault is the failure mode of the whole Server, see SetFault.
+type Fault int
+
+const (
+	// FaultNone means the Server works normally.
+	FaultNone Fault = iota
+	// FaultTimeout makes the Server not reply to queries at all. Queries
+	// are still recorded.
+	FaultTimeout
+	// FaultServFail makes the Server reply with SERVFAIL to all queries.
+	FaultServFail
+	// FaultRefused makes the Server reply with REFUSED to all queries.
+	FaultRefused
+	// FaultTCPClose makes the Server close TCP connections after writing
+	// half of each length-prefixed reply. UDP queries are served normally.
+	FaultTCPClose
+	// FaultTCPReset is similar to FaultTCPClose but resets connections
+	// (RST) instead of closing them gracefully.
+	FaultTCPReset
+	// FaultNoQuestion makes the Server send replies with the question
+	// section omitted, as some broken middleboxes do.
+	FaultNoQuestion
+	// FaultWrongQuestion makes the Server send replies with the question
+	// for a different name ("invalid.") than the one asked.
+	FaultWrongQuestion
+	// FaultLame makes the Server reply to all queries with NOERROR and
+	// empty answer and authority sections, without AA and RA flags, as
+	// servers with lame delegations do.
+	FaultLame
+	// FaultPointerLoop makes the Server reply with the answer record whose
+	// owner name is a compression pointer to itself, so parsers following
+	// pointers without loop detection hang.
+	FaultPointerLoop
+	// FaultForwardPointer makes the Server reply with the answer record
+	// whose owner name is a compression pointer to the following TXT
+	// record data. RFC 1035 requires pointers to refer to prior
+	// occurrences, so strict parsers reject it.
+	FaultForwardPointer
+)
+
+// faultNames maps names of faults, as used by String and in scenarios, to
+// their values.
+var faultNames = map[string]Fault{
+	"none":            FaultNone,
+	"timeout":         FaultTimeout,
+	"servfail":        FaultServFail,
+	"refused":         FaultRefused,
+	"tcp-close":       FaultTCPClose,
+	"tcp-reset":       FaultTCPReset,
+	"no-question":     FaultNoQuestion,
+	"wrong-question":  FaultWrongQuestion,
+	"lame":            FaultLame,
+	"pointer-loop":    FaultPointerLoop,
+	"forward-pointer": FaultForwardPointer,
+}
+
+func (f Fault) String() string {
+	for name, v := range faultNames {
+		if v == f {
+			return name
+		}
+	}
+	return "Fault(?)"
+}
+
+func (f Fault) rcode() int {
+	switch f {
+	case FaultServFail:
+		return dns.RcodeServerFailure
+	case FaultRefused:
+		return dns.RcodeRefused
+	}
+	return 0
+}
+
+// mangleQuestion changes the question section of the reply as required by
+// FaultNoQuestion and FaultWrongQuestion.
+func (f Fault) mangleQuestion(reply *dns.Msg) {
+	switch f {
+	case FaultNoQuestion:
+		reply.Question = nil
+	case FaultWrongQuestion:
+		questions := make([]dns.Question, len(reply.Question))
+		for i, q := range reply.Question {
+			q.Name = "invalid."
+			questions[i] = q
+		}
+		reply.Question = questions
+	}
+}
+
+// craftReply returns the packed reply with FaultPointerLoop or
+// FaultForwardPointer applied to it or nil for other faults. Only the header
+// and the question section of reply are kept.
+func (f Fault) craftReply(reply *dns.Msg) ([]byte, error) {
+	if f != FaultPointerLoop && f != FaultForwardPointer {
+		return nil, nil
+	}
+
+	hdr := new(dns.Msg)
+	hdr.MsgHdr = reply.MsgHdr
+	hdr.Question = reply.Question
+	packed, err := hdr.Pack()
+	if err != nil {
+		return nil, err
+	}
+	// ANCOUNT = 1
+	packed[6], packed[7] = 0, 1
+
+	off := len(packed)
+	// Owner name pointer, TYPE, CLASS, TTL and RDLENGTH take 12 bytes.
+	target := off
+	rrtype := dns.TypeA
+	rdata := []byte{192, 0, 2, 1}
+	if f == FaultForwardPointer {
+		target = off + 12
+		rrtype = dns.TypeTXT
+		// Also valid TXT data: "example" and an empty character-string.
+		rdata = append([]byte{7}, "example\x00"...)
+	}
+	packed = append(packed,
+		0xC0|byte(target>>8), byte(target),
+		byte(rrtype>>8), byte(rrtype),
+		0, dns.ClassINET,
+		0, 0, 0, 60,
+		0, byte(len(rdata)))
+	return append(packed, rdata...), nil
+}
+
+// SetFault makes the Server fail all queries in the specified way, until
+// SetFault(FaultNone) is called. It is safe to call concurrently with queries
+// being served.
+func (s *Server) SetFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fault = f
+	if s.faultTimer != nil {
+		s.faultTimer.Stop()
+		s.faultTimer = nil
+	}
+}
+
+// FailFor makes the Server fail all queries in the specified way for the
+// duration d, then return to normal operation. This makes it possible to test
+// failover to other servers and failing back.
+func (s *Server) FailFor(f Fault, d time.Duration) {
+	s.SetFault(f)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// Superseded by another SetFault call.
+		if s.faultTimer != timer {
+			return
+		}
+		s.fault = FaultNone
+		s.faultTimer = nil
+	})
+	s.faultTimer = timer
+}
+
+// Fault returns the failure mode set by SetFault or FailFor.
+func (s *Server) Fault() Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fault
+}
+
+// SetFault sets the failure mode of the server with the specified index, see
+// Server.SetFault.
+func (c *Cluster) SetFault(i int, f Fault) {
+	c.Servers[i].SetFault(f)
+}
+
+// ServedBy returns indexes of servers that received queries so far, in order
+// of queries. It is a shortcut for checking failover order using Queries.
+func (c *Cluster) ServedBy() []int {
+	queries := c.Queries()
+	res := make([]int, 0, len(queries))
+	for _, q := range queries {
+		res = append(res, q.Server)
+	}
+	return res
+}
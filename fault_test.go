@@ -0,0 +1,220 @@
+package mockdns
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestCluster_Failover(t *testing.T) {
+	c, err := NewCluster(2, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Simple client trying servers in order.
+	cl := dns.Client{Timeout: 50 * time.Millisecond}
+	resolve := func() int {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		for i, addr := range c.Addrs() {
+			reply, _, err := cl.Exchange(m, addr)
+			if err == nil && reply.Rcode == dns.RcodeSuccess {
+				return i
+			}
+		}
+		t.Fatal("All servers failed")
+		return -1
+	}
+
+	c.SetFault(0, FaultServFail)
+	if got := resolve(); got != 1 {
+		t.Fatalf("Wrong server, want %v, got %v", 1, got)
+	}
+	c.SetFault(0, FaultNone)
+	c.ResetQueryCounts()
+
+	c.Servers[0].FailFor(FaultTimeout, 200*time.Millisecond)
+	if got := resolve(); got != 1 {
+		t.Fatalf("Wrong server, want %v, got %v", 1, got)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if c.Servers[0].Fault() != FaultNone {
+		t.Fatal("Fault is not cleared")
+	}
+	if got := resolve(); got != 0 {
+		t.Fatalf("Wrong server after fail-back, want %v, got %v", 0, got)
+	}
+
+	if served := c.ServedBy(); !reflect.DeepEqual(served, []int{0, 1, 0}) {
+		t.Fatalf("Wrong servers order: %v", served)
+	}
+}
+
+func TestServer_FaultTCPClose(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	for _, f := range []Fault{FaultTCPClose, FaultTCPReset} {
+		srv.SetFault(f)
+
+		cl := dns.Client{Net: "tcp", Timeout: time.Second}
+		start := time.Now()
+		if reply, _, err := cl.Exchange(m, srv.LocalAddr().String()); err == nil {
+			t.Fatalf("%v: truncated reply is accepted: %v", f, reply)
+		}
+		if d := time.Since(start); d >= time.Second {
+			t.Fatalf("%v: client hangs", f)
+		}
+
+		// UDP is not affected.
+		if _, err := dns.Exchange(m, srv.LocalAddr().String()); err != nil {
+			t.Fatalf("%v: UDP query failed: %v", f, err)
+		}
+	}
+
+	srv.SetFault(FaultNone)
+	cl := dns.Client{Net: "tcp", Timeout: time.Second}
+	if _, _, err := cl.Exchange(m, srv.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.TCPConns(); n > 1 {
+		t.Fatalf("Closed connections are not released: %v", n)
+	}
+}
+
+func TestServer_FaultQuestion(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	srv.SetFault(FaultNoQuestion)
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Question) != 0 || len(reply.Answer) != 1 {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+
+	srv.SetFault(FaultWrongQuestion)
+	reply, err = dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Question) != 1 || reply.Question[0].Name != "invalid." {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+
+	srv.SetFault(FaultNone)
+	reply, err = dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Question) != 1 || reply.Question[0].Name != "example.org." {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+}
+
+func TestServer_FaultLame(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetFault(FaultLame)
+
+	for _, name := range []string{"example.org.", "missing.example.org."} {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.Rcode != dns.RcodeSuccess || len(reply.Answer) != 0 || len(reply.Ns) != 0 {
+			t.Fatalf("Wrong reply: %v", reply)
+		}
+		if reply.Authoritative || reply.RecursionAvailable {
+			t.Fatalf("Wrong flags: %v", reply.MsgHdr)
+		}
+	}
+}
+
+func TestServer_FaultPointers(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	query := func() []byte {
+		t.Helper()
+		conn, err := net.Dial("udp", srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		packed, err := m.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(packed); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 512)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[:n]
+	}
+
+	srv.SetFault(FaultPointerLoop)
+	reply := new(dns.Msg)
+	if err := reply.Unpack(query()); err == nil {
+		t.Fatalf("Pointer loop is accepted: %v", reply)
+	}
+
+	srv.SetFault(FaultForwardPointer)
+	packet := query()
+	// miekg/dns does not reject forward pointers.
+	if err := reply.Unpack(packet); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Id != m.Id || len(reply.Answer) != 1 || reply.Answer[0].Header().Name != "example." {
+		t.Fatalf("Wrong reply: %v", reply)
+	}
+	off := len(packet) - 20
+	if ptr := int(packet[off]&0x3F)<<8 | int(packet[off+1]); ptr <= off {
+		t.Fatalf("Pointer is not forward: %v <= %v", ptr, off)
+	}
+}
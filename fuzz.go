@@ -0,0 +1,120 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Reply mutations applied by FuzzServer, selected by the first byte of each
+// 4-byte chunk of fuzzer input.
+const (
+	fuzzPass = iota
+	fuzzFlags
+	fuzzRcode
+	fuzzTruncate
+	fuzzCorrupt
+
+	fuzzOps
+)
+
+const dnsHeaderLen = 12
+
+// FuzzServer is a Server that mutates replies using fuzzer-provided input.
+// It is intended for use in fuzz targets (go test -fuzz) to check that
+// resolver clients handle malformed and unexpected replies gracefully:
+//
+//	srv, _ := mockdns.NewFuzzServer(zones)
+//	defer srv.Close()
+//	f.Fuzz(func(t *testing.T, data []byte) {
+//		srv.Run(data, func(ctx context.Context, r *net.Resolver) {
+//			r.LookupMX(ctx, "example.org")
+//		})
+//	})
+//
+// Input is consumed in 4-byte chunks, one per reply. The first byte selects
+// the mutation: passing the reply as is, flipping header flags, replacing the
+// rcode, truncating the reply or corrupting a single byte. The remaining
+// bytes are mutation parameters. Replies are passed unchanged once the input
+// is exhausted.
+//
+// Mutations never touch the message ID and QR flag and never cut the
+// header, so replies are not trivially discarded as unrelated. Clients may
+// still ignore malformed replies and wait for another one, so Run is bounded
+// by Timeout.
+type FuzzServer struct {
+	*Server
+
+	// Timeout is the deadline for a single Run call. Default is 1 second.
+	Timeout time.Duration
+}
+
+// NewFuzzServer starts a FuzzServer serving the specified zones.
+func NewFuzzServer(zones map[string]Zone) (*FuzzServer, error) {
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		return nil, err
+	}
+	return &FuzzServer{Server: srv}, nil
+}
+
+// Run calls f with the net.Resolver using the Server while replies are
+// mutated according to data. Run calls should not be made concurrently.
+func (s *FuzzServer) Run(data []byte, f func(ctx context.Context, r *net.Resolver)) {
+	s.mu.Lock()
+	s.fuzzInput = data
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.fuzzInput = nil
+		s.mu.Unlock()
+	}()
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	r := &net.Resolver{}
+	s.PatchNet(r)
+	f(ctx, r)
+}
+
+// fuzzReply mutates the packed reply using the next chunk of fuzzer input.
+func (s *Server) fuzzReply(packed []byte) []byte {
+	s.mu.Lock()
+	if len(s.fuzzInput) == 0 {
+		s.mu.Unlock()
+		return packed
+	}
+	var chunk [4]byte
+	n := copy(chunk[:], s.fuzzInput)
+	s.fuzzInput = s.fuzzInput[n:]
+	s.mu.Unlock()
+
+	if len(packed) < dnsHeaderLen {
+		return packed
+	}
+
+	switch chunk[0] % fuzzOps {
+	case fuzzFlags:
+		// Keep QR set so the reply is not ignored.
+		packed[2] ^= chunk[1] &^ 0x80
+		packed[3] ^= chunk[2]
+	case fuzzRcode:
+		packed[3] = packed[3]&0xF0 | chunk[1]&0x0F
+	case fuzzTruncate:
+		size := int(chunk[1])<<8 | int(chunk[2])
+		packed = packed[:dnsHeaderLen+size%(len(packed)-dnsHeaderLen+1)]
+	case fuzzCorrupt:
+		if len(packed) == dnsHeaderLen {
+			break
+		}
+		// Skip the ID and the flags byte containing QR.
+		offset := 3 + (int(chunk[1])<<8|int(chunk[2]))%(len(packed)-3)
+		packed[offset] ^= chunk[3]
+	}
+	return packed
+}
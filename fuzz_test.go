@@ -0,0 +1,63 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFuzzServer(t *testing.T) {
+	srv, err := NewFuzzServer(map[string]Zone{
+		"example.org.": {
+			A:  []string{"1.2.3.4"},
+			MX: []net.MX{{Host: "mx.example.org.", Pref: 10}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Timeout = 200 * time.Millisecond
+
+	lookup := func(data []byte) ([]string, error) {
+		var (
+			addrs []string
+			err   error
+		)
+		srv.Run(data, func(ctx context.Context, r *net.Resolver) {
+			addrs, err = r.LookupHost(ctx, "example.org")
+		})
+		return addrs, err
+	}
+
+	addrs, err := lookup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "1.2.3.4" {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"1.2.3.4"}, addrs)
+	}
+
+	// SERVFAIL for all replies.
+	servfail := []byte{
+		fuzzRcode, 2, 0, 0,
+		fuzzRcode, 2, 0, 0,
+		fuzzRcode, 2, 0, 0,
+		fuzzRcode, 2, 0, 0,
+	}
+	if _, err := lookup(servfail); err == nil {
+		t.Fatal("Expected error for SERVFAIL replies")
+	}
+
+	// Must not hang or panic whatever the input is.
+	for _, data := range [][]byte{
+		{fuzzTruncate, 0, 0, 0},
+		{fuzzTruncate, 0xFF, 0xFF, 0},
+		{fuzzCorrupt, 0, 10, 0xFF},
+		{fuzzFlags, 0xFF, 0xFF, 0},
+		{fuzzPass, 0, 0},
+	} {
+		lookup(data)
+	}
+}
@@ -0,0 +1,62 @@
+package mockdns
+
+import (
+	"net"
+)
+
+// Gauges contains current and peak concurrency of the Server, see
+// Server.Gauges.
+type Gauges struct {
+	// UDPInFlight is the amount of UDP queries being handled, including
+	// replies being delayed.
+	UDPInFlight     int
+	PeakUDPInFlight int
+
+	// TCPConns is the amount of open TCP connections, same as
+	// Server.TCPConns.
+	TCPConns     int
+	PeakTCPConns int
+}
+
+// Gauges returns current and peak concurrency of the Server, so load tests
+// can check that clients respect their limits. Peaks are tracked since the
+// Server start or the last ResetGauges call.
+func (s *Server) Gauges() Gauges {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Gauges{
+		UDPInFlight:     s.udpInFlight,
+		PeakUDPInFlight: s.peakUDPInFlight,
+		TCPConns:        len(s.tcpConns),
+		PeakTCPConns:    s.peakTCPConns,
+	}
+}
+
+// ResetGauges resets peak values returned by Gauges to the current ones.
+func (s *Server) ResetGauges() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peakUDPInFlight = s.udpInFlight
+	s.peakTCPConns = len(s.tcpConns)
+}
+
+// trackUDPQuery counts the query from remote as in-flight, if it came over
+// UDP. The returned function must be called once it is handled.
+func (s *Server) trackUDPQuery(remote net.Addr) func() {
+	if _, ok := remote.(*net.UDPAddr); !ok {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	s.udpInFlight++
+	if s.udpInFlight > s.peakUDPInFlight {
+		s.peakUDPInFlight = s.udpInFlight
+	}
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.udpInFlight--
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,61 @@
+package mockdns
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_Gauges(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if err := srv.SetClientBehavior("127.0.0.1", ClientBehavior{Delay: 100 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl := dns.Client{Timeout: time.Second}
+			if _, _, err := cl.Exchange(m, srv.LocalAddr().String()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cl := dns.Client{Net: "tcp", Timeout: time.Second}
+		if _, _, err := cl.Exchange(m, srv.LocalAddr().String()); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if g := srv.Gauges(); g.UDPInFlight != 3 || g.TCPConns != 1 {
+		t.Errorf("Wrong gauges during queries: %+v", g)
+	}
+	wg.Wait()
+
+	g := srv.Gauges()
+	if g.UDPInFlight != 0 || g.PeakUDPInFlight != 3 || g.PeakTCPConns != 1 {
+		t.Fatalf("Wrong gauges after queries: %+v", g)
+	}
+
+	srv.ResetGauges()
+	if g := srv.Gauges(); g.PeakUDPInFlight != 0 {
+		t.Fatalf("Wrong result, want %v, got %v", 0, g.PeakUDPInFlight)
+	}
+}
@@ -0,0 +1,95 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+type region struct {
+	name string
+	nets []*net.IPNet
+}
+
+// AddRegion defines the region with the specified name used for GeoDNS
+// simulation. Clients from cidrs networks are served Zone.Regions[name]
+// instead of the zone itself, if it is defined. If the query includes the
+// EDNS Client Subnet option (RFC 7871), the subnet address is matched
+// instead of the client address.
+//
+// Regions are checked in the order they were added, the first matching one
+// is used. The ECS option is not echoed in replies.
+func (s *Server) AddRegion(name string, cidrs ...string) error {
+	reg := region{name: name}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("region %s: %v", name, err)
+		}
+		reg.nets = append(reg.nets, ipNet)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.regions {
+		if existing.name == name {
+			s.regions[i] = reg
+			return nil
+		}
+	}
+	s.regions = append(s.regions, reg)
+	return nil
+}
+
+// regionFor returns the region of the client that sent the query, if any.
+func (s *Server) regionFor(addr net.Addr, m *dns.Msg) string {
+	s.mu.Lock()
+	regions := s.regions
+	s.mu.Unlock()
+	if len(regions) == 0 {
+		return ""
+	}
+
+	ip := ecsAddr(m)
+	if ip == nil {
+		ip = addrIP(addr)
+	}
+	if ip == nil {
+		return ""
+	}
+
+	for _, reg := range regions {
+		for _, ipNet := range reg.nets {
+			if ipNet.Contains(ip) {
+				return reg.name
+			}
+		}
+	}
+	return ""
+}
+
+// ecsAddr returns the address from EDNS Client Subnet option of the query,
+// if any.
+func ecsAddr(m *dns.Msg) net.IP {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return ecs.Address
+		}
+	}
+	return nil
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	}
+	return nil
+}
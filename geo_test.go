@@ -0,0 +1,82 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_Regions(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"cdn.example.org.": {
+			A: []string{"203.0.113.1"},
+			Regions: map[string]Zone{
+				"eu": {A: []string{"198.51.100.1"}},
+				"us": {A: []string{"192.0.2.1"}},
+			},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	if err := srv.AddRegion("eu", "127.0.0.2/32", "192.0.2.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.AddRegion("us", "198.51.100.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(reply *dns.Msg, want string) {
+		t.Helper()
+		if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != want {
+			t.Errorf("Wrong result, want %v, got %v", want, reply.Answer)
+		}
+	}
+
+	check(queryFrom(t, srv, "127.0.0.1", "cdn.example.org.", dns.TypeA), "203.0.113.1")
+	check(queryFrom(t, srv, "127.0.0.2", "cdn.example.org.", dns.TypeA), "198.51.100.1")
+
+	// ECS takes precedence over the client address.
+	m := new(dns.Msg)
+	m.SetQuestion("cdn.example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	opt := m.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        1,
+		SourceNetmask: 24,
+		Address:       net.ParseIP("198.51.100.0").To4(),
+	})
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(reply, "192.0.2.1")
+
+	if err := srv.AddRegion("bad", "not-a-cidr"); err == nil {
+		t.Error("Expected error for malformed CIDR")
+	}
+}
+
+func TestResolver_Region(t *testing.T) {
+	r := Resolver{
+		Zones: map[string]Zone{
+			"cdn.example.org.": {
+				A:       []string{"203.0.113.1"},
+				Regions: map[string]Zone{"eu": {A: []string{"198.51.100.1"}}},
+			},
+		},
+		Region: "eu",
+	}
+	addrs, err := r.LookupHost(context.Background(), "cdn.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"198.51.100.1"}, addrs)
+	}
+}
@@ -5,4 +5,5 @@ go 1.13
 require (
 	github.com/miekg/dns v1.1.57
 	golang.org/x/tools v0.15.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,37 @@
+package mockdns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// TypeHandler fills reply for the query, see HandleType. reply already has
+// the header set using SetReply. Returned errors are handled the same way as
+// Resolver errors, e.g. *net.DNSError with IsNotFound produces NXDOMAIN and
+// other errors produce SERVFAIL.
+type TypeHandler func(query, reply *dns.Msg) error
+
+// HandleType makes the Server use h for all queries of qtype instead of
+// looking them up in zones, e.g. to serve experimental record types (RFC
+// 3597 TYPE65534) or ones not supported by Zone. Calling it again for the
+// same qtype replaces the handler, nil h removes it.
+//
+// Faults, client behaviors and query checks are applied before the handler
+// is called.
+func (s *Server) HandleType(qtype uint16, h TypeHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h == nil {
+		delete(s.typeHandlers, qtype)
+		return
+	}
+	if s.typeHandlers == nil {
+		s.typeHandlers = make(map[uint16]TypeHandler)
+	}
+	s.typeHandlers[qtype] = h
+}
+
+func (s *Server) typeHandler(qtype uint16) TypeHandler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.typeHandlers[qtype]
+}
@@ -0,0 +1,59 @@
+package mockdns
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_HandleType(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	srv.HandleType(dns.TypeHTTPS, func(query, reply *dns.Msg) error {
+		if query.Question[0].Name == "fail.example.org." {
+			return errors.New("handler failed")
+		}
+		rr, err := dns.NewRR(query.Question[0].Name + " 300 IN HTTPS 1 . alpn=h2")
+		if err != nil {
+			return err
+		}
+		reply.Answer = append(reply.Answer, rr)
+		return nil
+	})
+
+	cl := dns.Client{Timeout: time.Second}
+	exchange := func(name string, qtype uint16) *dns.Msg {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	reply := exchange("example.org.", dns.TypeHTTPS)
+	if len(reply.Answer) != 1 || reply.Answer[0].Header().Rrtype != dns.TypeHTTPS {
+		t.Fatalf("Wrong result, want %v, got %v", "HTTPS record", reply.Answer)
+	}
+	if reply := exchange("fail.example.org.", dns.TypeHTTPS); reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, reply.Rcode)
+	}
+	if reply := exchange("example.org.", dns.TypeA); len(reply.Answer) != 1 {
+		t.Fatalf("Wrong result, want %v, got %v", 1, len(reply.Answer))
+	}
+
+	srv.HandleType(dns.TypeHTTPS, nil)
+	if reply := exchange("example.org.", dns.TypeHTTPS); len(reply.Answer) != 0 {
+		t.Fatalf("Wrong result, want %v, got %v", 0, len(reply.Answer))
+	}
+}
@@ -0,0 +1,89 @@
+package mockdns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// errInvalidHosts is the error message used by net.Resolver (since Go 1.20.5)
+// when some of MX, NS or SRV records are dropped because of invalid host
+// names.
+const errInvalidHosts = "DNS response contained records which contain invalid names"
+
+func invalidHostsErr(name string) error {
+	return &net.DNSError{Err: errInvalidHosts, Name: name, Server: defaultErrServer}
+}
+
+func filterMX(name string, mx []*net.MX) ([]*net.MX, error) {
+	out := mx[:0]
+	for _, rec := range mx {
+		if isDomainName(rec.Host) {
+			out = append(out, rec)
+		}
+	}
+	if len(out) != len(mx) {
+		return out, invalidHostsErr(name)
+	}
+	return out, nil
+}
+
+func filterNS(name string, ns []*net.NS) ([]*net.NS, error) {
+	out := ns[:0]
+	for _, rec := range ns {
+		if isDomainName(rec.Host) {
+			out = append(out, rec)
+		}
+	}
+	if len(out) != len(ns) {
+		return out, invalidHostsErr(name)
+	}
+	return out, nil
+}
+
+func filterSRV(name string, srv []*net.SRV) ([]*net.SRV, error) {
+	out := srv[:0]
+	for _, rec := range srv {
+		if isDomainName(rec.Target) {
+			out = append(out, rec)
+		}
+	}
+	if len(out) != len(srv) {
+		return out, invalidHostsErr(name)
+	}
+	return out, nil
+}
+
+// validHost reports whether rr is not an MX, NS or SRV record with an invalid
+// host name.
+func validHost(rr dns.RR) bool {
+	switch rr := rr.(type) {
+	case *dns.MX:
+		return isDomainName(rr.Mx)
+	case *dns.NS:
+		return isDomainName(rr.Ns)
+	case *dns.SRV:
+		return isDomainName(rr.Target)
+	}
+	return true
+}
+
+// filterHosts returns rrs without MX, NS and SRV records with invalid host
+// names. rrs is not modified.
+func filterHosts(rrs []dns.RR) []dns.RR {
+	for i, rr := range rrs {
+		if validHost(rr) {
+			continue
+		}
+
+		out := make([]dns.RR, i, len(rrs))
+		copy(out, rrs[:i])
+		for _, rr := range rrs[i+1:] {
+			if validHost(rr) {
+				out = append(out, rr)
+			}
+		}
+		return out
+	}
+	return rrs
+}
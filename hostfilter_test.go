@@ -0,0 +1,115 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+var invalidHostsZones = map[string]Zone{
+	"example.org.": {
+		MX: []net.MX{
+			{Host: "mx.example.org.", Pref: 10},
+			{Host: "bad!host.example.org.", Pref: 20},
+		},
+		NS: []net.NS{
+			{Host: "ns1.example.org."},
+			{Host: "ns 2.example.org."},
+		},
+	},
+	"_smtp._tcp.example.org.": {
+		SRV: []net.SRV{
+			{Target: "bad!host.example.org.", Port: 25},
+			{Target: "smtp.example.org.", Port: 25},
+		},
+	},
+}
+
+func TestResolver_FilterInvalidHosts(t *testing.T) {
+	r := Resolver{Zones: invalidHostsZones}
+
+	mx, err := r.LookupMX(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mx) != 2 {
+		t.Fatalf("Wrong amount of records without filtering, want %v, got %v", 2, len(mx))
+	}
+
+	r.FilterInvalidHosts = true
+
+	mx, err = r.LookupMX(context.Background(), "example.org")
+	if dnsErr, ok := err.(*net.DNSError); !ok || dnsErr.Err != errInvalidHosts {
+		t.Fatalf("Wrong error, want %v, got %v", errInvalidHosts, err)
+	}
+	if want := []*net.MX{{Host: "mx.example.org.", Pref: 10}}; !reflect.DeepEqual(mx, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, mx)
+	}
+
+	ns, err := r.LookupNS(context.Background(), "example.org")
+	if err == nil {
+		t.Fatal("No error for filtered NS records")
+	}
+	if want := []*net.NS{{Host: "ns1.example.org."}}; !reflect.DeepEqual(ns, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, ns)
+	}
+
+	_, srv, err := r.LookupSRV(context.Background(), "smtp", "tcp", "example.org")
+	if err == nil {
+		t.Fatal("No error for filtered SRV records")
+	}
+	if want := []*net.SRV{{Target: "smtp.example.org.", Port: 25}}; !reflect.DeepEqual(srv, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, srv)
+	}
+}
+
+func TestServer_FilterInvalidHosts(t *testing.T) {
+	plain := newTestServer(t, invalidHostsZones, func(s *Server) {})
+	defer plain.Close()
+	srv := newTestServer(t, invalidHostsZones, func(s *Server) {
+		s.FilterInvalidHosts = true
+	})
+	defer srv.Close()
+
+	query := func(srv *Server, name string, qtype uint16) []dns.RR {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Answer
+	}
+
+	if answer := query(plain, "example.org.", dns.TypeMX); len(answer) != 2 {
+		t.Fatalf("Wrong amount of records without filtering, want %v, got %v", 2, len(answer))
+	}
+
+	answer := query(srv, "example.org.", dns.TypeMX)
+	if len(answer) != 1 || answer[0].(*dns.MX).Mx != "mx.example.org." {
+		t.Fatalf("Wrong result, want %v, got %v", "mx.example.org.", answer)
+	}
+	answer = query(srv, "example.org.", dns.TypeNS)
+	if len(answer) != 1 || answer[0].(*dns.NS).Ns != "ns1.example.org." {
+		t.Fatalf("Wrong result, want %v, got %v", "ns1.example.org.", answer)
+	}
+	answer = query(srv, "_smtp._tcp.example.org.", dns.TypeSRV)
+	if len(answer) != 1 || answer[0].(*dns.SRV).Target != "smtp.example.org." {
+		t.Fatalf("Wrong result, want %v, got %v", "smtp.example.org.", answer)
+	}
+
+	// net.Resolver gets no error, since nothing is left to filter.
+	var r net.Resolver
+	srv.PatchNet(&r)
+	mx, err := r.LookupMX(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mx) != 1 {
+		t.Fatalf("Wrong amount of records, want %v, got %v", 1, len(mx))
+	}
+}
@@ -0,0 +1,74 @@
+package mockdns
+
+import (
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// hostsEntry returns Hosts addresses for host, ignoring case and trailing
+// dot.
+func (r *Resolver) hostsEntry(host string) []string {
+	if len(r.Hosts) == 0 {
+		return nil
+	}
+
+	fqdn := normalizeName(host)
+	for name, addrs := range r.Hosts {
+		if normalizeName(name) == fqdn {
+			return addrs
+		}
+	}
+	return nil
+}
+
+// lookupHosts returns addresses from Hosts for the specified network ("ip",
+// "ip4" or "ip6").
+func (r *Resolver) lookupHosts(network, host string) []string {
+	entry := r.hostsEntry(host)
+	if len(entry) == 0 {
+		return nil
+	}
+
+	var addrs4, addrs6 []string
+	for _, addr := range entry {
+		ip, _ := parseIPZone(addr)
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			addrs4 = append(addrs4, addr)
+		} else {
+			addrs6 = append(addrs6, addr)
+		}
+	}
+
+	switch network {
+	case "ip4":
+		return addrs4
+	case "ip6":
+		return addrs6
+	default:
+		return r.orderAddrs(addrs4, addrs6)
+	}
+}
+
+// lookupHostsAddr returns names from Hosts that have the addr address.
+func (r *Resolver) lookupHostsAddr(addr string) []string {
+	ip, _ := parseIPZone(addr)
+	if ip == nil {
+		return nil
+	}
+
+	var names []string
+	for name, addrs := range r.Hosts {
+		for _, hostAddr := range addrs {
+			if hostIP, _ := parseIPZone(hostAddr); hostIP.Equal(ip) {
+				names = append(names, dns.Fqdn(name))
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
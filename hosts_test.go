@@ -0,0 +1,64 @@
+package mockdns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestResolver_Hosts(t *testing.T) {
+	r := Resolver{
+		Zones: map[string]Zone{
+			"example.org.": {
+				A: []string{"1.2.3.4"},
+			},
+			"example.net.": {
+				A:    []string{"1.2.3.4"},
+				AAAA: []string{"2001:db8::1"},
+			},
+		},
+		Hosts: map[string][]string{
+			"example.org": {"127.0.0.1", "::1"},
+			"myhost":      {"192.0.2.1"},
+			"example.net": {"192.0.2.2"},
+		},
+	}
+
+	addrs, err := r.LookupHost(context.Background(), "example.org.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.1", "::1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Hosts entry is not preferred, want %v, got %v", want, addrs)
+	}
+
+	addrs, err = r.LookupHost(context.Background(), "MyHost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result for hosts-only name, want %v, got %v", want, addrs)
+	}
+
+	// No IPv6 address in hosts, fallback to zones.
+	ips, err := r.LookupIP(context.Background(), "ip6", "example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || ips[0].String() != "2001:db8::1" {
+		t.Errorf("Wrong fallback result: %v", ips)
+	}
+
+	names, err := r.LookupAddr(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.org."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Wrong reverse result, want %v, got %v", want, names)
+	}
+
+	// Hosts entries have no other records.
+	if _, err := r.LookupMX(context.Background(), "myhost"); err == nil {
+		t.Errorf("Expected error for MX lookup of hosts-only name, got nil")
+	}
+}
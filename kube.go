@@ -0,0 +1,134 @@
+package mockdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const defaultKubeDomain = "cluster.local"
+
+// KubeService describes the Kubernetes headless service for
+// AddKubeService.
+type KubeService struct {
+	Name      string
+	Namespace string
+
+	// Domain is the cluster domain, "cluster.local" if empty.
+	Domain string
+
+	Pods  []KubePod
+	Ports []KubePort
+}
+
+// KubePod is the endpoint of the headless service.
+type KubePod struct {
+	IP string
+
+	// Hostname is the pod hostname (spec.hostname), if any. Pods without
+	// it are named using the dashed IP (e.g. "10-0-0-1").
+	Hostname string
+}
+
+// KubePort is the named port of the service.
+type KubePort struct {
+	Name string
+	// Protocol is "tcp" or "udp", "tcp" if empty.
+	Protocol string
+	Port     uint16
+}
+
+// AddKubeService adds records kube-dns or CoreDNS would serve for the
+// headless service to zones:
+//
+//   - A/AAAA records for <service>.<ns>.svc.<domain> with all pod IPs,
+//   - A/AAAA record for each pod (<hostname>.<service>.<ns>.svc.<domain>),
+//   - SRV records for each named port
+//     (_<port>._<proto>.<service>.<ns>.svc.<domain>) pointing to pods,
+//   - PTR records for pod IPs.
+//
+// Use KubeSearch to get the search list of pods in the namespace.
+func AddKubeService(zones map[string]Zone, svc KubeService) error {
+	if svc.Name == "" || svc.Namespace == "" {
+		return errors.New("service name and namespace are required")
+	}
+	domain := svc.Domain
+	if domain == "" {
+		domain = defaultKubeDomain
+	}
+
+	svcName := normalizeName(svc.Name + "." + svc.Namespace + ".svc." + domain)
+	svcZone := zones[svcName]
+
+	podNames := make([]string, 0, len(svc.Pods))
+	for _, pod := range svc.Pods {
+		ip := net.ParseIP(pod.IP)
+		if ip == nil {
+			return fmt.Errorf("malformed pod IP: %v", pod.IP)
+		}
+
+		host := pod.Hostname
+		if host == "" {
+			host = strings.NewReplacer(".", "-", ":", "-").Replace(ip.String())
+		}
+		podName := normalizeName(host + "." + svcName)
+		podNames = append(podNames, podName)
+
+		podZone := zones[podName]
+		if ip.To4() != nil {
+			svcZone.A = append(svcZone.A, ip.String())
+			podZone.A = append(podZone.A, ip.String())
+		} else {
+			svcZone.AAAA = append(svcZone.AAAA, ip.String())
+			podZone.AAAA = append(podZone.AAAA, ip.String())
+		}
+		zones[podName] = podZone
+
+		arpa := ReverseName(ip)
+		ptrZone := zones[arpa]
+		ptrZone.PTR = append(ptrZone.PTR, podName)
+		zones[arpa] = ptrZone
+	}
+	zones[svcName] = svcZone
+
+	if len(podNames) == 0 {
+		return nil
+	}
+	for _, port := range svc.Ports {
+		if port.Name == "" {
+			return errors.New("SRV records are only created for named ports")
+		}
+		proto := port.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+
+		srvName := normalizeName("_" + port.Name + "._" + proto + "." + svcName)
+		srvZone := zones[srvName]
+		for _, podName := range podNames {
+			srvZone.SRV = append(srvZone.SRV, net.SRV{
+				Target:   podName,
+				Port:     port.Port,
+				Priority: 0,
+				Weight:   uint16(100 / len(podNames)),
+			})
+		}
+		zones[srvName] = srvZone
+	}
+
+	return nil
+}
+
+// KubeSearch returns the DNS search list of pods in the namespace, as set
+// by kubelet in resolv.conf. Domain defaults to "cluster.local".
+func KubeSearch(namespace, domain string) []string {
+	if domain == "" {
+		domain = defaultKubeDomain
+	}
+	return []string{
+		namespace + ".svc." + domain,
+		"svc." + domain,
+		domain,
+	}
+}
@@ -0,0 +1,69 @@
+package mockdns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestAddKubeService(t *testing.T) {
+	zones := map[string]Zone{}
+	err := AddKubeService(zones, KubeService{
+		Name:      "db",
+		Namespace: "prod",
+		Pods: []KubePod{
+			{IP: "10.0.0.1", Hostname: "db-0"},
+			{IP: "10.0.0.2"},
+		},
+		Ports: []KubePort{{Name: "postgres", Port: 5432}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Resolver{
+		Zones:             zones,
+		SingleLabelPolicy: NameSearch,
+		Search:            KubeSearch("prod", ""),
+	}
+
+	addrs, err := r.LookupHost(context.Background(), "db.prod.svc.cluster.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	// Resolved via the search list.
+	addrs, err = r.LookupHost(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result for search, want %v, got %v", want, addrs)
+	}
+
+	_, srvs, err := r.LookupSRV(context.Background(), "postgres", "tcp", "db.prod.svc.cluster.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srvs) != 2 || srvs[0].Target != "db-0.db.prod.svc.cluster.local." || srvs[1].Target != "10-0-0-2.db.prod.svc.cluster.local." || srvs[0].Port != 5432 {
+		t.Errorf("Wrong SRV records: %+v %+v", srvs[0], srvs[1])
+	}
+
+	addrs, err = r.LookupHost(context.Background(), "db-0.db.prod.svc.cluster.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	names, err := r.LookupAddr(context.Background(), "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10-0-0-2.db.prod.svc.cluster.local."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, names)
+	}
+}
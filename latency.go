@@ -0,0 +1,75 @@
+package mockdns
+
+import (
+	"sort"
+	"time"
+)
+
+// maxLatencySamples is the amount of most recent queries LatencyPercentile
+// is computed over.
+const maxLatencySamples = 1 << 16
+
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencyRing) add(d time.Duration) {
+	if len(l.samples) < maxLatencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % maxLatencySamples
+}
+
+func (s *Server) recordLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency.add(d)
+}
+
+// LatencyPercentile returns the p-th percentile (0 < p <= 100) of time
+// spent handling queries, starting from the moment the query is passed to
+// Server and up to the moment the reply is written to the socket.
+//
+// It is computed over the most recent 65536 queries. Zero is returned if no
+// queries were served yet.
+func (s *Server) LatencyPercentile(p float64) time.Duration {
+	return s.LatencyPercentiles(p)[0]
+}
+
+// LatencyPercentiles is similar to LatencyPercentile but computes multiple
+// percentiles at once.
+func (s *Server) LatencyPercentiles(ps ...float64) []time.Duration {
+	s.mu.Lock()
+	sorted := make([]time.Duration, len(s.latency.samples))
+	copy(sorted, s.latency.samples)
+	s.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	res := make([]time.Duration, len(ps))
+	if len(sorted) == 0 {
+		return res
+	}
+	for i, p := range ps {
+		// Nearest-rank method.
+		rank := int(p/100*float64(len(sorted))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(sorted) {
+			rank = len(sorted) - 1
+		}
+		res[i] = sorted[rank]
+	}
+	return res
+}
+
+// ResetLatencies discards all samples used by LatencyPercentile.
+func (s *Server) ResetLatencies() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latencyRing{}
+}
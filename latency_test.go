@@ -0,0 +1,65 @@
+package mockdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLatencyRing(t *testing.T) {
+	var s Server
+	if p := s.LatencyPercentile(50); p != 0 {
+		t.Errorf("Wrong percentile without samples: %v", p)
+	}
+
+	for i := 1; i <= 100; i++ {
+		s.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+
+	ps := s.LatencyPercentiles(1, 50, 99, 100)
+	want := []time.Duration{1 * time.Millisecond, 50 * time.Millisecond, 99 * time.Millisecond, 100 * time.Millisecond}
+	for i := range want {
+		if ps[i] != want[i] {
+			t.Errorf("Wrong percentile %d, want %v, got %v", i, want[i], ps[i])
+		}
+	}
+
+	// Old samples are discarded once the limit is reached.
+	for i := 0; i < maxLatencySamples; i++ {
+		s.recordLatency(time.Second)
+	}
+	if p := s.LatencyPercentile(1); p != time.Second {
+		t.Errorf("Old samples are not discarded: %v", p)
+	}
+
+	s.ResetLatencies()
+	if p := s.LatencyPercentile(50); p != 0 {
+		t.Errorf("Wrong percentile after reset: %v", p)
+	}
+}
+
+func TestServer_LatencyPercentile(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.org.", dns.TypeA)
+	cl := dns.Client{}
+	if _, _, err := cl.Exchange(msg, srv.LocalAddr().String()); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	// Latency is recorded after the reply is sent so it may be not available
+	// right away.
+	deadline := time.Now().Add(time.Second)
+	for srv.LatencyPercentile(100) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Latency is not recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,136 @@
+package mockdns
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxTXTString is the maximum length of a single character-string in TXT
+// records. Longer values are split by Server.
+const maxTXTString = 255
+
+// Warning is a problem found by LintZones.
+type Warning struct {
+	// Name is the zone the problem is found in.
+	Name    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Name + ": " + w.Message
+}
+
+// LintZones checks zones for likely fixture mistakes that are not invalid
+// per se (see ValidateZones), but cause unexpected behavior:
+//
+//   - CNAME targets that are not present in zones,
+//   - MX and NS hosts that are CNAMEs (RFC 2181, Section 10.3),
+//   - NS hosts without addresses (no glue),
+//   - TXT values longer than 255 octets, which are split into several
+//     character-strings,
+//   - duplicate records.
+//
+// Warnings are sorted by zone name.
+func LintZones(zones map[string]Zone) []Warning {
+	// Keys are looked up in canonical form, so fixtures written by hand
+	// (e.g. "Example.org") are linted as Server would use them.
+	canonical := make(map[string]Zone, len(zones))
+	names := make([]string, 0, len(zones))
+	for name, zone := range zones {
+		canonical[normalizeName(name)] = zone
+		names = append(names, name)
+	}
+	r := &Resolver{Zones: canonical}
+	sort.Strings(names)
+
+	var warns []Warning
+	for _, name := range names {
+		zone := zones[name]
+		warn := func(format string, args ...interface{}) {
+			warns = append(warns, Warning{Name: name, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if zone.CNAME != "" {
+			if _, ok := r.zone(normalizeName(zone.CNAME)); !ok {
+				warn("CNAME target %s does not exist", zone.CNAME)
+			}
+		}
+
+		seenMX := make(map[string]bool, len(zone.MX))
+		for _, mx := range zone.MX {
+			if target, ok := r.zone(mx.Host); ok && target.CNAME != "" {
+				warn("MX host %s is a CNAME", mx.Host)
+			}
+			key := strconv.Itoa(int(mx.Pref)) + " " + normalizeName(mx.Host)
+			if seenMX[key] {
+				warn("duplicate MX record %s", key)
+			}
+			seenMX[key] = true
+		}
+
+		seenNS := make(map[string]bool, len(zone.NS))
+		for _, ns := range zone.NS {
+			host := normalizeName(ns.Host)
+			target, ok := r.zone(host)
+			switch {
+			case ok && target.CNAME != "":
+				warn("NS host %s is a CNAME", ns.Host)
+			case !ok || len(target.A) == 0 && len(target.AAAA) == 0:
+				warn("NS host %s has no addresses", ns.Host)
+			}
+			if seenNS[host] {
+				warn("duplicate NS record %s", host)
+			}
+			seenNS[host] = true
+		}
+
+		seenSRV := make(map[srvKey]bool, len(zone.SRV))
+		for _, srv := range zone.SRV {
+			key := srvKey{srv.Priority, srv.Weight, srv.Port, normalizeName(srv.Target)}
+			if seenSRV[key] {
+				warn("duplicate SRV record %d %d %d %s", key.priority, key.weight, key.port, key.target)
+			}
+			seenSRV[key] = true
+		}
+
+		for _, txt := range zone.TXT {
+			if len(txt) > maxTXTString {
+				warn("TXT value of %d octets is longer than %d and is split", len(txt), maxTXTString)
+			}
+		}
+
+		for _, dup := range duplicates(zone.A) {
+			warn("duplicate A record %s", dup)
+		}
+		for _, dup := range duplicates(zone.AAAA) {
+			warn("duplicate AAAA record %s", dup)
+		}
+		for _, dup := range duplicates(zone.TXT) {
+			warn("duplicate TXT record %q", dup)
+		}
+		for _, dup := range duplicates(zone.PTR) {
+			warn("duplicate PTR record %s", dup)
+		}
+	}
+
+	return warns
+}
+
+type srvKey struct {
+	priority, weight, port uint16
+	target                 string
+}
+
+// duplicates returns values that occur in values more than once.
+func duplicates(values []string) []string {
+	seen := make(map[string]int, len(values))
+	var dups []string
+	for _, v := range values {
+		seen[v]++
+		if seen[v] == 2 {
+			dups = append(dups, v)
+		}
+	}
+	return dups
+}
@@ -0,0 +1,60 @@
+package mockdns
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestLintZones(t *testing.T) {
+	warns := LintZones(map[string]Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1", "192.0.2.1"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}, {Host: "alias.example.org.", Pref: 20}},
+			NS:  []net.NS{{Host: "ns1.example.org."}, {Host: "ns2.example.org."}},
+			TXT: []string{strings.Repeat("a", 300)},
+		},
+		"mx.example.org.": {
+			A: []string{"192.0.2.2"},
+		},
+		"ns1.example.org.": {
+			A: []string{"192.0.2.3"},
+		},
+		"alias.example.org.": {
+			CNAME: "mx.example.org.",
+		},
+		"dangling.example.org.": {
+			CNAME: "missing.example.org.",
+		},
+	})
+
+	want := []string{
+		"dangling.example.org.: CNAME target missing.example.org. does not exist",
+		"example.org.: MX host alias.example.org. is a CNAME",
+		"example.org.: NS host ns2.example.org. has no addresses",
+		"example.org.: TXT value of 300 octets is longer than 255 and is split",
+		"example.org.: duplicate A record 192.0.2.1",
+	}
+	if len(warns) != len(want) {
+		t.Fatalf("Wrong warnings, want %v, got %v", want, warns)
+	}
+	for i, w := range warns {
+		if w.String() != want[i] {
+			t.Errorf("Wrong warning %d, want %v, got %v", i, want[i], w)
+		}
+	}
+}
+
+func TestLintZones_Case(t *testing.T) {
+	warns := LintZones(map[string]Zone{
+		"Alias.Example.ORG": {
+			CNAME: "Target.example.org",
+		},
+		"TARGET.example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	})
+	if len(warns) != 0 {
+		t.Fatalf("Wrong warnings, want %v, got %v", nil, warns)
+	}
+}
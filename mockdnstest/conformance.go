@@ -0,0 +1,163 @@
+package mockdnstest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+)
+
+// Lookup is a single lookup done by Conformance.
+type Lookup struct {
+	// Method is the name of the lookup method: "LookupHost",
+	// "LookupIPAddr", "LookupMX", "LookupNS", "LookupTXT", "LookupCNAME",
+	// "LookupSRV" or "LookupAddr".
+	Method string
+
+	// Name is the argument of the method (address for LookupAddr).
+	Name string
+
+	// Service and Proto are used for LookupSRV only.
+	Service, Proto string
+}
+
+func (l Lookup) String() string {
+	if l.Method == "LookupSRV" {
+		return l.Method + "(" + l.Service + ", " + l.Proto + ", " + l.Name + ")"
+	}
+	return l.Method + "(" + l.Name + ")"
+}
+
+// Difference is a lookup that gives different results with mockdns.Resolver
+// and net.Resolver. Results are rendered using FormatResult.
+type Difference struct {
+	Lookup Lookup
+	Mock   string
+	Real   string
+}
+
+// resolver is the set of methods shared by mockdns.Resolver and
+// net.Resolver.
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+func doLookup(ctx context.Context, r resolver, l Lookup) string {
+	switch l.Method {
+	case "LookupHost":
+		return FormatResult(r.LookupHost(ctx, l.Name))
+	case "LookupIPAddr":
+		return FormatResult(r.LookupIPAddr(ctx, l.Name))
+	case "LookupMX":
+		return FormatResult(r.LookupMX(ctx, l.Name))
+	case "LookupNS":
+		return FormatResult(r.LookupNS(ctx, l.Name))
+	case "LookupTXT":
+		return FormatResult(r.LookupTXT(ctx, l.Name))
+	case "LookupCNAME":
+		return FormatResult(r.LookupCNAME(ctx, l.Name))
+	case "LookupSRV":
+		cname, addrs, err := r.LookupSRV(ctx, l.Service, l.Proto, l.Name)
+		return FormatResult(cname, nil) + FormatResult(addrs, err)
+	case "LookupAddr":
+		return FormatResult(r.LookupAddr(ctx, l.Name))
+	}
+	panic("mockdnstest: unknown lookup method: " + l.Method)
+}
+
+// DefaultLookups returns lookups of addresses, MX, NS, TXT and CNAME records
+// for all names in zones and for a name that does not exist.
+func DefaultLookups(zones map[string]mockdns.Zone) []Lookup {
+	names := make([]string, 0, len(zones)+1)
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append(names, "nonexistent.mockdns.test.")
+
+	var lookups []Lookup
+	for _, name := range names {
+		for _, method := range []string{"LookupHost", "LookupMX", "LookupNS", "LookupTXT", "LookupCNAME"} {
+			lookups = append(lookups, Lookup{Method: method, Name: name})
+		}
+	}
+	return lookups
+}
+
+// Conformance runs lookups against mockdns.Resolver with the specified zones
+// and against net.Resolver querying mockdns.Server with the same zones, and
+// returns lookups with different results. If lookups are not specified,
+// DefaultLookups are used.
+//
+// Results are compared in order, so differences in ordering are reported
+// too (e.g. net.Resolver sorts addresses according to RFC 6724). Errors are
+// compared by message and IsNotFound, IsTimeout and IsTemporary flags.
+func Conformance(ctx context.Context, zones map[string]mockdns.Zone, lookups ...Lookup) ([]Difference, error) {
+	if len(lookups) == 0 {
+		lookups = DefaultLookups(zones)
+	}
+
+	srv, err := mockdns.NewServerWithLogger(zones, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		return nil, err
+	}
+	defer srv.Close()
+
+	real := &net.Resolver{}
+	srv.PatchNet(real)
+	mock := &mockdns.Resolver{Zones: zones}
+
+	var diffs []Difference
+	for _, l := range lookups {
+		mockRes := doLookup(ctx, mock, l)
+		realRes := doLookup(ctx, real, l)
+		if normalizeDotNames(mockRes) != normalizeDotNames(realRes) {
+			diffs = append(diffs, Difference{Lookup: l, Mock: mockRes, Real: realRes})
+		}
+	}
+	return diffs, nil
+}
+
+// normalizeDotNames removes the "name: ..." part of rendered errors, since
+// net.Resolver reports names in different forms depending on the lookup
+// path.
+func normalizeDotNames(res string) string {
+	lines := strings.Split(res, "\n")
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "error: ") {
+			continue
+		}
+		if start := strings.Index(l, "(name: "); start != -1 {
+			if end := strings.Index(l[start:], ", "); end != -1 {
+				lines[i] = l[:start+1] + l[start+end+2:]
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckConformance is similar to Conformance but reports differences as
+// test errors.
+func CheckConformance(t testing.TB, zones map[string]mockdns.Zone, lookups ...Lookup) {
+	t.Helper()
+
+	diffs, err := Conformance(context.Background(), zones, lookups...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diffs {
+		t.Errorf("%v: mockdns and net.Resolver differ (-mock +net):\n%s", d.Lookup, Diff(d.Mock, d.Real))
+	}
+}
@@ -0,0 +1,50 @@
+package mockdnstest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+)
+
+func TestConformance(t *testing.T) {
+	zones := map[string]mockdns.Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			TXT: []string{"v=spf1 -all"},
+		},
+		"www.example.org.": {
+			CNAME: "example.org.",
+		},
+	}
+	diffs, err := Conformance(context.Background(), zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Known differences between mockdns.Resolver and net.Resolver with the
+	// reason for each, anything else is a bug.
+	known := map[Lookup]string{
+		{Method: "LookupNS", Name: "example.org."}: "mockdns.Resolver returns no records " +
+			"instead of an error for names without NS records",
+		{Method: "LookupNS", Name: "www.example.org."}: "mockdns.Resolver returns no records " +
+			"instead of an error for names without NS records",
+		{Method: "LookupCNAME", Name: "example.org."}: "net.Resolver returns the name itself " +
+			"for names without CNAME records",
+	}
+	for _, d := range diffs {
+		if reason, ok := known[d.Lookup]; ok {
+			t.Logf("%v: known difference: %s", d.Lookup, reason)
+			continue
+		}
+		t.Errorf("%v: mockdns and net.Resolver differ (-mock +net):\n%s", d.Lookup, Diff(d.Mock, d.Real))
+	}
+
+	// Lookups that definitely should match.
+	CheckConformance(t, zones,
+		Lookup{Method: "LookupHost", Name: "example.org."},
+		Lookup{Method: "LookupMX", Name: "example.org."},
+		Lookup{Method: "LookupTXT", Name: "example.org."},
+	)
+}
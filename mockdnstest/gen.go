@@ -0,0 +1,271 @@
+package mockdnstest
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+// GenConfig controls zone sets produced by Generate. See DefaultGenConfig
+// for a config with all fields set.
+type GenConfig struct {
+	// Domain is the suffix of all generated names. Defaults to "test.".
+	Domain string
+
+	// Names is the amount of names to generate. Defaults to 20.
+	Names int
+
+	// CNAMEs is the fraction of names that are CNAMEs, from 0 to 1.
+	CNAMEs float64
+
+	// MaxChain is the maximum length of generated CNAME chains. Defaults
+	// to 3.
+	MaxChain int
+
+	// Missing is the amount of names not present in the zone set to
+	// generate, see ZoneSet.Missing.
+	Missing int
+}
+
+// DefaultGenConfig returns the GenConfig with 20 names under "test.", a
+// quarter of which are CNAMEs in chains of up to 3, and 5 missing names.
+func DefaultGenConfig() GenConfig {
+	return GenConfig{
+		Domain:   "test.",
+		Names:    20,
+		CNAMEs:   0.25,
+		MaxChain: 3,
+		Missing:  5,
+	}
+}
+
+// ZoneSet is a randomly generated set of zones, see Generate.
+type ZoneSet struct {
+	// Zones can be passed to mockdns.NewServer or used in mockdns.Resolver.
+	Zones map[string]mockdns.Zone
+
+	// Names contains all names defined in Zones, sorted.
+	Names []string
+
+	// Missing contains names under the same domain that are not defined in
+	// Zones, sorted.
+	Missing []string
+}
+
+// Generate returns a random but valid zone set. The same seed and config
+// always produce the same zone set.
+//
+// Names have a mix of A, AAAA, TXT and MX records or are CNAMEs. CNAME chains
+// always end at a name with records and never form loops.
+func Generate(seed int64, cfg GenConfig) *ZoneSet {
+	if cfg.Domain == "" {
+		cfg.Domain = "test."
+	}
+	cfg.Domain = dns.Fqdn(strings.ToLower(cfg.Domain))
+	if cfg.Names == 0 {
+		cfg.Names = 20
+	}
+	if cfg.MaxChain == 0 {
+		cfg.MaxChain = 3
+	}
+
+	g := generator{
+		rnd:   rand.New(rand.NewSource(seed)),
+		cfg:   cfg,
+		taken: make(map[string]bool),
+	}
+
+	zs := &ZoneSet{Zones: make(map[string]mockdns.Zone, cfg.Names)}
+
+	cnames := int(float64(cfg.Names) * cfg.CNAMEs)
+	if cnames >= cfg.Names {
+		cnames = cfg.Names - 1
+	}
+
+	// Chain length of each name, in order of generation.
+	var (
+		names []string
+		depth []int
+	)
+	for i := 0; i < cfg.Names-cnames; i++ {
+		name := g.name()
+		zs.Zones[name] = g.zone()
+		names = append(names, name)
+		depth = append(depth, 0)
+	}
+	for i := 0; i < cnames; i++ {
+		var candidates []int
+		for j := range names {
+			if depth[j] < cfg.MaxChain {
+				candidates = append(candidates, j)
+			}
+		}
+		target := candidates[g.rnd.Intn(len(candidates))]
+
+		name := g.name()
+		zs.Zones[name] = mockdns.Zone{CNAME: names[target]}
+		names = append(names, name)
+		depth = append(depth, depth[target]+1)
+	}
+
+	for i := 0; i < cfg.Missing; i++ {
+		zs.Missing = append(zs.Missing, g.name())
+	}
+
+	zs.Names = names
+	sort.Strings(zs.Names)
+	sort.Strings(zs.Missing)
+	return zs
+}
+
+type generator struct {
+	rnd   *rand.Rand
+	cfg   GenConfig
+	taken map[string]bool
+}
+
+const labelChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func (g *generator) label() string {
+	b := make([]byte, 1+g.rnd.Intn(10))
+	// Start with a letter to keep labels valid hostnames.
+	b[0] = labelChars[g.rnd.Intn(26)]
+	for i := 1; i < len(b); i++ {
+		b[i] = labelChars[g.rnd.Intn(len(labelChars))]
+	}
+	return string(b)
+}
+
+// name returns a new unique name under the configured domain.
+func (g *generator) name() string {
+	for {
+		labels := make([]string, 1+g.rnd.Intn(3))
+		for i := range labels {
+			labels[i] = g.label()
+		}
+		name := strings.Join(labels, ".") + "." + g.cfg.Domain
+		if !g.taken[name] {
+			g.taken[name] = true
+			return name
+		}
+	}
+}
+
+func (g *generator) zone() mockdns.Zone {
+	var z mockdns.Zone
+	// At least one record so CNAME targets are never empty.
+	for len(z.A)+len(z.AAAA)+len(z.TXT)+len(z.MX) == 0 {
+		z.A = g.ips(4)
+		z.AAAA = g.ips(16)
+		for i := g.rnd.Intn(3); i > 0; i-- {
+			z.TXT = append(z.TXT, "v="+g.label())
+		}
+		prefs := g.rnd.Perm(100)
+		for i := g.rnd.Intn(3); i > 0; i-- {
+			z.MX = append(z.MX, net.MX{
+				Host: g.label() + "." + g.cfg.Domain,
+				Pref: uint16(prefs[i]),
+			})
+		}
+	}
+	return z
+}
+
+// ips returns up to 3 distinct addresses from documentation ranges.
+func (g *generator) ips(size int) []string {
+	var out []string
+	for _, host := range g.rnd.Perm(250)[:g.rnd.Intn(4)] {
+		ip := net.IPv4(192, 0, 2, byte(host+1))
+		if size == 16 {
+			ip = net.ParseIP("2001:db8::" + strconv.FormatInt(int64(host+1), 16))
+		}
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+// Answer is the expected result of a lookup, see ZoneSet.Expect.
+type Answer struct {
+	NXDOMAIN bool
+
+	// Canonical is the name at the end of the CNAME chain, if the queried
+	// name is a CNAME.
+	Canonical string
+
+	// Values contains record data of the canonical name, sorted. A and AAAA
+	// values are IP addresses, TXT values are the text and MX values are
+	// "preference host".
+	Values []string
+}
+
+// Expect returns the correct result of a lookup of the name and type in the
+// zone set. Supported types are A, AAAA, TXT and MX.
+//
+// Expect does not use mockdns to compute the answer, it can be used as an
+// oracle to test both mockdns and resolvers querying a mockdns.Server.
+func (zs *ZoneSet) Expect(name string, qtype uint16) Answer {
+	name = dns.Fqdn(strings.ToLower(name))
+
+	var a Answer
+	zone, ok := zs.Zones[name]
+	if !ok {
+		return Answer{NXDOMAIN: true}
+	}
+	for zone.CNAME != "" {
+		a.Canonical = zone.CNAME
+		zone = zs.Zones[zone.CNAME]
+	}
+
+	switch qtype {
+	case dns.TypeA:
+		a.Values = append(a.Values, zone.A...)
+	case dns.TypeAAAA:
+		a.Values = append(a.Values, zone.AAAA...)
+	case dns.TypeTXT:
+		a.Values = append(a.Values, zone.TXT...)
+	case dns.TypeMX:
+		for _, mx := range zone.MX {
+			a.Values = append(a.Values, strconv.Itoa(int(mx.Pref))+" "+mx.Host)
+		}
+	}
+	sort.Strings(a.Values)
+	return a
+}
+
+// ReplyAnswer converts a reply to the query for name into Answer, so it can
+// be compared with the result of ZoneSet.Expect. CNAME chains in the reply
+// are followed to the canonical name.
+func ReplyAnswer(name string, reply *dns.Msg) Answer {
+	var a Answer
+	if reply.Rcode == dns.RcodeNameError {
+		a.NXDOMAIN = true
+	}
+
+	current := dns.Fqdn(strings.ToLower(name))
+	for _, rr := range reply.Answer {
+		owner := strings.ToLower(rr.Header().Name)
+		if owner != current {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.CNAME:
+			current = strings.ToLower(rr.Target)
+			a.Canonical = current
+		case *dns.A:
+			a.Values = append(a.Values, rr.A.String())
+		case *dns.AAAA:
+			a.Values = append(a.Values, rr.AAAA.String())
+		case *dns.TXT:
+			a.Values = append(a.Values, strings.Join(rr.Txt, ""))
+		case *dns.MX:
+			a.Values = append(a.Values, strconv.Itoa(int(rr.Preference))+" "+rr.Mx)
+		}
+	}
+	sort.Strings(a.Values)
+	return a
+}
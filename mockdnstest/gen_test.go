@@ -0,0 +1,70 @@
+package mockdnstest
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+func TestGenerate_Deterministic(t *testing.T) {
+	a := Generate(42, DefaultGenConfig())
+	b := Generate(42, DefaultGenConfig())
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("Different zone sets for the same seed")
+	}
+	if len(a.Names) != 20 || len(a.Missing) != 5 {
+		t.Fatalf("Wrong names count: %d, %d", len(a.Names), len(a.Missing))
+	}
+	for _, name := range a.Missing {
+		if _, ok := a.Zones[name]; ok {
+			t.Fatalf("Missing name %s is present in zones", name)
+		}
+	}
+}
+
+func TestGenerate_Zero(t *testing.T) {
+	zs := Generate(42, GenConfig{Names: 10})
+	if len(zs.Names) != 10 || len(zs.Missing) != 0 {
+		t.Fatalf("Wrong names count: %d, %d", len(zs.Names), len(zs.Missing))
+	}
+	for name, zone := range zs.Zones {
+		if zone.CNAME != "" {
+			t.Fatalf("Wrong result, want no CNAMEs, got %s for %s", zone.CNAME, name)
+		}
+	}
+}
+
+func TestGenerate_Server(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		zs := Generate(seed, GenConfig{Names: 30, CNAMEs: 0.4, Missing: 5})
+
+		srv, err := mockdns.NewServerWithLogger(zs.Zones, log.New(ioutil.Discard, "", 0), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range append(append([]string(nil), zs.Names...), zs.Missing...) {
+			for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeTXT, dns.TypeMX} {
+				m := new(dns.Msg)
+				m.SetQuestion(name, qtype)
+				reply, err := dns.Exchange(m, srv.LocalAddr().String())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := zs.Expect(name, qtype)
+				got := ReplyAnswer(name, reply)
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("seed %d, %s %s: Wrong result, want %+v, got %+v",
+						seed, name, dns.TypeToString[qtype], want, got)
+				}
+			}
+		}
+
+		srv.Close()
+	}
+}
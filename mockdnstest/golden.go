@@ -0,0 +1,220 @@
+package mockdnstest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// UpdateGoldenEnv is the environment variable that makes CompareGolden
+// write golden files instead of comparing against them, if it is set to a
+// non-empty value.
+const UpdateGoldenEnv = "MOCKDNS_UPDATE_GOLDEN"
+
+// FormatMsg renders the DNS message in a canonical text form suitable for
+// golden files.
+//
+// Message ID is omitted and records within each section are sorted, so the
+// output does not depend on randomized values and record order. Use
+// FormatMsgOrdered if the order matters.
+func FormatMsg(m *dns.Msg) string {
+	return formatMsg(m, true)
+}
+
+// FormatMsgOrdered is similar to FormatMsg but keeps records in order.
+func FormatMsgOrdered(m *dns.Msg) string {
+	return formatMsg(m, false)
+}
+
+func formatMsg(m *dns.Msg, sortRRs bool) string {
+	var b strings.Builder
+
+	rcode, ok := dns.RcodeToString[m.Rcode]
+	if !ok {
+		rcode = fmt.Sprintf("RCODE%d", m.Rcode)
+	}
+	fmt.Fprintf(&b, "opcode: %s, rcode: %s, flags:", dns.OpcodeToString[m.Opcode], rcode)
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", m.Response},
+		{"aa", m.Authoritative},
+		{"tc", m.Truncated},
+		{"rd", m.RecursionDesired},
+		{"ra", m.RecursionAvailable},
+		{"ad", m.AuthenticatedData},
+		{"cd", m.CheckingDisabled},
+	} {
+		if f.set {
+			b.WriteString(" " + f.name)
+		}
+	}
+	b.WriteString("\n")
+
+	if len(m.Question) != 0 {
+		b.WriteString("\nQUESTION:\n")
+		for _, q := range m.Question {
+			fmt.Fprintf(&b, "%s %s %s\n", strings.ToLower(q.Name),
+				dns.ClassToString[q.Qclass], dns.TypeToString[q.Qtype])
+		}
+	}
+	for _, section := range []struct {
+		name string
+		rrs  []dns.RR
+	}{
+		{"ANSWER", m.Answer},
+		{"AUTHORITY", m.Ns},
+		{"ADDITIONAL", m.Extra},
+	} {
+		if len(section.rrs) == 0 {
+			continue
+		}
+		lines := make([]string, 0, len(section.rrs))
+		for _, rr := range section.rrs {
+			lines = append(lines, strings.Replace(rr.String(), "\t", " ", -1))
+		}
+		if sortRRs {
+			sort.Strings(lines)
+		}
+		b.WriteString("\n" + section.name + ":\n")
+		for _, l := range lines {
+			b.WriteString(l + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// FormatResult renders the result of a mockdns.Resolver or net.Resolver
+// lookup in a canonical text form suitable for golden files, one value per
+// line in order. Supported result types are string, []string, []net.IP,
+// []net.IPAddr, []*net.MX, []*net.NS and []*net.SRV. Error, if any, is
+// rendered last.
+func FormatResult(result interface{}, err error) string {
+	var lines []string
+	switch result := result.(type) {
+	case nil:
+	case string:
+		if result != "" {
+			lines = append(lines, result)
+		}
+	case []string:
+		lines = append(lines, result...)
+	case []net.IP:
+		for _, ip := range result {
+			lines = append(lines, ip.String())
+		}
+	case []net.IPAddr:
+		for _, ip := range result {
+			lines = append(lines, ip.String())
+		}
+	case []*net.MX:
+		for _, mx := range result {
+			lines = append(lines, fmt.Sprintf("MX %d %s", mx.Pref, mx.Host))
+		}
+	case []*net.NS:
+		for _, ns := range result {
+			lines = append(lines, "NS "+ns.Host)
+		}
+	case []*net.SRV:
+		for _, srv := range result {
+			lines = append(lines, fmt.Sprintf("SRV %d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target))
+		}
+	default:
+		lines = append(lines, fmt.Sprintf("%v", result))
+	}
+
+	if err != nil {
+		dnsErr, ok := err.(*net.DNSError)
+		if ok {
+			lines = append(lines, fmt.Sprintf("error: %s (name: %s, not found: %v, temporary: %v)",
+				dnsErr.Err, dnsErr.Name, dnsErr.IsNotFound, dnsErr.IsTemporary))
+		} else {
+			lines = append(lines, "error: "+err.Error())
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// CompareGolden compares got with the contents of the golden file at path
+// and fails the test with a line diff if they differ.
+//
+// If UpdateGoldenEnv is set, the file is written instead, creating missing
+// directories.
+func CompareGolden(t testing.TB, path string, got string) {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateGoldenEnv)
+	}
+	if string(want) == got {
+		return
+	}
+	t.Errorf("Output differs from %s (-want +got):\n%s", path, Diff(string(want), got))
+}
+
+// Diff returns a line diff between want and got. Lines only in want are
+// prefixed with "-", lines only in got with "+" and common lines with " ".
+func Diff(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			out.WriteString("+" + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("-" + a[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,48 @@
+package mockdnstest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+func TestFormatMsg_Golden(t *testing.T) {
+	srv, err := mockdns.NewServerWithLogger(map[string]mockdns.Zone{
+		"example.org.": {
+			A:  []string{"192.0.2.2", "192.0.2.1"},
+			MX: []net.MX{{Host: "mx.example.org.", Pref: 10}},
+		},
+	}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	CompareGolden(t, filepath.Join("testdata", "example.org-A.golden"), FormatMsg(reply))
+
+	mx, err := srv.Resolver().LookupMX(context.Background(), "example.org")
+	CompareGolden(t, filepath.Join("testdata", "example.org-MX.golden"), FormatResult(mx, err))
+
+	_, err = srv.Resolver().LookupHost(context.Background(), "missing.example.org")
+	CompareGolden(t, filepath.Join("testdata", "missing.golden"), FormatResult(nil, err))
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff("a\nb\nc\n", "a\nx\nc\nd\n")
+	want := " a\n-b\n+x\n c\n+d\n"
+	if got != want {
+		t.Fatalf("Wrong result, want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,175 @@
+// Package mockdnstest contains helpers for testing and benchmarking DNS
+// clients and servers, such as mockdns.Server, without external tools.
+package mockdnstest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// LoadConfig specifies the load generated by Load.
+type LoadConfig struct {
+	// Addr is the address of the DNS server to query. Use
+	// mockdns.Server.LocalAddr().String() to target a mock server.
+	Addr string
+
+	// Net is the transport to use: "udp" (default) or "tcp".
+	Net string
+
+	// QPS is the target rate of queries per second. Zero means no limit,
+	// queries are sent as fast as workers can handle them.
+	QPS int
+
+	// Duration is the time to generate the load for. Load stops earlier if
+	// the context is done. Zero means until the context is done.
+	Duration time.Duration
+
+	// Concurrency is the number of queries that can be in flight at once.
+	// Defaults to 1.
+	Concurrency int
+
+	// Questions are sent in round-robin order, one per query. It must not be
+	// empty.
+	Questions []dns.Question
+
+	// Timeout is the per-query timeout. Defaults to 2 seconds.
+	Timeout time.Duration
+}
+
+// Report is the result of Load.
+type Report struct {
+	// Sent is the number of queries sent, Errors is the number of queries
+	// that failed (e.g. timed out) and got no reply.
+	Sent   int
+	Errors int
+
+	// Rcodes is the number of replies received for each response code.
+	Rcodes map[int]int
+
+	// Elapsed is the time the load was generated for.
+	Elapsed time.Duration
+
+	// latencies are round-trip times of successful queries, sorted.
+	latencies []time.Duration
+}
+
+// QPS returns the achieved rate of queries per second.
+func (r Report) QPS() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Elapsed.Seconds()
+}
+
+// Percentile returns the p-th percentile (0 < p <= 100) of round-trip time
+// of successful queries. Zero is returned if no queries succeeded.
+func (r Report) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	// Nearest-rank method.
+	rank := int(p/100*float64(len(r.latencies))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(r.latencies) {
+		rank = len(r.latencies) - 1
+	}
+	return r.latencies[rank]
+}
+
+// Load sends queries to the server as specified by cfg and reports the
+// results.
+func Load(ctx context.Context, cfg LoadConfig) (Report, error) {
+	if len(cfg.Questions) == 0 {
+		return Report{}, errors.New("no questions to send")
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.Net == "" {
+		cfg.Net = "udp"
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	// Each value sent to tokens permits one query.
+	tokens := make(chan dns.Question)
+	go func() {
+		defer close(tokens)
+
+		var tick <-chan time.Time
+		if cfg.QPS > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(cfg.QPS))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i := 0; ; i++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case tokens <- cfg.Questions[i%len(cfg.Questions)]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu  sync.Mutex
+		rep = Report{Rcodes: make(map[int]int)}
+		wg  sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cl := dns.Client{Net: cfg.Net, Timeout: cfg.Timeout}
+			for q := range tokens {
+				m := new(dns.Msg)
+				m.Id = dns.Id()
+				m.RecursionDesired = true
+				m.Question = []dns.Question{q}
+
+				reply, rtt, err := cl.Exchange(m, cfg.Addr)
+
+				mu.Lock()
+				rep.Sent++
+				if err != nil {
+					rep.Errors++
+				} else {
+					rep.Rcodes[reply.Rcode]++
+					rep.latencies = append(rep.latencies, rtt)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	rep.Elapsed = time.Since(start)
+
+	sort.Slice(rep.latencies, func(i, j int) bool { return rep.latencies[i] < rep.latencies[j] })
+
+	return rep, nil
+}
@@ -0,0 +1,55 @@
+package mockdnstest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/miekg/dns"
+)
+
+func TestLoad(t *testing.T) {
+	srv, err := mockdns.NewServerWithLogger(map[string]mockdns.Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	rep, err := Load(context.Background(), LoadConfig{
+		Addr:        srv.LocalAddr().String(),
+		QPS:         200,
+		Duration:    250 * time.Millisecond,
+		Concurrency: 4,
+		Questions: []dns.Question{
+			{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+			{Name: "nonexistent.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Sent == 0 {
+		t.Fatal("No queries sent")
+	}
+	if rep.Errors != 0 {
+		t.Errorf("Unexpected errors: %v", rep.Errors)
+	}
+	if rep.Rcodes[dns.RcodeSuccess] == 0 || rep.Rcodes[dns.RcodeNameError] == 0 {
+		t.Errorf("Wrong rcodes: %v", rep.Rcodes)
+	}
+	// Leave a lot of room for slow CI machines.
+	if rep.Sent > 60 {
+		t.Errorf("QPS limit is not respected, sent %v queries", rep.Sent)
+	}
+	if p50, p99 := rep.Percentile(50), rep.Percentile(99); p50 <= 0 || p99 < p50 {
+		t.Errorf("Wrong percentiles: p50 = %v, p99 = %v", p50, p99)
+	}
+}
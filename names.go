@@ -0,0 +1,157 @@
+package mockdns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// normalizeName returns the canonical form of name used as a key in Zones and
+// Hosts: lower-case and fully qualified. All lookups go through it so
+// "example.org" and "Example.ORG." are the same name.
+func normalizeName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
+
+// zone returns the zone for name. Zones keys are expected to be
+// normalized, but keys without the trailing dot are accepted too. Bulk is
+// consulted if there is no such zone in Zones.
+func (r *Resolver) zone(name string) (Zone, bool) {
+	zone, ok := r.lookupZone(name)
+	if ok && r.Region != "" {
+		if regZone, ok := zone.Regions[r.Region]; ok {
+			return regZone, true
+		}
+	}
+	return zone, ok
+}
+
+func (r *Resolver) lookupZone(name string) (Zone, bool) {
+	name = normalizeName(name)
+	if zone, ok := r.getZone(name); ok {
+		return zone, true
+	}
+	if name != "." {
+		if zone, ok := r.getZone(strings.TrimSuffix(name, ".")); ok {
+			return zone, true
+		}
+	}
+	if r.Bulk != nil {
+		return r.Bulk.zone(name)
+	}
+	return Zone{}, false
+}
+
+// isDomainName reports whether s is a syntactically valid domain name using
+// the same rules as the net package: labels consist of letters, digits,
+// underscores and hyphens, are at most 63 octets long, do not start or end
+// with a hyphen and the name is not entirely numeric.
+func isDomainName(s string) bool {
+	if s == "." {
+		return true
+	}
+	return validName(s, true)
+}
+
+// isHostName reports whether s is a valid host name (RFC 952, RFC 1123) as
+// checked by libc resolvers (res_hnok): same as isDomainName, but without
+// underscores and the root name.
+func isHostName(s string) bool {
+	return validName(s, false)
+}
+
+func validName(s string, underscore bool) bool {
+
+	// The presentation format allows 253 octets plus the trailing dot.
+	l := len(s)
+	if l == 0 || l > 254 || l == 254 && s[l-1] != '.' {
+		return false
+	}
+
+	last := byte('.')
+	nonNumeric := false
+	labelLen := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' && underscore:
+			nonNumeric = true
+			labelLen++
+		case '0' <= c && c <= '9':
+			labelLen++
+		case c == '-':
+			if last == '.' {
+				return false
+			}
+			nonNumeric = true
+			labelLen++
+		case c == '.':
+			if last == '.' || last == '-' || labelLen == 0 || labelLen > 63 {
+				return false
+			}
+			labelLen = 0
+		default:
+			return false
+		}
+		last = c
+	}
+
+	return last != '-' && labelLen <= 63 && nonNumeric
+}
+
+// ReverseName returns the reverse lookup name of ip: in the in-addr.arpa.
+// domain for IPv4 (and IPv4-mapped IPv6) addresses and in the ip6.arpa.
+// domain, in nibble format, for IPv6 addresses. It can be used as a key of
+// zones with PTR records. Empty string is returned if ip is invalid.
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return strconv.Itoa(int(ip4[3])) + "." + strconv.Itoa(int(ip4[2])) + "." +
+			strconv.Itoa(int(ip4[1])) + "." + strconv.Itoa(int(ip4[0])) + ".in-addr.arpa."
+	}
+	if len(ip) != net.IPv6len {
+		return ""
+	}
+
+	const hexDigits = "0123456789abcdef"
+	buf := make([]byte, 0, len("x.")*32+len("ip6.arpa."))
+	for i := len(ip) - 1; i >= 0; i-- {
+		buf = append(buf, hexDigits[ip[i]&0xf], '.', hexDigits[ip[i]>>4], '.')
+	}
+	return string(append(buf, "ip6.arpa."...))
+}
+
+// checkName returns the error net.Resolver returns for the malformed name or
+// nil if name is valid.
+func checkName(name string) error {
+	if !isDomainName(name) {
+		return noSuchHost(name)
+	}
+	return nil
+}
+
+// checkHost is checkName for address lookups, which also checks host and the
+// name it resolves to against isHostName if StrictNames is set.
+func (r *Resolver) checkHost(host string) error {
+	if r.StrictNames && !isHostName(host) {
+		return noSuchHost(host)
+	}
+	return checkName(host)
+}
+
+// ipLiteral returns addresses to use for host if it is an IP literal and
+// whether it is one at all. The error is returned if the address does not
+// match network, like net.Resolver does.
+func ipLiteral(network, host string) ([]string, bool, error) {
+	ip, _ := parseIPZone(host)
+	if ip == nil {
+		return nil, false, nil
+	}
+
+	is4 := ip.To4() != nil
+	if network == "ip4" && !is4 || network == "ip6" && is4 {
+		return nil, true, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return []string{host}, true, nil
+}
@@ -0,0 +1,200 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIsDomainName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		".":               true,
+		"example.org":     true,
+		"example.org.":    true,
+		"_srv._tcp.a.b.":  true,
+		"a-b.example.":    true,
+		"":                false,
+		"..":              false,
+		"a..b":            false,
+		"-a.example.":     false,
+		"a-.example.":     false,
+		"1234":            false,
+		"exa mple.org":    false,
+		"example.org/foo": false,
+	} {
+		if got := isDomainName(name); got != valid {
+			t.Errorf("isDomainName(%q): want %v, got %v", name, valid, got)
+		}
+	}
+
+	for name, valid := range map[string]bool{
+		".":              false,
+		"example.org.":   true,
+		"_srv._tcp.a.b.": false,
+		"my_host.a.b.":   false,
+	} {
+		if got := isHostName(name); got != valid {
+			t.Errorf("isHostName(%q): want %v, got %v", name, valid, got)
+		}
+	}
+}
+
+func TestResolver_MalformedNames(t *testing.T) {
+	r := Resolver{}
+
+	addrs, err := r.LookupHost(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	_, err = r.LookupIP(context.Background(), "ip4", "::1")
+	if addrErr, ok := err.(*net.AddrError); !ok || addrErr.Err != "no suitable address found" {
+		t.Fatalf("Wrong error for mismatched IP literal: %#v", err)
+	}
+
+	for _, name := range []string{"", "exa mple.org", "a..b"} {
+		_, err := r.LookupHost(context.Background(), name)
+		dnsErr, ok := err.(*net.DNSError)
+		if !ok || !dnsErr.IsNotFound || dnsErr.Name != name || dnsErr.Err != "no such host" {
+			t.Errorf("Wrong error for %q: %#v", name, err)
+		}
+
+		_, err = r.LookupMX(context.Background(), name)
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			t.Errorf("Wrong error for MX %q: %#v", name, err)
+		}
+	}
+
+	_, err = r.LookupAddr(context.Background(), "not-an-ip")
+	if dnsErr, ok := err.(*net.DNSError); !ok || dnsErr.Err != "unrecognized address" {
+		t.Fatalf("Wrong error for LookupAddr: %#v", err)
+	}
+}
+
+func TestResolver_StrictNames(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{
+		"my_host.example.org.": {
+			A:   []string{"192.0.2.1"},
+			TXT: []string{"text"},
+		},
+		"alias.example.org.": {
+			CNAME: "my_host.example.org.",
+		},
+	}}
+
+	if _, err := r.LookupHost(context.Background(), "my_host.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.StrictNames = true
+
+	for _, name := range []string{"my_host.example.org", "alias.example.org", "."} {
+		_, err := r.LookupHost(context.Background(), name)
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound || dnsErr.Err != "no such host" {
+			t.Errorf("Wrong error for %q: %#v", name, err)
+		}
+	}
+
+	// Other lookups permit underscores.
+	if _, err := r.LookupTXT(context.Background(), "my_host.example.org"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolver_TrailingDot(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			CNAME: "Target.Example.ORG",
+		},
+		// Key without the trailing dot.
+		"target.example.org": {
+			A:   []string{"192.0.2.1"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			NS:  []net.NS{{Host: "ns.example.org."}},
+			TXT: []string{"text"},
+		},
+		"1.2.0.192.in-addr.arpa": {
+			PTR: []string{"target.example.org."},
+		},
+	}
+	r := Resolver{Zones: zones}
+
+	for _, name := range []string{"example.org", "example.org.", "EXAMPLE.org"} {
+		addrs, err := r.LookupHost(context.Background(), name)
+		if err != nil {
+			t.Fatalf("LookupHost(%q): %v", name, err)
+		}
+		if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+			t.Errorf("LookupHost(%q): want %v, got %v", name, want, addrs)
+		}
+		if _, err := r.LookupMX(context.Background(), name); err != nil {
+			t.Errorf("LookupMX(%q): %v", name, err)
+		}
+		if _, err := r.LookupNS(context.Background(), name); err != nil {
+			t.Errorf("LookupNS(%q): %v", name, err)
+		}
+		if _, err := r.LookupTXT(context.Background(), name); err != nil {
+			t.Errorf("LookupTXT(%q): %v", name, err)
+		}
+		cname, err := r.LookupCNAME(context.Background(), name)
+		if err != nil {
+			t.Errorf("LookupCNAME(%q): %v", name, err)
+		}
+		if cname != "Target.Example.ORG" {
+			t.Errorf("LookupCNAME(%q): want %v, got %v", name, "Target.Example.ORG", cname)
+		}
+	}
+
+	names, err := r.LookupAddr(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"target.example.org."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, names)
+	}
+
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	for _, name := range []string{"example.org", "EXAMPLE.ORG."} {
+		addrs, err := netR.LookupHost(context.Background(), name)
+		if err != nil {
+			t.Fatalf("net.Resolver LookupHost(%q): %v", name, err)
+		}
+		if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+			t.Errorf("net.Resolver LookupHost(%q): want %v, got %v", name, want, addrs)
+		}
+	}
+	names, err = netR.LookupAddr(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"target.example.org."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Wrong net.Resolver result, want %v, got %v", want, names)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	for ip, want := range map[string]string{
+		"192.0.2.1":        "1.2.0.192.in-addr.arpa.",
+		"::ffff:192.0.2.1": "1.2.0.192.in-addr.arpa.",
+		"2001:db8::1":      "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		if got := ReverseName(net.ParseIP(ip)); got != want {
+			t.Errorf("ReverseName(%v): want %v, got %v", ip, want, got)
+		}
+	}
+	if got := ReverseName(nil); got != "" {
+		t.Errorf("Wrong result for nil IP, want empty string, got %v", got)
+	}
+}
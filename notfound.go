@@ -8,7 +8,17 @@ func notFound(host string) error {
 	return &net.DNSError{
 		Err:        "no such host",
 		Name:       host,
-		Server:     "127.0.0.1:53",
+		Server:     defaultErrServer,
+		IsNotFound: true,
+	}
+}
+
+// noSuchHost returns the error net.Resolver returns for names it refuses to
+// look up (empty or malformed).
+func noSuchHost(host string) error {
+	return &net.DNSError{
+		Err:        "no such host",
+		Name:       host,
 		IsNotFound: true,
 	}
 }
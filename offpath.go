@@ -0,0 +1,39 @@
+package mockdns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// sendOffPath sends the reply returned by Server.OffPath to the client from
+// a different local port. Only UDP queries are affected.
+func (s *Server) sendOffPath(w dns.ResponseWriter, query, reply *dns.Msg) {
+	if s.OffPath == nil {
+		return
+	}
+	client, ok := w.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return
+	}
+
+	spoofed := s.OffPath(query, reply.Copy())
+	if spoofed == nil {
+		return
+	}
+	packed, err := spoofed.Pack()
+	if err != nil {
+		s.Log.Printf("off-path reply: %v", err)
+		return
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		s.Log.Printf("off-path reply: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(packed, client); err != nil {
+		s.Log.Printf("off-path reply: %v", err)
+	}
+}
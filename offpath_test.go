@@ -0,0 +1,67 @@
+package mockdns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_OffPath(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.OffPath = func(query, reply *dns.Msg) *dns.Msg {
+			reply.Answer[0].(*dns.A).A = net.IPv4(203, 0, 113, 1)
+			return reply
+		}
+	})
+	defer srv.Close()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvAddr := srv.LocalAddr().(*net.UDPAddr)
+	if _, err := conn.WriteToUDP(packed, srvAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Off-path reply comes first, from another port.
+	buf := make([]byte, 512)
+	for i, want := range []string{"203.0.113.1", "192.0.2.1"} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sameSource := from.Port == srvAddr.Port; sameSource != (i == 1) {
+			t.Errorf("Reply %d: wrong source port %v", i, from.Port)
+		}
+		reply := new(dns.Msg)
+		if err := reply.Unpack(buf[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if got := reply.Answer[0].(*dns.A).A.String(); got != want {
+			t.Errorf("Reply %d: wrong address, want %v, got %v", i, want, got)
+		}
+	}
+
+	// Cached answers are not modified.
+	reply, err := dns.Exchange(m, srvAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reply.Answer[0].(*dns.A).A.String(); got != "192.0.2.1" {
+		t.Fatalf("Wrong address, want %v, got %v", "192.0.2.1", got)
+	}
+}
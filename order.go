@@ -0,0 +1,51 @@
+package mockdns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// CheckOrder verifies that queries for the specified questions were received
+// by the Server in the specified order. Only Name and Qtype of questions are
+// compared and only the first query for each question is considered.
+//
+// Query for a question is allowed to be received up to window before the
+// query for the previous question. That is, non-zero window makes it
+// possible to accept queries sent concurrently (e.g. A and AAAA lookups
+// done in parallel) while still rejecting queries sent strictly in the
+// wrong order.
+func (s *Server) CheckOrder(window time.Duration, questions ...dns.Question) error {
+	queries := s.Queries()
+
+	var prev time.Time
+	for i, q := range questions {
+		name := normalizeName(q.Name)
+
+		var (
+			found    bool
+			received time.Time
+		)
+		for _, info := range queries {
+			if normalizeName(info.Question.Name) == name && info.Question.Qtype == q.Qtype {
+				found = true
+				received = info.Time
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no query for %s %v", name, dns.Type(q.Qtype))
+		}
+
+		if i != 0 && received.Add(window).Before(prev) {
+			prevQ := questions[i-1]
+			return fmt.Errorf("query for %s %v received %v before query for %s %v",
+				name, dns.Type(q.Qtype), prev.Sub(received),
+				normalizeName(prevQ.Name), dns.Type(prevQ.Qtype))
+		}
+		prev = received
+	}
+
+	return nil
+}
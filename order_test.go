@@ -0,0 +1,51 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_CheckOrder(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"_sip._tcp.example.org.": {
+			SRV: []net.SRV{{Target: "sip.example.org.", Port: 5060}},
+		},
+		"sip.example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var r net.Resolver
+	srv.PatchNet(&r)
+
+	if _, _, err := r.LookupSRV(context.Background(), "sip", "tcp", "example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.LookupIP(context.Background(), "ip4", "sip.example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	srvQ := dns.Question{Name: "_sip._tcp.example.org.", Qtype: dns.TypeSRV}
+	aQ := dns.Question{Name: "sip.example.org", Qtype: dns.TypeA}
+
+	if err := srv.CheckOrder(0, srvQ, aQ); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := srv.CheckOrder(0, aQ, srvQ); err == nil {
+		t.Errorf("Expected error for wrong order, got nil")
+	}
+	if err := srv.CheckOrder(time.Hour, aQ, srvQ); err != nil {
+		t.Errorf("Unexpected error with large window: %v", err)
+	}
+	if err := srv.CheckOrder(0, srvQ, dns.Question{Name: "sip.example.org.", Qtype: dns.TypeAAAA}); err == nil {
+		t.Errorf("Expected error for missing query, got nil")
+	}
+}
@@ -0,0 +1,61 @@
+package mockdns
+
+import (
+	"strings"
+)
+
+// NamePolicy specifies how Resolver handles single-label names (e.g.
+// "localhost") and the root name.
+type NamePolicy int
+
+const (
+	// NameAsIs looks up the name in Zones as any other name. This is the
+	// default.
+	NameAsIs NamePolicy = iota
+	// NameHostsOnly resolves the name only using Resolver.Hosts, all other
+	// lookups fail with "no such host".
+	NameHostsOnly
+	// NameNXDOMAIN makes all lookups for the name fail with "no such host".
+	NameNXDOMAIN
+	// NameSearch appends each of Resolver.Search suffixes to the name and
+	// uses the first one that exists in Zones, falling back to the name
+	// itself. It is the same as NameAsIs for the root name.
+	NameSearch
+)
+
+// namePolicy returns the policy to use for name.
+func (r *Resolver) namePolicy(name string) NamePolicy {
+	switch {
+	case name == ".":
+		return r.RootPolicy
+	case !strings.Contains(strings.TrimSuffix(name, "."), "."):
+		return r.SingleLabelPolicy
+	default:
+		return NameAsIs
+	}
+}
+
+// applyNamePolicy returns the name to look up in Zones instead of name
+// according to RootPolicy and SingleLabelPolicy.
+//
+// Hosts overlay is consulted before the policy is applied so NameHostsOnly
+// results in an error here.
+func (r *Resolver) applyNamePolicy(name string) (string, error) {
+	policy := r.namePolicy(name)
+	switch policy {
+	case NameHostsOnly, NameNXDOMAIN:
+		return "", notFound(name)
+	case NameSearch:
+		if name == "." {
+			return name, nil
+		}
+		label := strings.TrimSuffix(name, ".")
+		for _, suffix := range r.Search {
+			cand := normalizeName(label + "." + strings.Trim(suffix, "."))
+			if zone, ok := r.zone(cand); ok && !zone.NXDOMAIN {
+				return cand, nil
+			}
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,84 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestResolver_NamePolicy(t *testing.T) {
+	zones := map[string]Zone{
+		"myhost.": {
+			A: []string{"192.0.2.1"},
+		},
+		"myhost.corp.example.": {
+			A:   []string{"192.0.2.2"},
+			TXT: []string{"corp"},
+		},
+		".": {
+			NS: []net.NS{{Host: "a.root-servers.net."}},
+		},
+	}
+	hosts := map[string][]string{
+		"localhost": {"127.0.0.1"},
+	}
+
+	cases := []struct {
+		policy NamePolicy
+		host   string
+		want   []string
+	}{
+		{NameAsIs, "myhost", []string{"192.0.2.1"}},
+		{NameAsIs, "localhost", []string{"127.0.0.1"}},
+		{NameHostsOnly, "myhost", nil},
+		{NameHostsOnly, "localhost", []string{"127.0.0.1"}},
+		{NameNXDOMAIN, "myhost.", nil},
+		{NameSearch, "myhost", []string{"192.0.2.2"}},
+		{NameSearch, "myhost.", []string{"192.0.2.2"}},
+	}
+	for _, c := range cases {
+		r := Resolver{
+			Zones:             zones,
+			Hosts:             hosts,
+			SingleLabelPolicy: c.policy,
+			Search:            []string{"nonexistent.example", "corp.example."},
+		}
+		addrs, err := r.LookupHost(context.Background(), c.host)
+		if c.want == nil {
+			if dnsErr, ok := err.(*net.DNSError); !ok || !isNotFound(dnsErr) {
+				t.Errorf("Expected not found error for %v with policy %v, got %v", c.host, c.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %v with policy %v: %v", c.host, c.policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(addrs, c.want) {
+			t.Errorf("Wrong result for %v with policy %v, want %v, got %v", c.host, c.policy, c.want, addrs)
+		}
+	}
+
+	r := Resolver{Zones: zones, SingleLabelPolicy: NameSearch, Search: []string{"corp.example"}}
+	txt, err := r.LookupTXT(context.Background(), "myhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"corp"}; !reflect.DeepEqual(txt, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, txt)
+	}
+
+	r = Resolver{Zones: zones, RootPolicy: NameNXDOMAIN}
+	if _, err := r.LookupNS(context.Background(), "."); err == nil {
+		t.Fatal("Expected error for root name with NameNXDOMAIN")
+	}
+	r.RootPolicy = NameAsIs
+	ns, err := r.LookupNS(context.Background(), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ns) != 1 || ns[0].Host != "a.root-servers.net." {
+		t.Fatalf("Wrong result: %v", ns)
+	}
+}
@@ -0,0 +1,116 @@
+package mockdns
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxPooledBuffer is the size of the largest buffer kept in bufPool, large
+// buffers used for rare big replies are left to GC.
+const maxPooledBuffer = 64 * 1024
+
+var (
+	bufPool = sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, 0, 4096)
+			return &buf
+		},
+	}
+	replyPool = sync.Pool{
+		New: func() interface{} {
+			return &pooledReply{
+				answer: make([]dns.RR, 0, 8),
+				ns:     make([]dns.RR, 0, 2),
+				extra:  make([]dns.RR, 0, 8),
+			}
+		},
+	}
+)
+
+// pooledReply is the reply message along with section slices owned by it.
+// Sections of the message can be also replaced with slices shared with
+// cached answers or returned by callbacks, so only owned ones are reused.
+type pooledReply struct {
+	msg               dns.Msg
+	answer, ns, extra []dns.RR
+}
+
+// recycling reports whether replies are reused. RecycleReplies is read once,
+// so replies are never released differently than they were created.
+func (s *Server) recycling() bool {
+	s.recycleOnce.Do(func() {
+		s.recycle = s.RecycleReplies
+	})
+	return s.recycle
+}
+
+// newReply returns the message to use for a reply. See RecycleReplies.
+func (s *Server) newReply() *pooledReply {
+	if !s.recycling() {
+		return &pooledReply{}
+	}
+	p := replyPool.Get().(*pooledReply)
+	p.msg.Answer, p.msg.Ns, p.msg.Extra = p.answer, p.ns, p.extra
+	return p
+}
+
+// releaseReply puts the reply message back to the pool if RecycleReplies is
+// set. The reply must not be used after that.
+func (s *Server) releaseReply(p *pooledReply) {
+	if !s.recycling() {
+		return
+	}
+	p.answer = reuseSection(p.answer, p.msg.Answer)
+	p.ns = reuseSection(p.ns, p.msg.Ns)
+	p.extra = reuseSection(p.extra, p.msg.Extra)
+	p.msg = dns.Msg{}
+	replyPool.Put(p)
+}
+
+// reuseSection returns section truncated for reuse if it is backed by the
+// owned array, owned truncated otherwise. Records are cleared, so they are
+// not kept alive by the pool.
+func reuseSection(owned, section []dns.RR) []dns.RR {
+	if cap(section) != 0 && &section[:cap(section)][cap(section)-1] == &owned[:cap(owned)][cap(owned)-1] {
+		owned = section
+	}
+	owned = owned[:cap(owned)]
+	for i := range owned {
+		owned[i] = nil
+	}
+	return owned[:0]
+}
+
+// writeMsg packs the reply using a pooled buffer and writes it.
+func (s *Server) writeMsg(w dns.ResponseWriter, query, reply *dns.Msg) error {
+	bufPtr := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufPtr)
+
+	start := time.Now()
+	packed, err := reply.PackBuffer((*bufPtr)[:cap(*bufPtr)])
+	if err != nil {
+		return err
+	}
+	if cap(packed) > cap(*bufPtr) && cap(packed) <= maxPooledBuffer {
+		*bufPtr = packed[:0]
+	}
+	packed, err = s.limitSize(w, reply, packed)
+	if err != nil || packed == nil {
+		return err
+	}
+	return s.writePacked(w, query, packed, start)
+}
+
+// writePacked finishes the encode stage started at start and writes packed.
+func (s *Server) writePacked(w dns.ResponseWriter, query *dns.Msg, packed []byte, start time.Time) error {
+	s.emitStage(StageEncode, query, start)
+
+	packed = s.fuzzReply(packed)
+
+	start = time.Now()
+	_, err := w.Write(packed)
+	s.emitStage(StageWrite, query, start)
+	return err
+}
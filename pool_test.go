@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_RecycleReplies(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			A:   []string{"192.0.2.1"},
+			TXT: []string{"text"},
+		},
+	}, func(s *Server) {
+		s.RecycleReplies = true
+	})
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	for i := 0; i < 10; i++ {
+		addrs, err := netR.LookupHost(context.Background(), "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+			t.Fatalf("Wrong result, want %v, got %v", want, addrs)
+		}
+
+		txt, err := netR.LookupTXT(context.Background(), "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"text"}; !reflect.DeepEqual(txt, want) {
+			t.Fatalf("Wrong result, want %v, got %v", want, txt)
+		}
+
+		if _, err := netR.LookupHost(context.Background(), "nonexistent.org"); err == nil {
+			t.Fatal("Expected error for nonexistent name")
+		}
+	}
+
+	for _, info := range srv.Queries() {
+		if info.Reply != nil {
+			t.Fatal("Recycled reply is retained in the query log")
+		}
+	}
+}
+
+func TestServer_ReleaseReply(t *testing.T) {
+	s := Server{RecycleReplies: true}
+
+	shared := make([]dns.RR, 1, 4)
+	shared[0] = &dns.A{Hdr: dns.RR_Header{Name: "example.org."}}
+
+	reply := s.newReply()
+	owned := reply.msg.Extra
+	reply.msg.Answer = shared
+	reply.msg.Extra = append(reply.msg.Extra, &dns.OPT{})
+	s.releaseReply(reply)
+
+	if shared[0] == nil {
+		t.Fatal("Shared section is cleared")
+	}
+	if reply.extra[:1][0] != nil || &reply.extra[:1][0] != &owned[:1][0] {
+		t.Fatal("Owned section is not reused")
+	}
+}
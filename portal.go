@@ -0,0 +1,40 @@
+package mockdns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Hijacking resolvers and captive portals tend to use short TTLs.
+const (
+	hijackTTL  = 60
+	captiveTTL = 0
+)
+
+// portalRRs returns A or AAAA records for the question with the addresses
+// of the matching family. It returns nil for questions of other types.
+func (s *Server) portalRRs(q dns.Question, addrs []string, ttl uint32) []dns.RR {
+	if q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA {
+		return nil
+	}
+
+	var rrs []dns.RR
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			s.Log.Printf("malformed portal address: %s", addr)
+			continue
+		}
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: ttl}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			if q.Qtype == dns.TypeA {
+				rrs = append(rrs, &dns.A{Hdr: hdr, A: ip4})
+			}
+		} else if q.Qtype == dns.TypeAAAA {
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return rrs
+}
@@ -0,0 +1,61 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_HijackNXDOMAIN(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.HijackNXDOMAIN = []string{"198.51.100.80"}
+	})
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeSuccess || len(reply.Answer) != 1 {
+		t.Fatalf("Reply is not hijacked: %v", reply)
+	}
+	if a := reply.Answer[0].(*dns.A); a.A.String() != "198.51.100.80" {
+		t.Fatalf("Wrong result, want %v, got %v", "198.51.100.80", a.A)
+	}
+
+	// No IPv6 portal address.
+	reply = queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeAAAA)
+	if reply.Rcode != dns.RcodeNameError {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeNameError, reply.Rcode)
+	}
+	reply = queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeMX)
+	if reply.Rcode != dns.RcodeNameError {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeNameError, reply.Rcode)
+	}
+
+	// Existing names are not affected.
+	reply = queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+}
+
+func TestServer_CaptivePortal(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.CaptivePortal = []string{"10.0.0.1"}
+	})
+	defer srv.Close()
+
+	for _, name := range []string{"example.org.", "connectivitycheck.example.net."} {
+		reply := queryFrom(t, srv, "127.0.0.1", name, dns.TypeA)
+		if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+			t.Fatalf("%s: Wrong answer: %v", name, reply.Answer)
+		}
+	}
+
+	reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeAAAA)
+	if reply.Rcode != dns.RcodeSuccess || len(reply.Answer) != 0 || len(reply.Ns) != 1 {
+		t.Fatalf("Not a NODATA reply: %v", reply)
+	}
+}
@@ -0,0 +1,80 @@
+package mockdns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultServices is the service table used by LookupPort if
+// Resolver.Services is nil. Keys are in "network/service" form, where network
+// is "tcp" or "udp".
+var DefaultServices = map[string]int{
+	"tcp/ftp":         21,
+	"tcp/ssh":         22,
+	"tcp/telnet":      23,
+	"tcp/smtp":        25,
+	"tcp/domain":      53,
+	"udp/domain":      53,
+	"tcp/http":        80,
+	"tcp/pop3":        110,
+	"tcp/imap":        143,
+	"tcp/imap2":       143,
+	"udp/ntp":         123,
+	"tcp/ldap":        389,
+	"tcp/https":       443,
+	"udp/https":       443,
+	"tcp/submissions": 465,
+	"tcp/smtps":       465,
+	"udp/syslog":      514,
+	"tcp/submission":  587,
+	"tcp/ldaps":       636,
+	"tcp/imaps":       993,
+	"tcp/pop3s":       995,
+	"tcp/sieve":       4190,
+}
+
+// lookupPort implements LookupPort using Services (or DefaultServices),
+// mirroring net.LookupPort errors.
+func (r *Resolver) lookupPort(network, service string) (int, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		network = "tcp"
+	case "udp", "udp4", "udp6":
+		network = "udp"
+	case "":
+		// Same as net.LookupPort: try TCP first, then UDP.
+		port, err := r.lookupPort("tcp", service)
+		if err != nil {
+			return r.lookupPort("udp", service)
+		}
+		return port, nil
+	default:
+		return 0, &net.AddrError{Err: "unknown network", Addr: network}
+	}
+
+	if service == "" {
+		return 0, nil
+	}
+
+	if port, err := strconv.Atoi(service); err == nil {
+		if port < 0 || port > 0xFFFF {
+			return 0, &net.AddrError{Err: "invalid port", Addr: service}
+		}
+		return port, nil
+	}
+
+	services := r.Services
+	if services == nil {
+		services = DefaultServices
+	}
+	if port, ok := services[network+"/"+strings.ToLower(service)]; ok {
+		return port, nil
+	}
+
+	return 0, &net.DNSError{
+		Err:        "unknown port",
+		Name:       network + "/" + service,
+		IsNotFound: true,
+	}
+}
@@ -0,0 +1,49 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestResolver_LookupPort(t *testing.T) {
+	r := Resolver{}
+
+	port, err := r.LookupPort(context.Background(), "tcp", "submission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 587 {
+		t.Fatalf("Wrong result, want %v, got %v", 587, port)
+	}
+
+	port, err = r.LookupPort(context.Background(), "tcp", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 1234 {
+		t.Fatalf("Wrong result, want %v, got %v", 1234, port)
+	}
+
+	_, err = r.LookupPort(context.Background(), "udp", "submission")
+	dnsErr, ok := err.(*net.DNSError)
+	if !ok || !dnsErr.IsNotFound || dnsErr.Name != "udp/submission" {
+		t.Fatalf("Wrong error: %#v", err)
+	}
+
+	if _, err := r.LookupPort(context.Background(), "ip", "http"); err == nil {
+		t.Fatal("Expected error for unknown network")
+	}
+
+	r.Services = map[string]int{"tcp/custom": 4242}
+	port, err = r.LookupPort(context.Background(), "tcp4", "Custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 4242 {
+		t.Fatalf("Wrong result, want %v, got %v", 4242, port)
+	}
+	if _, err := r.LookupPort(context.Background(), "tcp", "http"); err == nil {
+		t.Fatal("Expected DefaultServices to be ignored when Services is set")
+	}
+}
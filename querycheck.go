@@ -0,0 +1,120 @@
+package mockdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// QueryCheck verifies a property of an incoming query. It returns a non-nil
+// error describing the violation if query does not satisfy it.
+type QueryCheck func(m *dns.Msg) error
+
+// RequireRD returns the QueryCheck that requires the Recursion Desired (RD)
+// bit to be set.
+func RequireRD() QueryCheck {
+	return func(m *dns.Msg) error {
+		if !m.RecursionDesired {
+			return errors.New("RD bit is not set")
+		}
+		return nil
+	}
+}
+
+// RequireEDNS returns the QueryCheck that requires the query to contain the
+// EDNS OPT record advertising UDP buffer size of at least minBufSize.
+func RequireEDNS(minBufSize uint16) QueryCheck {
+	return func(m *dns.Msg) error {
+		opt := m.IsEdns0()
+		if opt == nil {
+			return errors.New("EDNS is not used")
+		}
+		if opt.UDPSize() < minBufSize {
+			return fmt.Errorf("EDNS buffer size %d is less than %d", opt.UDPSize(), minBufSize)
+		}
+		return nil
+	}
+}
+
+// RequireDO returns the QueryCheck that requires the DNSSEC OK (DO) bit to
+// be set. It implies EDNS usage.
+func RequireDO() QueryCheck {
+	return func(m *dns.Msg) error {
+		opt := m.IsEdns0()
+		if opt == nil {
+			return errors.New("EDNS is not used")
+		}
+		if !opt.Do() {
+			return errors.New("DO bit is not set")
+		}
+		return nil
+	}
+}
+
+// RequireClass returns the QueryCheck that requires all questions to use
+// the specified class.
+func RequireClass(class uint16) QueryCheck {
+	return func(m *dns.Msg) error {
+		for _, q := range m.Question {
+			if q.Qclass != class {
+				return fmt.Errorf("wrong class %v, want %v", dns.Class(q.Qclass), dns.Class(class))
+			}
+		}
+		return nil
+	}
+}
+
+// ForbidANY returns the QueryCheck that rejects queries for the ANY type.
+func ForbidANY() QueryCheck {
+	return func(m *dns.Msg) error {
+		for _, q := range m.Question {
+			if q.Qtype == dns.TypeANY {
+				return errors.New("ANY query")
+			}
+		}
+		return nil
+	}
+}
+
+// CheckQueries adds checks that are applied to each query received by the
+// Server. Queries violating any check are answered with REFUSED and the
+// violation is recorded, see QueryViolations.
+func (s *Server) CheckQueries(checks ...QueryCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, checks...)
+}
+
+// QueryViolations returns the errors describing all queries that failed
+// checks added using CheckQueries.
+func (s *Server) QueryViolations() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]error, len(s.violations))
+	copy(res, s.violations)
+	return res
+}
+
+func (s *Server) checkQuery(remote net.Addr, m *dns.Msg) error {
+	s.mu.Lock()
+	checks := s.checks
+	s.mu.Unlock()
+
+	for _, check := range checks {
+		if err := check(m); err != nil {
+			var q dns.Question
+			if len(m.Question) != 0 {
+				q = m.Question[0]
+			}
+			err = fmt.Errorf("query %s %v from %v: %w", q.Name, dns.Type(q.Qtype), remote, err)
+
+			s.mu.Lock()
+			s.violations = append(s.violations, err)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package mockdns
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_CheckQueries(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	srv.CheckQueries(RequireRD(), RequireEDNS(1232), RequireDO(), RequireClass(dns.ClassINET), ForbidANY())
+
+	cases := []struct {
+		name    string
+		prepare func(m *dns.Msg)
+		wantErr string
+	}{
+		{name: "valid", prepare: func(m *dns.Msg) {}},
+		{name: "no RD", prepare: func(m *dns.Msg) { m.RecursionDesired = false }, wantErr: "RD bit is not set"},
+		{name: "no EDNS", prepare: func(m *dns.Msg) { m.Extra = nil }, wantErr: "EDNS is not used"},
+		{name: "small bufsize", prepare: func(m *dns.Msg) { m.IsEdns0().SetUDPSize(512) }, wantErr: "EDNS buffer size 512 is less than 1232"},
+		{name: "no DO", prepare: func(m *dns.Msg) { m.IsEdns0().SetDo(false) }, wantErr: "DO bit is not set"},
+		{name: "class CH", prepare: func(m *dns.Msg) { m.Question[0].Qclass = dns.ClassCHAOS }, wantErr: "wrong class CH, want IN"},
+		{name: "ANY", prepare: func(m *dns.Msg) { m.Question[0].Qtype = dns.TypeANY }, wantErr: "ANY query"},
+	}
+	cl := dns.Client{}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.QueryViolations())
+
+			msg := new(dns.Msg)
+			msg.SetQuestion("example.org.", dns.TypeA)
+			msg.SetEdns0(4096, true)
+			tt.prepare(msg)
+			reply, _, err := cl.Exchange(msg, srv.LocalAddr().String())
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			violations := srv.QueryViolations()
+			if tt.wantErr == "" {
+				if reply.Rcode != dns.RcodeSuccess {
+					t.Errorf("Wrong rcode, want NOERROR, got %v", dns.RcodeToString[reply.Rcode])
+				}
+				if len(violations) != before {
+					t.Errorf("Unexpected violation: %v", violations[len(violations)-1])
+				}
+				return
+			}
+
+			if reply.Rcode != dns.RcodeRefused {
+				t.Errorf("Wrong rcode, want REFUSED, got %v", dns.RcodeToString[reply.Rcode])
+			}
+			if len(violations) != before+1 {
+				t.Fatalf("Violation is not recorded")
+			}
+			if !strings.Contains(violations[before].Error(), tt.wantErr) {
+				t.Errorf("Wrong violation, want %q, got %q", tt.wantErr, violations[before])
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package mockdns
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ResolvConf emulates resolv.conf(5) options for resolvers patched using
+// Server.PatchNet, so code adapting to them can be tested without changing
+// the host configuration. See Server.ResolvConf.
+type ResolvConf struct {
+	// UseVC makes resolvers use only TCP, as with "options use-vc".
+	UseVC bool
+
+	// SingleRequest makes resolvers send queries of each lookup one at a
+	// time, waiting for the reply to the previous one (e.g. A and AAAA
+	// queries of LookupHost), as with "options single-request". Queries of
+	// a lookup are recognized by the resolver and the name, so concurrent
+	// lookups of different names are not serialized.
+	SingleRequest bool
+
+	// Attempts is the number of times each UDP query is sent before giving
+	// up, as with "options attempts:n". It replaces the attempts of the Go
+	// resolver: once all attempts time out, its own retries of the same
+	// question fail immediately for Timeout.
+	Attempts int
+
+	// Timeout is the time to wait for each reply, as with "options
+	// timeout:n". The Go resolver timeout (5 seconds by default) still
+	// limits the total time of each exchange.
+	Timeout time.Duration
+}
+
+type resolvState struct {
+	mu sync.Mutex
+	// exhausted contains questions that timed out after all Attempts,
+	// with the time until which retries fail immediately.
+	exhausted map[queryKey]time.Time
+	// single contains locks held while a query of the lookup is in flight
+	// for SingleRequest.
+	single map[singleKey]*singleLock
+}
+
+// singleKey identifies queries of one lookup.
+type singleKey struct {
+	r    *net.Resolver
+	name string
+}
+
+type singleLock struct {
+	sem chan struct{}
+	// refs is the amount of connections holding or waiting for the lock,
+	// it is removed once there are none.
+	refs int
+}
+
+func (st *resolvState) lockSingle(key singleKey) {
+	st.mu.Lock()
+	l, ok := st.single[key]
+	if !ok {
+		l = &singleLock{sem: make(chan struct{}, 1)}
+		st.single[key] = l
+	}
+	l.refs++
+	st.mu.Unlock()
+
+	l.sem <- struct{}{}
+}
+
+func (st *resolvState) unlockSingle(key singleKey) {
+	st.mu.Lock()
+	l := st.single[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(st.single, key)
+	}
+	st.mu.Unlock()
+
+	<-l.sem
+}
+
+// resolvConn wraps connections of patched resolvers to apply ResolvConf.
+type resolvConn struct {
+	net.Conn
+	conf ResolvConf
+	st   *resolvState
+	r    *net.Resolver
+
+	query    []byte
+	key      queryKey
+	deadline time.Time
+	locked   bool
+	unlock   sync.Once
+}
+
+// resolvPacketConn is resolvConn for UDP. net.Resolver uses message framing
+// only for connections implementing net.PacketConn.
+type resolvPacketConn struct {
+	*resolvConn
+}
+
+func (c *resolvPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *resolvPacketConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+// resolvConn returns conn of r wrapped to apply s.ResolvConf, if needed.
+func (s *Server) resolvConn(r *net.Resolver, conn net.Conn) net.Conn {
+	conf := s.ResolvConf
+	if !conf.SingleRequest && conf.Attempts == 0 && conf.Timeout == 0 {
+		return conn
+	}
+
+	s.mu.Lock()
+	if s.resolv == nil {
+		s.resolv = &resolvState{
+			exhausted: make(map[queryKey]time.Time),
+			single:    make(map[singleKey]*singleLock),
+		}
+	}
+	st := s.resolv
+	s.mu.Unlock()
+
+	c := &resolvConn{Conn: conn, conf: conf, st: st, r: r}
+	if c.udp() {
+		return &resolvPacketConn{resolvConn: c}
+	}
+	return c
+}
+
+func (c *resolvConn) udp() bool {
+	_, ok := c.Conn.(net.PacketConn)
+	return ok
+}
+
+func (c *resolvConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *resolvConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *resolvConn) Write(b []byte) (int, error) {
+	if c.key == (queryKey{}) {
+		packed := b
+		if !c.udp() && len(b) >= 2 {
+			// Strip the length prefix.
+			packed = b[2:]
+		}
+		m := new(dns.Msg)
+		if err := m.Unpack(packed); err == nil && len(m.Question) != 0 {
+			c.key = queryKey{name: normalizeName(m.Question[0].Name), qtype: m.Question[0].Qtype}
+		}
+	}
+	if c.udp() && c.conf.Attempts != 0 {
+		c.st.mu.Lock()
+		until, ok := c.st.exhausted[c.key]
+		c.st.mu.Unlock()
+		if ok && time.Now().Before(until) {
+			return 0, &net.OpError{Op: "write", Net: "udp", Err: errResolvTimeout{}}
+		}
+	}
+	c.query = append(c.query[:0], b...)
+
+	if c.conf.SingleRequest && !c.locked {
+		c.st.lockSingle(singleKey{r: c.r, name: c.key.name})
+		c.locked = true
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *resolvConn) Read(b []byte) (int, error) {
+	if !c.udp() {
+		if c.conf.Timeout != 0 {
+			c.Conn.SetReadDeadline(c.tryDeadline())
+		}
+		n, err := c.Conn.Read(b)
+		c.release()
+		return n, err
+	}
+
+	attempts := c.conf.Attempts
+	if attempts == 0 {
+		attempts = 1
+	}
+	for try := 1; ; try++ {
+		deadline := c.tryDeadline()
+		c.Conn.SetReadDeadline(deadline)
+		n, err := c.Conn.Read(b)
+		if err == nil {
+			c.release()
+			return n, nil
+		}
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() || try >= attempts || deadline.Equal(c.deadline) {
+			if ok && ne.Timeout() && c.conf.Attempts != 0 {
+				c.exhaust()
+			}
+			c.release()
+			return n, err
+		}
+		if _, err := c.Conn.Write(c.query); err != nil {
+			c.release()
+			return 0, err
+		}
+	}
+}
+
+// tryDeadline returns the read deadline for one attempt.
+func (c *resolvConn) tryDeadline() time.Time {
+	if c.conf.Timeout == 0 {
+		return c.deadline
+	}
+	deadline := time.Now().Add(c.conf.Timeout)
+	if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+		return c.deadline
+	}
+	return deadline
+}
+
+func (c *resolvConn) exhaust() {
+	timeout := c.conf.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	c.st.mu.Lock()
+	defer c.st.mu.Unlock()
+	now := time.Now()
+	for key, until := range c.st.exhausted {
+		if now.After(until) {
+			delete(c.st.exhausted, key)
+		}
+	}
+	c.st.exhausted[c.key] = now.Add(timeout)
+}
+
+func (c *resolvConn) release() {
+	if c.locked {
+		c.unlock.Do(func() {
+			c.st.unlockSingle(singleKey{r: c.r, name: c.key.name})
+		})
+	}
+}
+
+func (c *resolvConn) Close() error {
+	c.release()
+	return c.Conn.Close()
+}
+
+type errResolvTimeout struct{}
+
+func (errResolvTimeout) Error() string   { return "i/o timeout (all attempts failed)" }
+func (errResolvTimeout) Timeout() bool   { return true }
+func (errResolvTimeout) Temporary() bool { return true }
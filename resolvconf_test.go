@@ -0,0 +1,116 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_ResolvConf(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A:    []string{"192.0.2.1"},
+			AAAA: []string{"2001:db8::1"},
+			TXT:  []string{"hello"},
+		},
+		"example.net.": {
+			A:    []string{"192.0.2.2"},
+			AAAA: []string{"2001:db8::2"},
+		},
+	}
+	serve := func(t *testing.T, conf ResolvConf, configure func(s *Server)) (*Server, *net.Resolver) {
+		srv := newTestServer(t, zones, func(s *Server) {
+			s.ResolvConf = conf
+			if configure != nil {
+				configure(s)
+			}
+		})
+		r := &net.Resolver{}
+		srv.PatchNet(r)
+		return srv, r
+	}
+	delayed := func(s *Server) {
+		if err := s.SetClientBehavior("127.0.0.1", ClientBehavior{Delay: 50 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("use-vc", func(t *testing.T) {
+		srv, r := serve(t, ResolvConf{UseVC: true}, nil)
+		defer srv.Close()
+		if _, err := r.LookupTXT(context.Background(), "example.org"); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range srv.Queries() {
+			if _, ok := q.RemoteAddr.(*net.TCPAddr); !ok {
+				t.Fatalf("Query over %v", q.RemoteAddr.Network())
+			}
+		}
+	})
+
+	t.Run("single-request", func(t *testing.T) {
+		srv, r := serve(t, ResolvConf{SingleRequest: true}, delayed)
+		defer srv.Close()
+		if _, err := r.LookupHost(context.Background(), "example.org"); err != nil {
+			t.Fatal(err)
+		}
+		queries := srv.Queries()
+		if len(queries) != 2 {
+			t.Fatalf("Wrong result, want %v, got %v", 2, len(queries))
+		}
+		if d := queries[1].Time.Sub(queries[0].Time); d < 50*time.Millisecond {
+			t.Fatalf("Queries are not sequential: second one sent after %v", d)
+		}
+	})
+
+	t.Run("single-request concurrent", func(t *testing.T) {
+		srv, r := serve(t, ResolvConf{SingleRequest: true}, delayed)
+		defer srv.Close()
+
+		// Each lookup takes two delayed queries, the lookups should not
+		// wait for each other.
+		start := time.Now()
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for _, name := range []string{"example.org", "example.net"} {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				_, err := r.LookupHost(context.Background(), name)
+				errs <- err
+			}(name)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if d := time.Since(start); d >= 190*time.Millisecond {
+			t.Fatalf("Lookups are serialized: took %v", d)
+		}
+		if n := len(srv.Queries()); n != 4 {
+			t.Fatalf("Wrong result, want %v, got %v", 4, n)
+		}
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		srv, r := serve(t, ResolvConf{Attempts: 3, Timeout: 50 * time.Millisecond}, func(s *Server) {
+			s.SetFault(FaultTimeout)
+		})
+		defer srv.Close()
+		start := time.Now()
+		if _, err := r.LookupTXT(context.Background(), "example.org"); err == nil {
+			t.Fatal("Expected error")
+		}
+		if d := time.Since(start); d >= time.Second {
+			t.Fatalf("Timeout is not applied: %v", d)
+		}
+		if n := len(srv.Queries()); n != 3 {
+			t.Fatalf("Wrong result, want %v, got %v", 3, n)
+		}
+	})
+}
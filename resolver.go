@@ -3,8 +3,10 @@ package mockdns
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"strings"
+	"sync/atomic"
 
 	"github.com/miekg/dns"
 )
@@ -18,6 +20,24 @@ type Zone struct {
 	// in the responses.
 	AD bool
 
+	// Make lookups using this zone fail as if the name does not exist.
+	// For Server, this results in NXDOMAIN response while lookups for record
+	// types that are not present in a zone without this flag result in
+	// NODATA response (NOERROR with SOA record in the authority section).
+	NXDOMAIN bool
+
+	// When used with Server, TTL for negative responses for this zone. See
+	// Server.NegativeTTL.
+	NegativeTTL uint32
+
+	// When used with Server, TTL of records generated from the zone values.
+	// If it is zero, 9999 is used. Misc records use the TTL specified in
+	// them.
+	TTL uint32
+
+	// Addresses in AAAA may contain the IPv6 zone identifier
+	// ("fe80::1%eth0"). It is returned by Resolver and stripped by Server,
+	// since it has no representation on the wire.
 	A     []string
 	AAAA  []string
 	TXT   []string
@@ -28,10 +48,74 @@ type Zone struct {
 	SRV   []net.SRV
 
 	// Misc includes other associated zone records, they can be returned only
-	// when used with Server.
+	// when used with Server. The exception is TXT records, which are also
+	// returned by Resolver.LookupTXT with character-strings of each record
+	// concatenated. This makes it possible to define TXT records with
+	// multiple character-strings, while each value in TXT is sent by Server
+	// as a single record split into 255-byte character-strings.
+	//
+	// Records of types unknown to miekg/dns can be added using UnknownRR.
 	Misc map[dns.Type][]dns.RR
+
+	// Regions contains zones used instead of this one for clients from
+	// specific regions, see Resolver.Region and Server.AddRegion.
+	Regions map[string]Zone
+
+	// AErr and AAAAErr, if set, are returned for lookups of addresses of
+	// the specific family only, e.g. to simulate IPv6 lookups timing out.
+	// Lookups of both families return addresses of the other family along
+	// with the error. For Server, A or AAAA queries result in SERVFAIL.
+	AErr    error
+	AAAAErr error
+
+	// EDE contains Extended DNS Errors (RFC 8914) Server attaches to all
+	// replies for the name, including error ones, if the query uses EDNS.
+	// If it is empty, SERVFAIL replies caused by Err, AErr or AAAAErr get
+	// the EDE from the error: *ExtendedError is sent as is and timeouts
+	// (see Timeout) are sent as "Network Error".
+	EDE []ExtendedError
+
+	// When used with Server, distribution of delays applied before sending
+	// replies for the name. It takes precedence over Server.Latency.
+	Latency LatencyDist
+
+	// When used with Server, answer queries for the name with sink
+	// addresses instead of zone records, as DNS-based blocklists do. See
+	// Server.SinkholeAddrs.
+	Sinkhole bool
+
+	// When used with Server, omit the CNAME record of the name from answers
+	// and send the records of the final target as if they belonged to the
+	// name, as DNS providers "flattening" CNAMEs at zone apex do. See also
+	// Server.FlattenCNAME.
+	FlattenCNAME bool
 }
 
+// defaultTTL is the TTL used for records if Zone.TTL is not set.
+const defaultTTL = 9999
+
+func (z Zone) ttl() uint32 {
+	if z.TTL != 0 {
+		return z.TTL
+	}
+	return defaultTTL
+}
+
+// AddrOrder specifies the order of addresses of different families returned
+// by Resolver lookups.
+type AddrOrder int
+
+const (
+	// IPv4First puts all IPv4 addresses before IPv6 ones. This is the
+	// default.
+	IPv4First AddrOrder = iota
+	// IPv6First puts all IPv6 addresses before IPv4 ones.
+	IPv6First
+	// Interleaved alternates address families, starting with IPv6, as
+	// described in RFC 8305 Section 4. See also Resolver.FirstFamilyCount.
+	Interleaved
+)
+
 // Resolver is the struct that implements interface same as net.Resolver
 // and so can be used as a drop-in replacement for it if tested code
 // supports it.
@@ -40,16 +124,108 @@ type Resolver struct {
 
 	// Don't follow CNAME in Zones for Lookup*.
 	SkipCNAME bool
+
+	// Tracer, if set, is used to create spans around Lookup* calls.
+	Tracer Tracer
+
+	// Trace, if set, contains hooks called at stages of Lookup* calls.
+	Trace *ResolverTrace
+
+	// MaxCNAMEDepth is the maximum amount of CNAMEs followed in a single
+	// lookup. Longer chains result in *CNAMEError. Defaults to 16.
+	MaxCNAMEDepth int
+
+	// FilterInvalidHosts makes LookupMX, LookupNS and LookupSRV drop records
+	// with host names that are not valid domain names, like net.Resolver
+	// does since Go 1.20.5. If any records are dropped, the remaining ones
+	// are returned together with a DNSError.
+	FilterInvalidHosts bool
+
+	// StrictNames makes LookupHost, LookupIPAddr, LookupIP and LookupNetIP
+	// reject names that are not valid host names (RFC 1123), like libc
+	// resolvers do: labels may contain only letters, digits and hyphens.
+	// Names that CNAME chains lead to are checked too, so zone entries with
+	// such names are not resolved. Other lookups use the net package rules
+	// that permit underscores, as needed for SRV and TXT names.
+	StrictNames bool
+
+	// ErrServer is the value of Server field of DNSErrors returned by
+	// Lookup* calls. If it is empty, "127.0.0.1:53" is used. Server sets it
+	// to its own address.
+	ErrServer string
+
+	// Hosts is the static overlay of name to addresses mapping, similar to
+	// /etc/hosts. It is consulted before Zones by LookupHost, LookupIPAddr,
+	// LookupIP, LookupNetIP and (in reverse) LookupAddr. Names are not
+	// subject to CNAME processing and do not have any other records.
+	Hosts map[string][]string
+
+	// CanonicalCNAME makes LookupCNAME follow the CNAME chain and return the
+	// canonical (final) name, like net.Resolver does. The name itself is
+	// returned if it has no CNAME. By default, LookupCNAME returns the
+	// CNAME value of the zone, which is empty if the zone has no CNAME.
+	CanonicalCNAME bool
+
+	// AddrOrder controls the order of addresses returned by LookupHost,
+	// LookupIPAddr, LookupIP and LookupNetIP for the "ip" network.
+	AddrOrder AddrOrder
+
+	// FirstFamilyCount is the number of IPv6 addresses returned before the
+	// first IPv4 one for the Interleaved order ("First Address Family Count"
+	// in RFC 8305). Values below 1 are treated as 1.
+	FirstFamilyCount int
+
+	// AddrSeed, if non-zero, makes lookups shuffle addresses of each family
+	// before ordering them. Results are deterministic for the same seed.
+	AddrSeed int64
+
+	// SingleLabelPolicy and RootPolicy control how lookups for
+	// single-label names ("localhost", "myhost") and the root name are
+	// handled. The trailing dot does not affect the policy. Search is the
+	// list of suffixes used by NameSearch.
+	SingleLabelPolicy NamePolicy
+	RootPolicy        NamePolicy
+	Search            []string
+
+	// Services is the table used by LookupPort, keys are in "network/service"
+	// form (e.g. "tcp/submission"), network is either "tcp" or "udp". If nil,
+	// DefaultServices is used. The host's /etc/services is never consulted.
+	Services map[string]int
+
+	// Bulk, if set, contains additional names with A and AAAA records only.
+	// Zones take precedence over it.
+	Bulk *BulkHosts
+
+	// Region, if set, makes lookups use Zone.Regions[Region] instead of
+	// zones that define it. Server sets it per query, see AddRegion.
+	Region string
+
+	// src, if set, is used instead of Zones. See Server.AddZone.
+	src *ZoneSource
+	// seen is the Zones map last used by src, so assigning a new one can be
+	// detected. See Server.Resolver.
+	seen *atomic.Value // map[string]Zone
 }
 
 func (r *Resolver) LookupAddr(ctx context.Context, addr string) (names []string, err error) {
-	arpa, err := dns.ReverseAddr(addr)
-	if err != nil {
+	ctx, span := r.startSpan(ctx, "LookupAddr", addr, dns.TypePTR)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := ctxErr(ctx, addr); err != nil {
 		return nil, err
 	}
 
-	rzone, ok := r.Zones[strings.ToLower(arpa)]
-	if !ok {
+	arpa := ReverseName(net.ParseIP(addr))
+	if arpa == "" {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+
+	if names := r.lookupHostsAddr(addr); len(names) != 0 {
+		return names, nil
+	}
+
+	rzone, ok := r.zone(arpa)
+	if !ok || rzone.NXDOMAIN {
 		return nil, notFound(arpa)
 	}
 	if rzone.Err != nil {
@@ -62,8 +238,30 @@ func (r *Resolver) LookupAddr(ctx context.Context, addr string) (names []string,
 }
 
 func (r *Resolver) LookupCNAME(ctx context.Context, host string) (cname string, err error) {
-	rzone, ok := r.Zones[strings.ToLower(host)]
-	if !ok {
+	ctx, span := r.startSpan(ctx, "LookupCNAME", host, dns.TypeCNAME)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := checkName(host); err != nil {
+		return "", err
+	}
+	host, err = r.applyNamePolicy(host)
+	if err != nil {
+		return "", err
+	}
+	if err := ctxErr(ctx, host); err != nil {
+		return "", err
+	}
+
+	if r.CanonicalCNAME {
+		_, rname, _, err := r.resolveZone(ctx, host, true)
+		if err != nil {
+			return "", err
+		}
+		return rname, nil
+	}
+
+	rzone, ok := r.zone(host)
+	if !ok || rzone.NXDOMAIN {
 		return "", notFound(host)
 	}
 
@@ -71,45 +269,169 @@ func (r *Resolver) LookupCNAME(ctx context.Context, host string) (cname string,
 }
 
 func (r *Resolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
-	_, addrs4, err := r.lookupA(ctx, host)
+	ctx, span := r.startSpan(ctx, "LookupHost", host, 0)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	return r.lookupIPs(ctx, "ip", host)
+}
+
+// lookupIPs returns addresses of the specified network ("ip", "ip4" or "ip6")
+// for host, consulting Hosts first. IP literals are returned as is.
+func (r *Resolver) lookupIPs(ctx context.Context, network, host string) ([]string, error) {
+	if host == "" {
+		return nil, noSuchHost(host)
+	}
+	if addrs, ok, err := ipLiteral(network, host); ok {
+		return addrs, err
+	}
+	if addrs := r.lookupHosts(network, host); len(addrs) != 0 {
+		return addrs, nil
+	}
+	if err := r.checkHost(host); err != nil {
+		return nil, err
+	}
+	host, err := r.applyNamePolicy(host)
 	if err != nil {
 		return nil, err
 	}
-	_, addrs6, err := r.lookupAAAA(ctx, host)
+
+	_, rname, rzone, err := r.targetZone(ctx, host)
 	if err != nil {
 		return nil, err
 	}
+	if r.StrictNames && !isHostName(rname) {
+		return nil, noSuchHost(host)
+	}
 
-	addrs = append(addrs, addrs4...)
-	addrs = append(addrs, addrs6...)
+	// familyErr is returned along with addresses of the other family, if
+	// any, see Zone.AErr.
+	var (
+		addrs4, addrs6 []string
+		familyErr      error
+	)
+	if network != "ip6" {
+		if rzone.AErr != nil {
+			familyErr = rzone.AErr
+		} else {
+			addrs4 = rzone.A
+		}
+	}
+	if network != "ip4" {
+		if rzone.AAAAErr != nil {
+			familyErr = rzone.AAAAErr
+		} else {
+			addrs6 = rzone.AAAA
+		}
+	}
 
+	addrs := r.orderAddrs(addrs4, addrs6)
+	if familyErr != nil {
+		if len(addrs) == 0 {
+			return nil, familyErr
+		}
+		return addrs, familyErr
+	}
 	if len(addrs) == 0 {
 		return nil, notFound(host)
 	}
 
-	return addrs, err
+	return addrs, nil
 }
 
-func (r *Resolver) targetZone(name string) (ad bool, rname string, zone Zone, err error) {
-	rname = strings.ToLower(dns.Fqdn(name))
-	rzone, ok := r.Zones[rname]
-	if !ok {
+// orderAddrs merges IPv4 and IPv6 addresses according to AddrOrder.
+func (r *Resolver) orderAddrs(addrs4, addrs6 []string) []string {
+	if r.AddrSeed != 0 {
+		rng := rand.New(rand.NewSource(r.AddrSeed))
+		addrs4 = shuffled(rng, addrs4)
+		addrs6 = shuffled(rng, addrs6)
+	}
+
+	addrs := make([]string, 0, len(addrs4)+len(addrs6))
+
+	switch r.AddrOrder {
+	case IPv6First:
+		addrs = append(addrs, addrs6...)
+		addrs = append(addrs, addrs4...)
+	case Interleaved:
+		first := r.FirstFamilyCount
+		if first < 1 {
+			first = 1
+		}
+		if first > len(addrs6) {
+			first = len(addrs6)
+		}
+		addrs = append(addrs, addrs6[:first]...)
+		addrs6 = addrs6[first:]
+
+		for i := 0; i < len(addrs4) || i < len(addrs6); i++ {
+			if i < len(addrs4) {
+				addrs = append(addrs, addrs4[i])
+			}
+			if i < len(addrs6) {
+				addrs = append(addrs, addrs6[i])
+			}
+		}
+	default:
+		addrs = append(addrs, addrs4...)
+		addrs = append(addrs, addrs6...)
+	}
+
+	return addrs
+}
+
+// shuffled returns the shuffled copy of addrs.
+func shuffled(rng *rand.Rand, addrs []string) []string {
+	cpy := make([]string, len(addrs))
+	copy(cpy, addrs)
+	rng.Shuffle(len(cpy), func(i, j int) {
+		cpy[i], cpy[j] = cpy[j], cpy[i]
+	})
+	return cpy
+}
+
+func (r *Resolver) targetZone(ctx context.Context, name string) (ad bool, rname string, zone Zone, err error) {
+	return r.resolveZone(ctx, name, !r.SkipCNAME)
+}
+
+// resolveZone returns the zone for name, following CNAMEs if followCNAME is
+// true.
+func (r *Resolver) resolveZone(ctx context.Context, name string, followCNAME bool) (ad bool, rname string, zone Zone, err error) {
+	if err := ctxErr(ctx, name); err != nil {
+		return false, "", Zone{}, err
+	}
+
+	rname = normalizeName(name)
+	rzone, ok := r.zone(rname)
+	if !ok || rzone.NXDOMAIN {
 		return false, "", Zone{}, notFound(name)
 	}
 
+	r.traceZone(rname, rzone)
 	if rzone.Err != nil {
 		return false, "", rzone, rzone.Err
 	}
 
 	ad = rzone.AD
 
-	if !r.SkipCNAME {
+	if followCNAME {
+		chain := []string{rname}
 		for rzone.CNAME != "" {
-			rname = rzone.CNAME
-			rzone, ok = r.Zones[rname]
-			if !ok {
+			if err := ctxErr(ctx, name); err != nil {
+				return false, "", Zone{}, err
+			}
+
+			target := normalizeName(rzone.CNAME)
+			if err := r.checkCNAMEChain(chain, target); err != nil {
+				return false, "", Zone{}, err
+			}
+			chain = append(chain, target)
+			r.traceCNAME(rname, target)
+			rname = target
+			rzone, ok = r.zone(rname)
+			if !ok || rzone.NXDOMAIN {
 				return false, rname, Zone{}, notFound(rname)
 			}
+			r.traceZone(rname, rzone)
 			if rzone.Err != nil {
 				return false, "", rzone, rzone.Err
 			}
@@ -121,79 +443,140 @@ func (r *Resolver) targetZone(name string) (ad bool, rname string, zone Zone, er
 }
 
 func (r *Resolver) lookupA(ctx context.Context, host string) (cname string, addrs []string, err error) {
-	_, cname, rzone, err := r.targetZone(host)
+	_, cname, rzone, err := r.targetZone(ctx, host)
 	if err != nil {
 		return cname, nil, err
 	}
 
+	if rzone.AErr != nil {
+		return cname, nil, rzone.AErr
+	}
+
 	return cname, rzone.A, nil
 }
 
 func (r *Resolver) lookupAAAA(ctx context.Context, host string) (cname string, addrs []string, err error) {
-	_, cname, rzone, err := r.targetZone(host)
+	_, cname, rzone, err := r.targetZone(ctx, host)
 	if err != nil {
 		return cname, nil, err
 	}
 
+	if rzone.AAAAErr != nil {
+		return cname, nil, rzone.AAAAErr
+	}
+
 	return cname, rzone.AAAA, nil
 }
 
-func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) (_ []net.IPAddr, err error) {
+	ctx, span := r.startSpan(ctx, "LookupIPAddr", host, 0)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
 	addrs, err := r.LookupHost(ctx, host)
-	if err != nil {
+	if len(addrs) == 0 {
 		return nil, err
 	}
 
 	parsed := make([]net.IPAddr, 0, len(addrs))
 	for _, addr := range addrs {
-		ip := net.ParseIP(addr)
+		ip, zone := parseIPZone(addr)
 		if ip == nil {
 			return nil, fmt.Errorf("malformed IP in records: %v", addr)
 		}
 
-		parsed = append(parsed, net.IPAddr{IP: ip})
+		parsed = append(parsed, net.IPAddr{IP: ip, Zone: zone})
 	}
 
-	return parsed, nil
+	// Partial results are returned along with the error, see Zone.AErr.
+	return parsed, err
 }
 
-func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
-	var addrs []string
-	var err error
+// ctxErr returns the error similar to one returned by net.Resolver if ctx is
+// done.
+func ctxErr(ctx context.Context, name string) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+
+	dnsErr := &net.DNSError{
+		Err:    err.Error(),
+		Name:   name,
+		Server: "127.0.0.1:53",
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		dnsErr.Err = "i/o timeout"
+		dnsErr.IsTimeout = true
+	case context.Canceled:
+		dnsErr.Err = "operation was canceled"
+	}
+	return dnsErr
+}
+
+// parseIPZone parses the IP address that may contain the IPv6 zone
+// identifier (e.g. "fe80::1%eth0"). nil IP is returned if addr is malformed.
+func parseIPZone(addr string) (net.IP, string) {
+	zone := ""
+	if i := strings.LastIndexByte(addr, '%'); i != -1 {
+		addr, zone = addr[:i], addr[i+1:]
+		if zone == "" {
+			return nil, ""
+		}
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil || (zone != "" && ip.To4() != nil) {
+		return nil, ""
+	}
+	return ip, zone
+}
+
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) (_ []net.IP, err error) {
+	ctx, span := r.startSpan(ctx, "LookupIP", host, 0)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
 	switch network {
-	case "ip":
-		addrs, err = r.LookupHost(ctx, host)
-	case "ip4":
-		_, addrs, err = r.lookupA(ctx, host)
-	case "ip6":
-		_, addrs, err = r.lookupAAAA(ctx, host)
+	case "ip", "ip4", "ip6":
 	default:
 		return nil, fmt.Errorf("unsupported network: %v", network)
 	}
-	if err != nil {
-		return nil, err
-	}
-
+	addrs, err := r.lookupIPs(ctx, network, host)
 	if len(addrs) == 0 {
-		return nil, notFound(host)
+		return nil, err
 	}
 
 	parsed := make([]net.IP, len(addrs))
 	for i, addr := range addrs {
-		parsed[i] = net.ParseIP(addr)
+		// net.IP has no place for IPv6 zone, use LookupIPAddr to get it.
+		parsed[i], _ = parseIPZone(addr)
 	}
-	return parsed, nil
+	return parsed, err
 }
 
-func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+func (r *Resolver) LookupMX(ctx context.Context, name string) (_ []*net.MX, err error) {
+	ctx, span := r.startSpan(ctx, "LookupMX", name, dns.TypeMX)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	name, err = r.applyNamePolicy(name)
+	if err != nil {
+		return nil, err
+	}
+
 	_, mx, err := r.lookupMX(ctx, name)
 	res := make([]*net.MX, len(mx))
 	copy(res, mx)
+	if err == nil && r.FilterInvalidHosts {
+		return filterMX(name, res)
+	}
 	return res, err
 }
 
 func (r *Resolver) lookupMX(ctx context.Context, name string) (string, []*net.MX, error) {
-	_, cname, rzone, err := r.targetZone(name)
+	_, cname, rzone, err := r.targetZone(ctx, name)
 	if err != nil {
 		return "", nil, err
 	}
@@ -207,15 +590,29 @@ func (r *Resolver) lookupMX(ctx context.Context, name string) (string, []*net.MX
 	return cname, out, nil
 }
 
-func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+func (r *Resolver) LookupNS(ctx context.Context, name string) (_ []*net.NS, err error) {
+	ctx, span := r.startSpan(ctx, "LookupNS", name, dns.TypeNS)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	name, err = r.applyNamePolicy(name)
+	if err != nil {
+		return nil, err
+	}
+
 	_, ns, err := r.lookupNS(ctx, name)
 	res := make([]*net.NS, len(ns))
 	copy(res, ns)
+	if err == nil && r.FilterInvalidHosts {
+		return filterNS(name, res)
+	}
 	return res, err
 }
 
 func (r *Resolver) lookupNS(ctx context.Context, name string) (string, []*net.NS, error) {
-	_, cname, rzone, err := r.targetZone(name)
+	_, cname, rzone, err := r.targetZone(ctx, name)
 	if err != nil {
 		return "", nil, err
 	}
@@ -230,17 +627,40 @@ func (r *Resolver) lookupNS(ctx context.Context, name string) (string, []*net.NS
 }
 
 func (r *Resolver) LookupPort(ctx context.Context, network, service string) (port int, err error) {
-	// TODO: Check whether it can cause problems with net.DefaultResolver hjacking.
-	return net.LookupPort(network, service)
+	if err := ctxErr(ctx, service); err != nil {
+		return 0, err
+	}
+
+	return r.lookupPort(network, service)
 }
 
 func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error) {
-	query := fmt.Sprintf("_%s._%s.%s", service, proto, name)
-	return r.lookupSRV(ctx, query)
+	// Like net.Resolver, name is looked up directly if both service and
+	// proto are empty.
+	query := name
+	if service != "" || proto != "" {
+		query = fmt.Sprintf("_%s._%s.%s", service, proto, name)
+	}
+	ctx, span := r.startSpan(ctx, "LookupSRV", query, dns.TypeSRV)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := checkName(query); err != nil {
+		return "", nil, err
+	}
+	query, err = r.applyNamePolicy(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cname, addrs, err = r.lookupSRV(ctx, query)
+	if err == nil && r.FilterInvalidHosts {
+		addrs, err = filterSRV(query, addrs)
+	}
+	return cname, addrs, err
 }
 
 func (r *Resolver) lookupSRV(ctx context.Context, query string) (cname string, addrs []*net.SRV, err error) {
-	_, cname, rzone, err := r.targetZone(query)
+	_, cname, rzone, err := r.targetZone(ctx, query)
 	if err != nil {
 		return "", nil, err
 	}
@@ -254,7 +674,18 @@ func (r *Resolver) lookupSRV(ctx context.Context, query string) (cname string, a
 	return cname, out, nil
 }
 
-func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+func (r *Resolver) LookupTXT(ctx context.Context, name string) (_ []string, err error) {
+	ctx, span := r.startSpan(ctx, "LookupTXT", name, dns.TypeTXT)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	name, err = r.applyNamePolicy(name)
+	if err != nil {
+		return nil, err
+	}
+
 	_, txt, err := r.lookupTXT(ctx, name)
 	res := make([]string, len(txt))
 	copy(res, txt)
@@ -262,12 +693,23 @@ func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error)
 }
 
 func (r *Resolver) lookupTXT(ctx context.Context, name string) (string, []string, error) {
-	_, cname, rzone, err := r.targetZone(name)
+	_, cname, rzone, err := r.targetZone(ctx, name)
 	if err != nil {
 		return "", nil, err
 	}
 
-	return cname, rzone.TXT, nil
+	misc := rzone.Misc[dns.Type(dns.TypeTXT)]
+	txts := make([]string, 0, len(rzone.TXT)+len(misc))
+	txts = append(txts, rzone.TXT...)
+	for _, rr := range misc {
+		if txt, ok := rr.(*dns.TXT); ok {
+			// Like net.Resolver, join character-strings of a single record
+			// but keep separate records as separate strings.
+			txts = append(txts, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	return cname, txts, nil
 }
 
 // Dial implements the function similar to net.Dial that uses Resolver zones
@@ -284,7 +726,7 @@ func (r *Resolver) DialContext(ctx context.Context, network, addr string) (net.C
 		return nil, err
 	}
 
-	ip := net.ParseIP(host)
+	ip, _ := parseIPZone(host)
 	if ip != nil {
 		return net.Dial(network, addr)
 	}
@@ -10,33 +10,27 @@ import (
 	"net/netip"
 )
 
-func (r *Resolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
-	var addrs []string
-	var err error
+func (r *Resolver) LookupNetIP(ctx context.Context, network, host string) (_ []netip.Addr, err error) {
+	ctx, span := r.startSpan(ctx, "LookupNetIP", host, 0)
+	defer func() { err = r.errServer(err); span.End(err) }()
+
 	switch network {
-	case "ip":
-		addrs, err = r.LookupHost(ctx, host)
-	case "ip4":
-		_, addrs, err = r.lookupA(ctx, host)
-	case "ip6":
-		_, addrs, err = r.lookupAAAA(ctx, host)
+	case "ip", "ip4", "ip6":
 	default:
 		return nil, fmt.Errorf("unsupported network: %v", network)
 	}
-	if err != nil {
-		return nil, err
-	}
-
+	addrs, err := r.lookupIPs(ctx, network, host)
 	if len(addrs) == 0 {
-		return nil, notFound(host)
+		return nil, err
 	}
 
 	parsed := make([]netip.Addr, len(addrs))
 	for i, addr := range addrs {
-		parsed[i], err = netip.ParseAddr(addr)
-		if err != nil {
-			return nil, err
+		var parseErr error
+		parsed[i], parseErr = netip.ParseAddr(addr)
+		if parseErr != nil {
+			return nil, parseErr
 		}
 	}
-	return parsed, nil
+	return parsed, err
 }
@@ -56,3 +56,19 @@ func TestResolver_LookupNetIP(t *testing.T) {
 
 	}
 }
+
+func TestResolver_LookupNetIP_Zone(t *testing.T) {
+	r := &Resolver{Zones: map[string]Zone{
+		"example.org.": {
+			AAAA: []string{"fe80::1%eth0"},
+		},
+	}}
+
+	addrs, err := r.LookupNetIP(context.Background(), "ip6", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "fe80::1%eth0" {
+		t.Errorf("Wrong result: %v", addrs)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"net"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolver_LookupHost(t *testing.T) {
@@ -115,3 +117,232 @@ func TestResolver_LookupIP(t *testing.T) {
 
 	}
 }
+
+func TestResolver_LookupIPAddr(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{
+		"example.org.": {
+			A:    []string{"1.2.3.4"},
+			AAAA: []string{"fe80::1%eth0", "2001:db8::1"},
+		},
+		"broken.example.org.": {
+			A: []string{"1.2.3.4%eth0"},
+		},
+	}}
+
+	addrs, err := r.LookupIPAddr(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []net.IPAddr{
+		{IP: net.ParseIP("1.2.3.4")},
+		{IP: net.ParseIP("fe80::1"), Zone: "eth0"},
+		{IP: net.ParseIP("2001:db8::1")},
+	}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	ips, err := r.LookupIP(context.Background(), "ip6", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 2 || !ips[0].Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("Wrong LookupIP result: %v", ips)
+	}
+
+	if _, err := r.LookupIPAddr(context.Background(), "broken.example.org"); err == nil {
+		t.Errorf("Expected error for IPv4 address with zone, got nil")
+	}
+}
+
+func TestResolver_AddrOrder(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A:    []string{"192.0.2.1", "192.0.2.2"},
+			AAAA: []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"},
+		},
+	}
+
+	cases := []struct {
+		order AddrOrder
+		want  []string
+	}{
+		{IPv4First, []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{IPv6First, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3", "192.0.2.1", "192.0.2.2"}},
+		{Interleaved, []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "2001:db8::3"}},
+	}
+	for _, c := range cases {
+		r := Resolver{Zones: zones, AddrOrder: c.order}
+		addrs, err := r.LookupHost(context.Background(), "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(addrs, c.want) {
+			t.Errorf("Wrong result for order %v, want %v, got %v", c.order, c.want, addrs)
+		}
+
+		ips, err := r.LookupIP(context.Background(), "ip", "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, ip := range ips {
+			if ip.String() != c.want[i] {
+				t.Errorf("Wrong LookupIP result for order %v, want %v, got %v", c.order, c.want, ips)
+				break
+			}
+		}
+	}
+}
+
+func TestResolver_AddrOrder_HappyEyeballs(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A:    []string{"192.0.2.1", "192.0.2.2"},
+			AAAA: []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"},
+		},
+	}
+
+	r := Resolver{Zones: zones, AddrOrder: Interleaved, FirstFamilyCount: 2}
+	addrs, err := r.LookupHost(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2001:db8::1", "2001:db8::2", "192.0.2.1", "2001:db8::3", "192.0.2.2"}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, addrs)
+	}
+
+	r = Resolver{Zones: zones, AddrOrder: Interleaved, AddrSeed: 42}
+	first, err := r.LookupHost(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		addrs, err := r.LookupHost(context.Background(), "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(addrs, first) {
+			t.Fatalf("Non-deterministic result for the same seed, want %v, got %v", first, addrs)
+		}
+	}
+	for i, addr := range first {
+		isV6 := strings.Contains(addr, ":")
+		if isV6 != (i%2 == 0) {
+			t.Fatalf("Families are not interleaved: %v", first)
+		}
+	}
+}
+
+func TestResolver_ContextDone(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{
+		"example.org.": {
+			A:   []string{"1.2.3.4"},
+			MX:  []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			TXT: []string{"text"},
+		},
+		"1.0.0.127.in-addr.arpa.": {
+			PTR: []string{"localhost."},
+		},
+	}}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lookups := map[string]func(ctx context.Context) error{
+		"LookupHost": func(ctx context.Context) error {
+			_, err := r.LookupHost(ctx, "example.org")
+			return err
+		},
+		"LookupIP": func(ctx context.Context) error {
+			_, err := r.LookupIP(ctx, "ip4", "example.org")
+			return err
+		},
+		"LookupMX": func(ctx context.Context) error {
+			_, err := r.LookupMX(ctx, "example.org")
+			return err
+		},
+		"LookupTXT": func(ctx context.Context) error {
+			_, err := r.LookupTXT(ctx, "example.org")
+			return err
+		},
+		"LookupCNAME": func(ctx context.Context) error {
+			_, err := r.LookupCNAME(ctx, "example.org.")
+			return err
+		},
+		"LookupAddr": func(ctx context.Context) error {
+			_, err := r.LookupAddr(ctx, "127.0.0.1")
+			return err
+		},
+	}
+	for name, lookup := range lookups {
+		if err := lookup(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+
+		err := lookup(expired)
+		dnsErr, ok := err.(*net.DNSError)
+		if !ok {
+			t.Errorf("%s: err is not *net.DNSError, but %T", name, err)
+			continue
+		}
+		if !dnsErr.IsTimeout || dnsErr.Err != "i/o timeout" {
+			t.Errorf("%s: wrong error for expired context: %#v", name, dnsErr)
+		}
+
+		err = lookup(canceled)
+		dnsErr, ok = err.(*net.DNSError)
+		if !ok {
+			t.Errorf("%s: err is not *net.DNSError, but %T", name, err)
+			continue
+		}
+		if dnsErr.IsTimeout || dnsErr.Err != "operation was canceled" {
+			t.Errorf("%s: wrong error for canceled context: %#v", name, dnsErr)
+		}
+	}
+}
+
+func TestResolver_CanonicalCNAME(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{
+		"www.example.org.": {
+			CNAME: "cdn.example.org.",
+		},
+		"cdn.example.org.": {
+			CNAME: "example.net.",
+		},
+		"example.net.": {
+			A: []string{"1.2.3.4"},
+		},
+	}}
+
+	cname, err := r.LookupCNAME(context.Background(), "www.example.org.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname != "cdn.example.org." {
+		t.Errorf("Wrong default result: %v", cname)
+	}
+
+	r.CanonicalCNAME = true
+	cname, err = r.LookupCNAME(context.Background(), "www.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname != "example.net." {
+		t.Errorf("Wrong canonical name: %v", cname)
+	}
+
+	cname, err = r.LookupCNAME(context.Background(), "example.net.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname != "example.net." {
+		t.Errorf("Wrong canonical name for name without CNAME: %v", cname)
+	}
+
+	if _, err := r.LookupCNAME(context.Background(), "example.com."); err == nil {
+		t.Errorf("Expected error for missing name, got nil")
+	}
+}
@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// QuestionMatcher reports whether the reply set using RespondWith should be
+// sent for the question.
+type QuestionMatcher func(q dns.Question) bool
+
+// MatchQuestion returns the QuestionMatcher matching questions for name
+// (case-insensitive, trailing dot optional) and qtype. qtype dns.TypeNone
+// matches all types.
+func MatchQuestion(name string, qtype uint16) QuestionMatcher {
+	name = normalizeName(name)
+	return func(q dns.Question) bool {
+		return normalizeName(q.Name) == name && (qtype == dns.TypeNone || q.Qtype == qtype)
+	}
+}
+
+type injectedReply struct {
+	match QuestionMatcher
+	msg   *dns.Msg
+	// wire is the packed message sent instead of msg, if not nil.
+	wire []byte
+}
+
+// RespondWith makes the Server reply with msg to queries matching match,
+// bypassing zones and all options changing reply contents (fault rcodes,
+// cookies, TTL adjustments, etc.). The message is sent as is except for its
+// ID: it is set to the query ID if msg.Id is zero. Queries are still
+// recorded, and delays, dropping (FaultTimeout, ClientBehavior.Drop), size
+// limits and fuzzing are still applied.
+//
+// msg must not be modified after the call. If multiple matchers match the
+// question, the one added first is used.
+func (s *Server) RespondWith(match QuestionMatcher, msg *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injected = append(s.injected, injectedReply{match: match, msg: msg})
+}
+
+// ClearResponses removes all replies set using RespondWith.
+func (s *Server) ClearResponses() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injected = nil
+}
+
+// injectedReply returns a copy of the message set using RespondWith or
+// RespondWithWire for the query and its wire form, if any. It returns nil
+// msg if there is none.
+func (s *Server) injectedReply(m *dns.Msg) (msg *dns.Msg, wire []byte) {
+	if len(m.Question) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	injected := s.injected
+	s.mu.Unlock()
+
+	for _, ir := range injected {
+		if !ir.match(m.Question[0]) {
+			continue
+		}
+		msg := ir.msg.Copy()
+		if msg.Id == 0 || ir.wire != nil {
+			msg.Id = m.Id
+		}
+		return msg, ir.wire
+	}
+	return nil, nil
+}
@@ -0,0 +1,65 @@
+package mockdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_RespondWith(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	raw := new(dns.Msg)
+	raw.Response = true
+	raw.Rcode = dns.RcodeNameError
+	raw.Question = []dns.Question{{Name: "other.example.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}
+	srv.RespondWith(MatchQuestion("EXAMPLE.org", dns.TypeA), raw)
+	srv.SetFault(FaultServFail)
+
+	cl := dns.Client{Timeout: time.Second}
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Id != m.Id {
+		t.Fatalf("Wrong ID, want %v, got %v", m.Id, reply.Id)
+	}
+	if reply.Rcode != dns.RcodeNameError {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeNameError, reply.Rcode)
+	}
+	if reply.Question[0].Name != "other.example." {
+		t.Fatalf("Wrong question, want %v, got %v", "other.example.", reply.Question[0].Name)
+	}
+	if srv.QueryCount("example.org", dns.TypeA) != 1 {
+		t.Fatal("Query not recorded")
+	}
+
+	srv.SetFault(FaultNone)
+	srv.ClearResponses()
+	reply, _, err = cl.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Wrong result, want %v, got %v", 1, len(reply.Answer))
+	}
+}
+
+func TestMatchQuestion(t *testing.T) {
+	q := dns.Question{Name: "Example.org.", Qtype: dns.TypeMX, Qclass: dns.ClassINET}
+	if !MatchQuestion("example.org", dns.TypeNone)(q) {
+		t.Fatal("TypeNone should match all types")
+	}
+	if MatchQuestion("example.org.", dns.TypeA)(q) {
+		t.Fatal("Type mismatch should not match")
+	}
+}
@@ -0,0 +1,28 @@
+package mockdns
+
+import (
+	"encoding/hex"
+
+	"github.com/miekg/dns"
+)
+
+// UnknownRR returns the record of type rrtype with rdata in the RFC 3597
+// "unknown record" form, for use in Zone.Misc. This makes it possible to
+// serve record types miekg/dns has no struct for (e.g. private-use types
+// 65280-65534). Equivalent zone file syntax is
+//
+//	example.org. 300 IN TYPE65280 \# 4 01020304
+//
+// Note that rdata is sent as is, so names inside it are never compressed.
+func UnknownRR(name string, rrtype uint16, ttl uint32, rdata []byte) dns.RR {
+	return &dns.RFC3597{
+		Hdr: dns.RR_Header{
+			Name:     dns.Fqdn(name),
+			Rrtype:   rrtype,
+			Class:    dns.ClassINET,
+			Ttl:      ttl,
+			Rdlength: uint16(len(rdata)),
+		},
+		Rdata: hex.EncodeToString(rdata),
+	}
+}
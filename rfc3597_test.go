@@ -0,0 +1,54 @@
+package mockdns
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_UnknownRR(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			Misc: map[dns.Type][]dns.RR{
+				65280: {UnknownRR("example.org", 65280, 300, []byte{1, 2, 3, 4})},
+			},
+		},
+	}
+	err := AddZoneFile(zones, strings.NewReader(
+		"file.example.org. 300 IN TYPE65281 \\# 2 abcd\n"), ".", "test.zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	cl := dns.Client{Timeout: time.Second}
+	for _, c := range []struct {
+		name  string
+		qtype uint16
+		rdata string
+	}{
+		{"example.org.", 65280, "01020304"},
+		{"file.example.org.", 65281, "abcd"},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(c.name, c.qtype)
+		reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reply.Answer) != 1 {
+			t.Fatalf("Wrong result, want %v, got %v", 1, len(reply.Answer))
+		}
+		rr, ok := reply.Answer[0].(*dns.RFC3597)
+		if !ok || rr.Hdr.Rrtype != c.qtype || rr.Rdata != c.rdata {
+			t.Fatalf("Wrong result, want %v, got %v", c.rdata, reply.Answer[0])
+		}
+	}
+}
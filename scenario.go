@@ -0,0 +1,229 @@
+package mockdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the declarative timeline of Server behaviors, see
+// NewServerFromScenario.
+//
+// It is encoded as YAML or JSON, so scenarios can be shared with test suites
+// in other languages:
+//
+//	zones:
+//	  v1: |
+//	    example.org. 300 IN A 192.0.2.1
+//	  v2: |
+//	    example.org. 300 IN A 192.0.2.2
+//	steps:
+//	  - fault: timeout
+//	    queries: 3
+//	  - zones: v1
+//	    duration: 5s
+//	  - zones: v2
+//
+// The same scenario as JSON:
+//
+//	{
+//	  "zones": {
+//	    "v1": "example.org. 300 IN A 192.0.2.1",
+//	    "v2": "example.org. 300 IN A 192.0.2.2"
+//	  },
+//	  "steps": [
+//	    {"fault": "timeout", "queries": 3},
+//	    {"zones": "v1", "duration": "5s"},
+//	    {"zones": "v2"}
+//	  ]
+//	}
+type Scenario struct {
+	// Zones contains named zone sets in the RFC 1035 zone file format.
+	// Relative names are not allowed unless $ORIGIN is set.
+	Zones map[string]string `json:"zones" yaml:"zones"`
+
+	Steps []ScenarioStep `json:"steps" yaml:"steps"`
+}
+
+// ScenarioStep is the Server state used until the step ends.
+type ScenarioStep struct {
+	// Zones is the name of the zone set to serve, previous zones are kept
+	// if it is empty.
+	Zones string `json:"zones,omitempty" yaml:"zones,omitempty"`
+
+	// Fault is the Fault name as returned by Fault.String, e.g. "timeout",
+	// "none" if empty.
+	Fault string `json:"fault,omitempty" yaml:"fault,omitempty"`
+
+	// Queries is the amount of queries the step lasts for.
+	Queries int `json:"queries,omitempty" yaml:"queries,omitempty"`
+
+	// Duration is the time the step lasts for, in time.ParseDuration
+	// format. If both Queries and Duration are set, the step ends when
+	// either is reached. The last step lasts forever.
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+}
+
+type scenarioStep struct {
+	zones    map[string]Zone
+	fault    Fault
+	queries  int
+	duration time.Duration
+}
+
+// scenarioRunner switches Server state according to the scenario.
+type scenarioRunner struct {
+	s     *Server
+	steps []scenarioStep
+
+	mu      sync.Mutex
+	step    int
+	queries int
+	timer   *time.Timer
+}
+
+// parseScenario decodes the scenario from YAML, or from JSON if it starts
+// with {.
+func parseScenario(r io.Reader) (*Scenario, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sc := &Scenario{}
+	if err := unmarshalDocument(data, sc); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// unmarshalDocument decodes data from YAML, or from JSON if it starts with {.
+func unmarshalDocument(data []byte, v interface{}) error {
+	if text := strings.TrimSpace(string(data)); strings.HasPrefix(text, "{") {
+		return json.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+func parseFault(name string) (Fault, error) {
+	f, ok := faultNames[name]
+	if !ok {
+		return FaultNone, fmt.Errorf("unknown fault: %s", name)
+	}
+	return f, nil
+}
+
+func compileScenario(sc *Scenario) ([]scenarioStep, error) {
+	zoneSets := make(map[string]map[string]Zone, len(sc.Zones))
+	for name, text := range sc.Zones {
+		zones := make(map[string]Zone)
+		if err := AddZoneFile(zones, strings.NewReader(text), ".", "zones "+name); err != nil {
+			return nil, err
+		}
+		zoneSets[name] = zones
+	}
+
+	steps := make([]scenarioStep, len(sc.Steps))
+	for i, st := range sc.Steps {
+		if st.Zones != "" {
+			zones, ok := zoneSets[st.Zones]
+			if !ok {
+				return nil, fmt.Errorf("step %d: unknown zones: %s", i, st.Zones)
+			}
+			steps[i].zones = zones
+		}
+		if st.Fault != "" {
+			f, err := parseFault(st.Fault)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %v", i, err)
+			}
+			steps[i].fault = f
+		}
+		if st.Duration != "" {
+			d, err := time.ParseDuration(st.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %v", i, err)
+			}
+			steps[i].duration = d
+		}
+		steps[i].queries = st.Queries
+	}
+	return steps, nil
+}
+
+// NewServerFromScenario starts the Server following the YAML or
+// JSON-encoded Scenario read from r. Steps are switched before handling the first query
+// of the next step, so a step with Queries set to N affects exactly N
+// queries.
+func NewServerFromScenario(r io.Reader) (*Server, error) {
+	sc, err := parseScenario(r)
+	if err != nil {
+		return nil, fmt.Errorf("malformed scenario: %v", err)
+	}
+	steps, err := compileScenario(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newServer(NewZoneSource(map[string]Zone{}), defaultLogger(), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) != 0 {
+		s.scenario = &scenarioRunner{s: s, steps: steps}
+		s.scenario.enter(0)
+	}
+	s.serve()
+	return s, nil
+}
+
+// enter applies the step i. r.mu must be held or r must not be shared yet.
+func (r *scenarioRunner) enter(i int) {
+	r.step = i
+	r.queries = 0
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+
+	st := r.steps[i]
+	if st.zones != nil {
+		r.s.SetZones(st.zones)
+	}
+	r.s.SetFault(st.fault)
+
+	if st.duration != 0 && i < len(r.steps)-1 {
+		r.timer = time.AfterFunc(st.duration, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.step == i {
+				r.enter(i + 1)
+			}
+		})
+	}
+}
+
+// onQuery is called for each query before it is handled.
+func (r *scenarioRunner) onQuery() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := r.steps[r.step]
+	if st.queries != 0 && r.queries >= st.queries && r.step < len(r.steps)-1 {
+		r.enter(r.step + 1)
+	}
+	r.queries++
+}
+
+func (r *scenarioRunner) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
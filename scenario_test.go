@@ -0,0 +1,175 @@
+package mockdns
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testScenario = `{
+  "zones": {
+    "v1": "example.org. 300 IN A 192.0.2.1",
+    "v2": "example.org. 300 IN A 192.0.2.2"
+  },
+  "steps": [
+    {"fault": "servfail", "queries": 2},
+    {"zones": "v1", "duration": "100ms"},
+    {"zones": "v2"}
+  ]
+}`
+
+const testScenarioYAML = `
+# Same as testScenario.
+zones:
+  v1: |
+    example.org. 300 IN A 192.0.2.1
+  v2: "example.org. 300 IN A 192.0.2.2"
+steps:
+  - fault: servfail
+    queries: 2
+  - zones: v1
+    duration: 100ms # comment
+  - zones: v2
+`
+
+func TestNewServerFromScenario(t *testing.T) {
+	t.Run("json", func(t *testing.T) { testScenarioServer(t, testScenario) })
+	t.Run("yaml", func(t *testing.T) { testScenarioServer(t, testScenarioYAML) })
+}
+
+func testScenarioServer(t *testing.T, scenario string) {
+	srv, err := NewServerFromScenario(strings.NewReader(scenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	cl := dns.Client{Timeout: time.Second}
+	query := func() (int, string) {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reply.Answer) == 0 {
+			return reply.Rcode, ""
+		}
+		return reply.Rcode, reply.Answer[0].(*dns.A).A.String()
+	}
+
+	for i := 0; i < 2; i++ {
+		if rcode, _ := query(); rcode != dns.RcodeServerFailure {
+			t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, rcode)
+		}
+	}
+	if rcode, addr := query(); rcode != dns.RcodeSuccess || addr != "192.0.2.1" {
+		t.Fatalf("Wrong result, want %v, got %v (rcode %v)", "192.0.2.1", addr, rcode)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if rcode, addr := query(); rcode != dns.RcodeSuccess || addr != "192.0.2.2" {
+		t.Fatalf("Wrong result, want %v, got %v (rcode %v)", "192.0.2.2", addr, rcode)
+	}
+}
+
+func TestParseScenario_YAMLScalars(t *testing.T) {
+	// Unquoted numbers and flow collections are decoded as strings where
+	// strings are expected.
+	sc, err := parseScenario(strings.NewReader(`
+zones: {1: "example.org. 300 IN TXT 123"}
+steps: [{zones: 1, queries: 2}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Scenario{
+		Zones: map[string]string{"1": "example.org. 300 IN TXT 123"},
+		Steps: []ScenarioStep{{Zones: "1", Queries: 2}},
+	}
+	if !reflect.DeepEqual(sc, want) {
+		t.Fatalf("Wrong result, want %+v, got %+v", want, sc)
+	}
+}
+
+func TestNewServerFromScenario_Invalid(t *testing.T) {
+	for _, sc := range []string{
+		`{"steps": [{"fault": "flaky"}]}`,
+		`{"steps": [{"zones": "missing"}]}`,
+		`{"steps": [{"duration": "5 minutes"}]}`,
+		`{"steps": [`,
+		"steps:\n  - fault: flaky\n",
+		"steps:\n  - queries: many\n",
+	} {
+		srv, err := NewServerFromScenario(strings.NewReader(sc))
+		if err == nil {
+			srv.Close()
+			t.Fatalf("Expected error for %s", sc)
+		}
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	for name, f := range faultNames {
+		if f.String() != name {
+			t.Errorf("Wrong result, want %v, got %v", name, f.String())
+		}
+		if got, err := parseFault(name); err != nil || got != f {
+			t.Errorf("Wrong result, want %v, got %v (%v)", f, got, err)
+		}
+	}
+}
+
+// TestNewServerFromScenario_Concurrent switches zones from timers while
+// queries are being served, it is meant to be run with -race.
+func TestNewServerFromScenario_Concurrent(t *testing.T) {
+	srv, err := NewServerFromScenario(strings.NewReader(`{
+  "zones": {
+    "v1": "example.org. 300 IN A 192.0.2.1",
+    "v2": "example.org. 300 IN A 192.0.2.2"
+  },
+  "steps": [
+    {"zones": "v1", "duration": "5ms"},
+    {"zones": "v2", "duration": "5ms"},
+    {"zones": "v1", "duration": "5ms"},
+    {"zones": "v2", "duration": "5ms"},
+    {"zones": "v1"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// Views make ServeDNS copy the Server Resolver.
+	if err := srv.AddView("other", map[string]Zone{}, "198.51.100.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl := dns.Client{Timeout: time.Second}
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			for deadline := time.Now().Add(40 * time.Millisecond); time.Now().Before(deadline); {
+				reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(reply.Answer) != 1 {
+					t.Errorf("Wrong result, want %v, got %v", 1, len(reply.Answer))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,142 @@
+package mockdns
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SerialPolicy controls serials of SOA records sent by Server, see
+// Server.SOASerial.
+type SerialPolicy int
+
+const (
+	// SerialFixed makes all SOA records have serial 1.
+	SerialFixed SerialPolicy = iota
+	// SerialMonotonic makes the serial start at 1 and be incremented on
+	// each change of the zone.
+	SerialMonotonic
+	// SerialDate makes the serial use the YYYYMMDDnn format, with nn
+	// incremented on each change of the zone during the day. As with real
+	// servers, more than 100 changes per day make the serial run ahead of
+	// the date.
+	SerialDate
+)
+
+// zoneSerial is the serial state of a zone that changed at least once.
+type zoneSerial struct {
+	changes uint32
+	date    uint32
+}
+
+// dateSerial returns the first YYYYMMDDnn serial for the day of t.
+func dateSerial(t time.Time) uint32 {
+	y, m, d := t.UTC().Date()
+	return uint32(y*1000000 + int(m)*10000 + d*100)
+}
+
+// serialKey identifies the zone serials are tracked for. view is empty for
+// Server zones.
+type serialKey struct {
+	view, zone string
+}
+
+// bumpSerials is called by ZoneSource on each change with names of zones
+// that were added, removed or modified, src.mu is held.
+func (src *ZoneSource) bumpSerials(changed []string) {
+	src.bumpViewSerials("", changed)
+}
+
+// bumpViewSerials increments serials of changed zones of the view, src.mu is
+// held.
+func (src *ZoneSource) bumpViewSerials(view string, changed []string) {
+	prev := src.serials.Load().(map[serialKey]zoneSerial)
+	serials := make(map[serialKey]zoneSerial, len(prev)+1)
+	for key, serial := range prev {
+		serials[key] = serial
+	}
+
+	today := dateSerial(time.Now())
+	bump := func(name string) {
+		key := serialKey{view: view, zone: normalizeName(name)}
+		serial, ok := serials[key]
+		if !ok {
+			serial.date = src.initDate
+		}
+		serial.changes++
+		if today > serial.date {
+			serial.date = today
+		} else {
+			serial.date++
+		}
+		serials[key] = serial
+	}
+	for _, name := range changed {
+		bump(name)
+	}
+
+	src.serials.Store(serials)
+}
+
+// Serial returns the SOA serial for the zone with the specified name
+// according to the policy. Each zone has its own serial, incremented when
+// it is added, removed or modified.
+func (src *ZoneSource) Serial(name string, policy SerialPolicy) uint32 {
+	return src.viewSerial("", name, policy)
+}
+
+func (src *ZoneSource) viewSerial(view, name string, policy SerialPolicy) uint32 {
+	key := serialKey{view: view, zone: normalizeName(name)}
+	serial, changed := src.serials.Load().(map[serialKey]zoneSerial)[key]
+	switch policy {
+	case SerialMonotonic:
+		return 1 + serial.changes
+	case SerialDate:
+		if !changed {
+			return src.initDate
+		}
+		return serial.date
+	}
+	return 1
+}
+
+// soaZone returns the name of the zone the SOA record for name is sent for:
+// the closest zone at or above name. name is returned as is if there is
+// none, e.g. for names answered by CaptivePortal.
+func soaZone(r *Resolver, name string) string {
+	for zoneName := name; ; {
+		if _, ok := r.zone(zoneName); ok {
+			return zoneName
+		}
+		if zoneName == "." {
+			return name
+		}
+		labels := dns.SplitDomainName(zoneName)
+		zoneName = dns.Fqdn(strings.Join(labels[1:], "."))
+	}
+}
+
+// setSerials replaces serials of SOA records in the reply, see
+// Server.SOASerial. view and r are the ones the reply was built for.
+func (s *Server) setSerials(view string, r *Resolver, reply *dns.Msg) {
+	for _, section := range []*[]dns.RR{&reply.Answer, &reply.Ns} {
+		w := sectionWriter{section: section}
+		for i, rr := range *section {
+			soa, ok := rr.(*dns.SOA)
+			if !ok {
+				continue
+			}
+			// Owners of negative answer SOA records are the queried names,
+			// serials are kept for zones.
+			zone := soaZone(r, normalizeName(soa.Hdr.Name))
+			serial := s.r.src.viewSerial(view, zone, s.SOASerial)
+			if soa.Serial == serial {
+				continue
+			}
+			soa = dns.Copy(soa).(*dns.SOA)
+			soa.Serial = serial
+			w.set(i, soa)
+		}
+	}
+}
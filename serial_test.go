@@ -0,0 +1,127 @@
+package mockdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_SOASerial(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.":       {A: []string{"192.0.2.1"}},
+		"other.example.org.": {A: []string{"192.0.2.3"}},
+	}
+	serve := func(policy SerialPolicy) *Server {
+		return newTestServer(t, zones, func(s *Server) {
+			s.SOASerial = policy
+		})
+	}
+	serial := func(srv *Server, name string) uint32 {
+		t.Helper()
+		reply := queryFrom(t, srv, "127.0.0.1", name, dns.TypeSOA)
+		if len(reply.Answer) != 1 {
+			t.Fatalf("Wrong answer: %v", reply.Answer)
+		}
+		return reply.Answer[0].(*dns.SOA).Serial
+	}
+
+	t.Run("fixed", func(t *testing.T) {
+		srv := serve(SerialFixed)
+		defer srv.Close()
+		srv.AddZone("example.org.", Zone{A: []string{"192.0.2.2"}})
+		if got := serial(srv, "example.org."); got != 1 {
+			t.Fatalf("Wrong result, want %v, got %v", 1, got)
+		}
+	})
+
+	t.Run("monotonic", func(t *testing.T) {
+		srv := serve(SerialMonotonic)
+		defer srv.Close()
+		if got := serial(srv, "example.org."); got != 1 {
+			t.Fatalf("Wrong result, want %v, got %v", 1, got)
+		}
+
+		// Changes of other zones do not affect the serial.
+		srv.AddZone("new.example.org.", Zone{A: []string{"192.0.2.2"}})
+		srv.RemoveZone("new.example.org.")
+		if got := serial(srv, "example.org."); got != 1 {
+			t.Fatalf("Wrong result, want %v, got %v", 1, got)
+		}
+		srv.AddZone("new.example.org.", Zone{A: []string{"192.0.2.2"}})
+		if got := serial(srv, "new.example.org."); got != 4 {
+			t.Fatalf("Wrong result, want %v, got %v", 4, got)
+		}
+
+		srv.AddZone("example.org.", Zone{A: []string{"192.0.2.2"}})
+		if got := serial(srv, "example.org."); got != 2 {
+			t.Fatalf("Wrong result, want %v, got %v", 2, got)
+		}
+		// Replacing the zone with the same one is not a change.
+		srv.AddZone("example.org.", Zone{A: []string{"192.0.2.2"}})
+		if got := serial(srv, "example.org."); got != 2 {
+			t.Fatalf("Wrong result, want %v, got %v", 2, got)
+		}
+	})
+
+	t.Run("date", func(t *testing.T) {
+		srv := serve(SerialDate)
+		defer srv.Close()
+		before := serial(srv, "example.org.")
+		if today := dateSerial(time.Now()); before < today || before > today+2 {
+			t.Fatalf("Wrong result, want %v..%v, got %v", today, today+2, before)
+		}
+		srv.AddZone("example.org.", Zone{A: []string{"192.0.2.2"}})
+		if got := serial(srv, "example.org."); got != before+1 {
+			t.Fatalf("Wrong result, want %v, got %v", before+1, got)
+		}
+		if got := serial(srv, "other.example.org."); got != before {
+			t.Fatalf("Wrong result, want %v, got %v", before, got)
+		}
+
+		// Negative answers use the serial too.
+		reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeMX)
+		if len(reply.Ns) != 1 || reply.Ns[0].(*dns.SOA).Serial != before+1 {
+			t.Fatalf("Wrong authority: %v", reply.Ns)
+		}
+		// NXDOMAIN answers use the serial of the zone above the name.
+		reply = queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeA)
+		if len(reply.Ns) != 1 || reply.Ns[0].(*dns.SOA).Serial != before+1 {
+			t.Fatalf("Wrong authority: %v", reply.Ns)
+		}
+	})
+
+	t.Run("views", func(t *testing.T) {
+		srv := serve(SerialMonotonic)
+		defer srv.Close()
+		if err := srv.AddView("internal", zones, "127.0.0.2/32"); err != nil {
+			t.Fatal(err)
+		}
+		err := srv.AddView("internal", map[string]Zone{
+			"example.org.":       {A: []string{"10.0.0.1"}},
+			"other.example.org.": {A: []string{"192.0.2.3"}},
+		}, "127.0.0.2/32")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := serial(srv, "example.org."); got != 1 {
+			t.Fatalf("Wrong result, want %v, got %v", 1, got)
+		}
+		reply := queryFrom(t, srv, "127.0.0.2", "example.org.", dns.TypeSOA)
+		if len(reply.Answer) != 1 || reply.Answer[0].(*dns.SOA).Serial != 2 {
+			t.Fatalf("Wrong answer: %v", reply.Answer)
+		}
+		reply = queryFrom(t, srv, "127.0.0.2", "other.example.org.", dns.TypeSOA)
+		if len(reply.Answer) != 1 || reply.Answer[0].(*dns.SOA).Serial != 1 {
+			t.Fatalf("Wrong answer: %v", reply.Answer)
+		}
+	})
+}
+
+func TestDateSerial(t *testing.T) {
+	got := dateSerial(time.Date(2021, 3, 7, 23, 0, 0, 0, time.UTC))
+	if got != 2021030700 {
+		t.Fatalf("Wrong result, want %v, got %v", 2021030700, got)
+	}
+}
@@ -3,10 +3,14 @@ package mockdns
 import (
 	"context"
 	"errors"
+	"expvar"
 	"log"
+	"math/rand"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -15,6 +19,9 @@ import (
 // Server is the wrapper that binds Resolver to the DNS server implementation
 // from github.com/miekg/dns. This allows it to be used as a replacement
 // resolver for testing code that doesn't support DNS callbacks. See PatchNet.
+//
+// Server builds DNS records for each zone once and reuses them until zones
+// are changed.
 type Server struct {
 	r       Resolver
 	stopped bool
@@ -23,6 +30,317 @@ type Server struct {
 
 	Log           Logger
 	Authoritative bool
+
+	// SuggestZones makes Server log names of zones similar to the queried
+	// name when it is not found, to help spotting typos in test setup. See
+	// Resolver.ClosestZones.
+	SuggestZones bool
+
+	// Tracer, if set, is used to create spans around handled queries.
+	Tracer Tracer
+
+	// NegativeTTL is the TTL clients should use to cache negative (NXDOMAIN
+	// and NODATA) responses. It is used both as the TTL and MINIMUM field of
+	// the SOA record in the authority section, see RFC 2308. Zone.NegativeTTL
+	// takes precedence over it. If both are zero, the default of 60 seconds
+	// is used.
+	NegativeTTL uint32
+
+	// Clock returns the current time for time-dependent behavior, such as
+	// SimulateCache and ServeStale. time.Now is used if it is nil.
+	Clock func() time.Time
+
+	// SimulateCache makes the Server behave like a recursive resolver that
+	// answers from its cache: TTLs in answers are decreased by the time
+	// passed (according to Clock) since the record was first returned. Once
+	// TTL reaches zero, the record is considered refetched and is returned
+	// with full TTL again.
+	SimulateCache bool
+
+	// ServeStale modifies SimulateCache behavior so that expired records are
+	// not refetched but served stale instead, as a resolver implementing
+	// RFC 8767 does when it is unable to refresh them. Stale records have
+	// the TTL of 30 seconds recommended by RFC 8767, Section 4.
+	ServeStale bool
+
+	// MaxTCPConns limits the amount of concurrently open TCP connections.
+	// Connections above the limit are accepted and immediately closed
+	// without reading any queries. Zero means no limit.
+	MaxTCPConns int
+
+	// TCPPipeline is the amount of queries received over a single TCP
+	// connection that are handled concurrently (RFC 7766 pipelining).
+	// Replies are sent as soon as they are ready, so they may be out of
+	// order. The connection is not read while the limit is reached. Zero or
+	// one means queries are handled one at a time, in order.
+	TCPPipeline int
+
+	// TCPWorkers limits the amount of TCP queries handled concurrently over
+	// all connections, as with a fixed-size worker pool. Queries above the
+	// limit wait for a free worker. Zero means no limit.
+	TCPWorkers int
+
+	// MaxTCPQueries limits the amount of queries served over a single TCP
+	// connection. The connection is closed by the server after the reply to
+	// the last allowed query is sent. Zero means no limit.
+	MaxTCPQueries int
+
+	// TCPWriteSize, if positive, makes Server write TCP replies (including
+	// the length prefix) in chunks of at most this amount of bytes, waiting
+	// TCPWriteDelay before each chunk but the first one. This simulates slow
+	// links to test client read deadlines and partial reads.
+	TCPWriteSize  int
+	TCPWriteDelay time.Duration
+
+	// OffPath, if set, is called with the query and a copy of the reply for
+	// each UDP query. The returned message, if not nil, is sent to the
+	// client from a different local port right before the legitimate
+	// reply, as an off-path attacker would do. Clients validating the
+	// source address and port ignore it.
+	OffPath func(query, reply *dns.Msg) *dns.Msg
+
+	// Cookies enables DNS cookies (RFC 7873): replies to queries with the
+	// client cookie include the server cookie, and queries not echoing it
+	// later are recorded, see CookieViolations.
+	Cookies bool
+
+	// RecycleReplies makes Server reuse reply messages to reduce GC
+	// pressure. Replies are not retained in the log returned by Queries
+	// (Reply is nil) and QueryInfo.Reply passed to OnQuery callbacks must
+	// not be used after the callback returns.
+	//
+	// It is read when the first query is received, later changes have no
+	// effect.
+	RecycleReplies bool
+
+	// AdditionalSRV makes Server include A and AAAA records of SRV targets
+	// in the additional section of SRV answers, if targets are present in
+	// zones.
+	AdditionalSRV bool
+
+	// AdditionalNS makes Server include A and AAAA records (glue) of name
+	// servers in the additional section of NS answers, if name servers are
+	// present in zones.
+	AdditionalNS bool
+
+	// AuthorityNS makes Server include NS records of the closest enclosing
+	// name that has them in the authority section of positive answers. Glue
+	// records are added too if AdditionalNS is set.
+	AuthorityNS bool
+
+	// FilterInvalidHosts makes Server omit MX, NS and SRV records with host
+	// names that are not valid domain names from answers, so clients see
+	// the same records as with Resolver.FilterInvalidHosts, regardless of
+	// their own filtering.
+	FilterInvalidHosts bool
+
+	// SOASerial controls serials of sent SOA records. By default, it is
+	// always 1. Other policies make the serial of a zone change when it is
+	// changed by AddZone, RemoveZone, SetZones, AddView or the ZoneSource,
+	// so clients detecting changes using serials can be tested. Negative
+	// answers for names without a zone use the serial of the closest zone
+	// above them.
+	SOASerial SerialPolicy
+
+	// ResolvConf contains options applied to resolvers patched using
+	// PatchNet, as if they were set in the host resolv.conf.
+	ResolvConf ResolvConf
+
+	// FlattenCNAME makes Server flatten CNAMEs of all names, as if they had
+	// Zone.FlattenCNAME set.
+	FlattenCNAME bool
+
+	// ClassANY controls how queries with QCLASS ANY (255), sent by some
+	// legacy clients, are answered. By default, they are answered with IN
+	// class data.
+	ClassANY ClassPolicy
+
+	// ANY controls how queries for the ANY type are answered. By default,
+	// they are answered with NODATA.
+	ANY ANYPolicy
+
+	// StatsPath, if set, makes Close write Stats to the file at this path as
+	// JSON, e.g. to keep it as a CI artifact.
+	StatsPath string
+
+	// NoRecursion makes Server clear the Recursion Available (RA) flag in
+	// replies, as an authoritative-only server would. Authoritative servers
+	// always clear it.
+	NoRecursion bool
+
+	// RefuseRecursion makes Server reply with REFUSED to queries with the
+	// Recursion Desired (RD) flag set.
+	RefuseRecursion bool
+
+	// EmptyNonTerminals makes Server reply with NODATA instead of NXDOMAIN
+	// to queries for names that have no zone but are ancestors of names that
+	// have one. This is required by resolvers using QNAME minimization
+	// (RFC 9156), which query ancestor names first and stop at NXDOMAIN.
+	// Ancestors with NS records are answered as usual, so delegations are
+	// followed. Bulk names are not considered.
+	EmptyNonTerminals bool
+
+	// HijackNXDOMAIN contains addresses of a "search portal" sent in reply
+	// to A and AAAA queries that would result in NXDOMAIN, simulating DNS
+	// hijacking done by some ISPs. Queries of other types and queries for
+	// which there are no addresses of the right family still get NXDOMAIN.
+	HijackNXDOMAIN []string
+
+	// CaptivePortal contains addresses sent in reply to all A and AAAA
+	// queries regardless of zones, emulating a captive portal. Queries of
+	// other types and queries for which there are no addresses of the right
+	// family get NODATA.
+	CaptivePortal []string
+
+	// SinkholeAddrs are sent in reply to A and AAAA queries for names with
+	// Zone.Sinkhole set. Defaults to 0.0.0.0 and ::.
+	SinkholeAddrs []string
+
+	// SinkholeTXT is the text of the TXT record identifying sinkholed
+	// replies. Defaults to DefaultSinkholeTXT.
+	SinkholeTXT string
+
+	// Blocklist, if set, is checked before zones. Names in it are answered
+	// as if they had Zone.Sinkhole set, regardless of zones.
+	Blocklist *Blocklist
+
+	// ZeroTTL makes Server send all records with TTL 0, including SOA
+	// records in negative replies (MINIMUM is set to 0 too), so clients
+	// should not cache anything.
+	ZeroTTL bool
+
+	// TTLJitter, if non-zero, makes Server randomize TTLs of sent records
+	// within the band of this fraction around the original value, e.g. 0.1
+	// makes records with TTL 300 have TTL between 270 and 330. Random
+	// values are generated from TTLJitterSeed, it should be set before the
+	// first query.
+	TTLJitter     float64
+	TTLJitterSeed int64
+
+	// MinTTL and MaxTTL, if non-zero, clamp TTLs of all sent records
+	// (including SOA MINIMUM) regardless of zones, after TTLJitter is
+	// applied. Setting both to the same value overrides all TTLs.
+	MinTTL uint32
+	MaxTTL uint32
+
+	// Latency, if set, is the distribution of delays applied before
+	// sending each reply, unless Zone.Latency is set for the queried name.
+	// Random values are generated from LatencySeed, it should be set before
+	// the first query.
+	Latency     LatencyDist
+	LatencySeed int64
+
+	// MaxUDPSize limits the size of replies sent over UDP, regardless of
+	// the EDNS buffer size advertised by the client. Larger replies are
+	// truncated and sent with the TC flag set. Values below 512 are treated
+	// as 512. Zero means no limit.
+	MaxUDPSize int
+
+	// MaxTCPSize limits the size of replies sent over TCP. Larger replies
+	// are dropped and the connection is closed, as some middleboxes do.
+	// Zero means no limit.
+	MaxTCPSize int
+
+	// Templates enables expansion of text/template placeholders in
+	// record text, e.g. TXT record "token-{{ .Counter }}" or
+	// "{{ .QName }} from {{ .Client }}". Placeholders are expanded for each
+	// query using TemplateData. Records that fail to expand result in
+	// SERVFAIL.
+	Templates bool
+
+	mu      sync.Mutex
+	onQuery []func(QueryInfo)
+	counts  map[queryKey]int
+	queries []QueryInfo
+
+	statsCounts map[statsKey]int
+
+	expQueries *expvar.Map
+	expRcodes  *expvar.Map
+
+	checks     []QueryCheck
+	violations []error
+
+	latency latencyRing
+
+	cacheSim map[queryKey]time.Time
+
+	ttlRand     *rand.Rand
+	latencyRand *rand.Rand
+
+	// cookies contains the last cookie sent to each client IP.
+	cookies          map[string]string
+	cookieSecret     []byte
+	cookieViolations []error
+
+	scenario *scenarioRunner
+	injected []injectedReply
+
+	typeHandlers map[uint16]TypeHandler
+
+	resolv *resolvState
+
+	// cache contains *zoneCache for the current zones.
+	cache   atomic.Value
+	viewGen uint32
+
+	onStage       []func(StageInfo)
+	hasStageHooks int32
+	parseStart    map[uint16][]time.Time
+	parseSwept    time.Time
+
+	views   []View
+	regions []region
+
+	clientBehaviors []clientBehavior
+
+	fault      Fault
+	faultTimer *time.Timer
+
+	// templates contains parsed *template.Template for records, keyed by
+	// record text.
+	templates       sync.Map
+	templateCounter uint64
+
+	// fuzzInput is the remaining input for reply mutations, see
+	// FuzzServer.
+	fuzzInput []byte
+
+	// tcpConns contains open TCP connections, keyed by remote address.
+	tcpConns map[string]*tcpConn
+
+	recycleOnce sync.Once
+	recycle     bool
+
+	workersOnce sync.Once
+	tcpWorkers  chan struct{}
+
+	// Concurrency gauges, see Gauges.
+	udpInFlight     int
+	peakUDPInFlight int
+	peakTCPConns    int
+}
+
+type queryKey struct {
+	name  string
+	qtype uint16
+}
+
+// QueryInfo describes a query served by Server. See OnQuery.
+type QueryInfo struct {
+	// Question is the first question from the query. It is zero if query
+	// contains no questions.
+	Question dns.Question
+
+	// Query and Reply are the received query and the sent reply. They
+	// should not be modified.
+	Query *dns.Msg
+	Reply *dns.Msg
+
+	RemoteAddr net.Addr
+
+	// Time is the moment the query was received by the Server.
+	Time time.Time
 }
 
 type Logger interface {
@@ -30,18 +348,46 @@ type Logger interface {
 }
 
 func NewServer(zones map[string]Zone, authoritative bool) (*Server, error) {
-	return NewServerWithLogger(zones, log.New(os.Stderr, "mockdns server: ", log.LstdFlags), authoritative)
+	return NewServerWithLogger(zones, defaultLogger(), authoritative)
+}
+
+func defaultLogger() Logger {
+	return log.New(os.Stderr, "mockdns server: ", log.LstdFlags)
 }
 
 func NewServerWithLogger(zones map[string]Zone, l Logger, authoritative bool) (*Server, error) {
+	return NewServerWithSource(NewZoneSource(zones), l, authoritative)
+}
+
+// NewServerWithSource starts the Server serving zones from src, which can be
+// shared with other Servers.
+func NewServerWithSource(src *ZoneSource, l Logger, authoritative bool) (*Server, error) {
+	s, err := newServer(src, l, authoritative)
+	if err != nil {
+		return nil, err
+	}
+	s.serve()
+	return s, nil
+}
+
+// newServer creates the Server bound to a random port without serving
+// queries yet, so unexported fields can be set up without races.
+func newServer(src *ZoneSource, l Logger, authoritative bool) (*Server, error) {
+	seen := new(atomic.Value)
+	seen.Store(src.load().base)
 	s := &Server{
 		r: Resolver{
-			Zones: zones,
+			Zones: src.load().base,
+			src:   src,
+			seen:  seen,
 		},
 		tcpServ:       dns.Server{Addr: "127.0.0.1:0", Net: "tcp"},
 		udpServ:       dns.Server{Addr: "127.0.0.1:0", Net: "udp"},
 		Log:           l,
 		Authoritative: authoritative,
+		counts:        make(map[queryKey]int),
+		statsCounts:   make(map[statsKey]int),
+		tcpConns:      make(map[string]*tcpConn),
 	}
 
 	tcpL, err := net.Listen("tcp4", "127.0.0.1:0")
@@ -59,64 +405,204 @@ func NewServerWithLogger(zones map[string]Zone, l Logger, authoritative bool) (*
 		return nil, err
 	}
 
-	s.tcpServ.Listener = tcpL
-	s.tcpServ.Handler = s
+	s.tcpServ.Listener = &tcpListener{Listener: tcpL, s: s}
+	// Limits are enforced by Server itself, see MaxTCPQueries.
+	s.tcpServ.MaxTCPQueries = -1
+	s.tcpServ.Handler = dns.HandlerFunc(s.serveTCP)
+	s.tcpServ.MsgAcceptFunc = s.acceptMsg
 	s.udpServ.PacketConn = pconn
+	s.r.ErrServer = pconn.LocalAddr().String()
 	s.udpServ.Handler = s
+	s.udpServ.MsgAcceptFunc = s.acceptMsg
 
+	return s, nil
+}
+
+func (s *Server) serve() {
 	go s.tcpServ.ActivateAndServe()
 	go s.udpServ.ActivateAndServe()
-
-	return s, nil
 }
 
-func (s *Server) writeErr(w dns.ResponseWriter, reply *dns.Msg, err error) {
+func (s *Server) writeErr(w dns.ResponseWriter, m *dns.Msg, received time.Time, reply *dns.Msg, err error) {
 	reply.Rcode = dns.RcodeServerFailure
 	reply.RecursionAvailable = false
 	reply.Answer = nil
 	reply.Extra = nil
 
+	_, r := s.resolverFor(w.RemoteAddr(), m)
+	if len(m.Question) == 0 || !s.attachEDE(reply, m, r, normalizeName(m.Question[0].Name)) {
+		// Zone.EDE takes precedence over the automatic classification.
+		if ede, ok := edeFor(err); ok {
+			addEDE(reply, m, ede)
+		}
+	}
+
 	if dnsErr, ok := err.(*net.DNSError); ok {
 		if isNotFound(dnsErr) {
-			reply.Rcode = dns.RcodeNameError
-			reply.RecursionAvailable = true
-			reply.Ns = []dns.RR{
-				&dns.SOA{
-					Hdr: dns.RR_Header{
-						Name:   dnsErr.Name,
-						Rrtype: dns.TypeSOA,
-						Class:  dns.ClassINET,
-						Ttl:    9999,
-					},
-					Ns:      "localhost.",
-					Mbox:    "hostmaster.localhost.",
-					Serial:  1,
-					Refresh: 900,
-					Retry:   900,
-					Expire:  1800,
-					Minttl:  60,
-				},
+			// Names in errors set by users (e.g. NotFound in Zone.Err) may be
+			// not fully qualified.
+			name := dns.Fqdn(dnsErr.Name)
+			if s.SuggestZones {
+				if suggestions := r.ClosestZones(name, maxSuggestions); len(suggestions) != 0 {
+					s.Log.Printf("no zone for %s, did you mean: %s?", name, strings.Join(suggestions, ", "))
+				}
+			}
+			reply.RecursionAvailable = s.recursionAvailable()
+			if hijacked := s.portalRRs(m.Question[0], s.HijackNXDOMAIN, hijackTTL); len(hijacked) != 0 {
+				reply.Rcode = dns.RcodeSuccess
+				reply.Answer = hijacked
+				reply.Ns = nil
+			} else {
+				reply.Rcode = dns.RcodeNameError
+				reply.Ns = []dns.RR{s.negativeSOA(r, name)}
 			}
 		}
+	} else if cnameErr, ok := err.(*CNAMEError); ok {
+		// Deliberately SERVFAIL, as real resolvers do for broken chains.
+		s.Log.Printf("broken CNAME chain: %v", cnameErr)
 	} else {
 		s.Log.Printf("lookup error: %v", err)
 	}
 
-	w.WriteMsg(reply)
+	s.writeReply(w, m, received, reply)
 }
 
-func mkCname(name, cname string) *dns.CNAME {
+// writeReply records the query, notifies OnQuery callbacks and sends the
+// reply to the client.
+func (s *Server) writeReply(w dns.ResponseWriter, m *dns.Msg, received time.Time, reply *dns.Msg) {
+	s.handleCookie(w.RemoteAddr(), m, reply)
+
+	if s.ZeroTTL {
+		zeroTTLs(reply)
+	} else if s.TTLJitter != 0 {
+		s.jitterTTLs(reply)
+	}
+	if s.MinTTL != 0 || s.MaxTTL != 0 {
+		s.clampTTLs(reply)
+	}
+	if s.SOASerial != SerialFixed {
+		view, r := s.resolverFor(w.RemoteAddr(), m)
+		s.setSerials(view, r, reply)
+	}
+	s.Fault().mangleQuestion(reply)
+
+	s.sendReply(w, m, received, reply, nil)
+}
+
+// sendReply records the query and sends reply as is. If wire is not nil, it
+// is sent instead of packed reply, see RespondWithWire.
+func (s *Server) sendReply(w dns.ResponseWriter, m *dns.Msg, received time.Time, reply *dns.Msg, wire []byte) {
+	info := QueryInfo{
+		Query:      m,
+		Reply:      reply,
+		RemoteAddr: w.RemoteAddr(),
+		Time:       received,
+	}
+	if len(m.Question) != 0 {
+		info.Question = m.Question[0]
+	}
+
+	s.mu.Lock()
+	for _, q := range m.Question {
+		s.counts[queryKey{name: normalizeName(q.Name), qtype: q.Qtype}]++
+		s.statsCounts[statsKey{name: normalizeName(q.Name), qtype: q.Qtype, rcode: reply.Rcode}]++
+	}
+	logged := info
+	if s.recycling() {
+		logged.Reply = nil
+	}
+	s.queries = append(s.queries, logged)
+	callbacks := s.onQuery
+	expQueries, expRcodes := s.expQueries, s.expRcodes
+	s.mu.Unlock()
+
+	updateExpvar(expQueries, expRcodes, m, reply)
+	s.emitStage(StageLookup, m, received)
+
+	for _, f := range callbacks {
+		f(info)
+	}
+
+	behavior, _ := s.behaviorFor(w.RemoteAddr())
+	delay := behavior.Delay
+	if len(m.Question) != 0 {
+		_, r := s.resolverFor(w.RemoteAddr(), m)
+		delay += s.simulatedLatency(r, normalizeName(m.Question[0].Name))
+	}
+	if delay != 0 {
+		time.Sleep(delay)
+	}
+	if !behavior.Drop && s.Fault() != FaultTimeout {
+		s.sendOffPath(w, m, reply)
+		var err error
+		if wire == nil {
+			wire, err = s.Fault().craftReply(reply)
+		}
+		if err == nil && wire != nil {
+			err = s.writeWire(w, m, wire)
+		} else if err == nil {
+			err = s.writeMsg(w, m, reply)
+		}
+		if err != nil {
+			s.Log.Printf("WriteMsg: %v", err)
+		}
+	}
+
+	s.countTCPQuery(w)
+}
+
+func mkCname(name, cname string, ttl uint32) *dns.CNAME {
 	return &dns.CNAME{
 		Hdr: dns.RR_Header{
 			Name:   name,
 			Rrtype: dns.TypeCNAME,
 			Class:  dns.ClassINET,
-			Ttl:    9999,
+			Ttl:    ttl,
 		},
 		Target: cname,
 	}
 }
 
+func mkSOA(name string) *dns.SOA {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    9999,
+		},
+		Ns:      "localhost.",
+		Mbox:    "hostmaster.localhost.",
+		Serial:  1,
+		Refresh: 900,
+		Retry:   900,
+		Expire:  1800,
+		Minttl:  60,
+	}
+}
+
+func (s *Server) recursionAvailable() bool {
+	return !s.Authoritative && !s.NoRecursion
+}
+
+// negativeSOA returns the SOA record for the authority section of negative
+// responses for the specified name. Its TTL and MINIMUM fields are set
+// according to NegativeTTL options, if any.
+func (s *Server) negativeSOA(r *Resolver, name string) *dns.SOA {
+	soa := mkSOA(name)
+
+	ttl := s.NegativeTTL
+	if zone, ok := r.zone(name); ok && zone.NegativeTTL != 0 {
+		ttl = zone.NegativeTTL
+	}
+	if ttl != 0 {
+		soa.Hdr.Ttl = ttl
+		soa.Minttl = ttl
+	}
+
+	return soa
+}
+
 func splitTXT(s string) []string {
 	const maxLen = 255
 
@@ -137,233 +623,202 @@ func splitTXT(s string) []string {
 	return parts
 }
 
+func hasType(rrs []dns.RR, rrtype uint16) bool {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype {
+			return true
+		}
+	}
+	return false
+}
+
 // ServeDNS implements miekg/dns.Handler. It responds with values from underlying
 // Resolver object.
 func (s *Server) ServeDNS(w dns.ResponseWriter, m *dns.Msg) {
-	reply := new(dns.Msg)
+	start := time.Now()
+	s.parseDone(m)
+	defer s.trackUDPQuery(w.RemoteAddr())()
+	if s.scenario != nil {
+		s.scenario.onQuery()
+	}
+	pooled := s.newReply()
+	reply := &pooled.msg
+
+	span := s.startSpan(m)
+	defer func() {
+		s.recordLatency(time.Since(start))
+		span.SetAttribute(AttrResponseCode, dns.RcodeToString[reply.Rcode])
+		span.End(nil)
+		s.releaseReply(pooled)
+	}()
+
+	if err := s.checkQuery(w.RemoteAddr(), m); err != nil {
+		s.Log.Printf("query check failed: %v", err)
+		reply.SetRcode(m, dns.RcodeRefused)
+		s.writeReply(w, m, start, reply)
+		return
+	}
+
+	if msg, wire := s.injectedReply(m); msg != nil {
+		*reply = *msg
+		s.sendReply(w, m, start, reply, wire)
+		return
+	}
 
 	if m.MsgHdr.Opcode != dns.OpcodeQuery {
 		reply.SetRcode(m, dns.RcodeRefused)
-		if err := w.WriteMsg(reply); err != nil {
-			s.Log.Printf("WriteMsg: %v", err)
-		}
+		s.writeReply(w, m, start, reply)
 		return
 	}
 
 	reply.SetReply(m)
-	reply.RecursionAvailable = true
+	reply.RecursionAvailable = s.recursionAvailable()
 	if s.Authoritative {
 		reply.Authoritative = true
-		reply.RecursionAvailable = false
 	}
 
-	q := m.Question[0]
-
-	qname := strings.ToLower(dns.Fqdn(q.Name))
-
-	if q.Qclass != dns.ClassINET {
-		reply.SetRcode(m, dns.RcodeNotImplemented)
-		if err := w.WriteMsg(reply); err != nil {
-			s.Log.Printf("WriteMsg: %v", err)
-		}
+	switch f := s.Fault(); {
+	case f.rcode() != 0:
+		reply.SetRcode(m, f.rcode())
+		s.writeReply(w, m, start, reply)
+		return
+	case f == FaultLame:
+		reply.Authoritative = false
+		reply.RecursionAvailable = false
+		s.writeReply(w, m, start, reply)
 		return
 	}
 
-	qnameZone, ok := s.r.Zones[qname]
-	if !ok {
-		s.writeErr(w, reply, notFound(qname))
+	if behavior, ok := s.behaviorFor(w.RemoteAddr()); ok && behavior.Rcode != 0 {
+		reply.SetRcode(m, behavior.Rcode)
+		s.writeReply(w, m, start, reply)
 		return
 	}
 
-	// This does the lookup twice (including lookup* below).
-	// TODO: Avoid this.
-	ad, rname, _, err := s.r.targetZone(qname)
-	if err != nil {
-		s.writeErr(w, reply, err)
+	if s.RefuseRecursion && m.RecursionDesired {
+		reply.SetRcode(m, dns.RcodeRefused)
+		s.writeReply(w, m, start, reply)
 		return
 	}
-	reply.AuthenticatedData = ad
 
-	if rname != qname {
-		reply.Answer = append(reply.Answer, mkCname(qname, rname))
+	q := m.Question[0]
+
+	qname := normalizeName(q.Name)
+
+	if rcode := s.classRcode(q.Qclass); rcode != dns.RcodeSuccess {
+		reply.SetRcode(m, rcode)
+		s.writeReply(w, m, start, reply)
+		return
 	}
 
-	switch q.Qtype {
-	case dns.TypeA:
-		_, addrs, err := s.r.lookupA(context.Background(), qname)
-		if err != nil {
-			s.writeErr(w, reply, err)
+	if h := s.typeHandler(q.Qtype); h != nil {
+		if err := h(m, reply); err != nil {
+			s.writeErr(w, m, start, reply, err)
 			return
 		}
+		s.writeReply(w, m, start, reply)
+		return
+	}
 
-		for _, addr := range addrs {
-			parsed := net.ParseIP(addr)
-			if parsed == nil {
-				panic("ServeDNS: malformed IP in records")
-			}
-			reply.Answer = append(reply.Answer, &dns.A{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeA,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				A: parsed,
-			})
-		}
-	case dns.TypeAAAA:
-		_, addrs, err := s.r.lookupAAAA(context.Background(), q.Name)
-		if err != nil {
-			s.writeErr(w, reply, err)
-			return
+	if len(s.CaptivePortal) != 0 {
+		reply.Answer = s.portalRRs(q, s.CaptivePortal, captiveTTL)
+		if len(reply.Answer) == 0 {
+			reply.Ns = []dns.RR{mkSOA(qname)}
 		}
+		s.writeReply(w, m, start, reply)
+		return
+	}
 
-		for _, addr := range addrs {
-			parsed := net.ParseIP(addr)
-			if parsed == nil {
-				panic("ServeDNS: malformed IP in records")
-			}
-			reply.Answer = append(reply.Answer, &dns.AAAA{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeAAAA,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				AAAA: parsed,
-			})
-		}
-	case dns.TypeMX:
-		_, mxs, err := s.r.lookupMX(context.Background(), q.Name)
-		if err != nil {
-			s.writeErr(w, reply, err)
-			return
-		}
+	view, r := s.resolverFor(w.RemoteAddr(), m)
+	if s.Blocklist != nil && s.Blocklist.Contains(qname) {
+		s.sinkholeReply(reply, q, Zone{})
+		s.writeReply(w, m, start, reply)
+		return
+	}
+	if zone, ok := r.zone(qname); ok && zone.Sinkhole {
+		s.sinkholeReply(reply, q, zone)
+		s.attachEDE(reply, m, r, qname)
+		s.writeReply(w, m, start, reply)
+		return
+	}
+	set, err := s.answer(view, r, qname, q.Qtype)
+	if err != nil {
+		s.writeErr(w, m, start, reply, err)
+		return
+	}
+	reply.AuthenticatedData = set.ad
+	if s.recycling() {
+		// Recycled sections are reused, so they must not share arrays
+		// with the cached answer.
+		reply.Answer = append(reply.Answer, set.answer...)
+		reply.Ns = append(reply.Ns, set.ns...)
+		reply.Extra = append(reply.Extra, set.extra...)
+	} else {
+		reply.Answer = set.answer
+		reply.Ns = set.ns
+		reply.Extra = set.extra
+	}
+	s.attachEDE(reply, m, r, qname)
 
-		for _, mx := range mxs {
-			reply.Answer = append(reply.Answer, &dns.MX{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeMX,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Preference: mx.Pref,
-				Mx:         mx.Host,
-			})
+	if s.Templates {
+		client := ""
+		if ip := addrIP(w.RemoteAddr()); ip != nil {
+			client = ip.String()
 		}
-	case dns.TypeNS:
-		cname, nss, err := s.r.lookupNS(context.Background(), q.Name)
-		if err != nil {
-			s.writeErr(w, reply, err)
+		if err := s.expandTemplates(reply, q, client); err != nil {
+			s.writeErr(w, m, start, reply, err)
 			return
 		}
+	}
 
-		if cname != "" {
-			reply.Answer = append(reply.Answer, mkCname(q.Name, cname))
-		}
-		for _, ns := range nss {
-			reply.Answer = append(reply.Answer, &dns.NS{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeNS,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Ns: ns.Host,
-			})
-		}
-	case dns.TypeSRV:
-		_, srvs, err := s.r.lookupSRV(context.Background(), q.Name)
-		if err != nil {
-			s.writeErr(w, reply, err)
-			return
-		}
+	if s.SimulateCache {
+		s.simulateCache(reply)
+	}
 
-		for _, srv := range srvs {
-			reply.Answer = append(reply.Answer, &dns.SRV{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeSRV,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Priority: srv.Priority,
-				Port:     srv.Port,
-				Target:   srv.Target,
-			})
-		}
-	case dns.TypeCNAME:
-		reply.AuthenticatedData = qnameZone.AD
-	case dns.TypeTXT:
-		_, txts, err := s.r.lookupTXT(context.Background(), q.Name)
-		if err != nil {
-			s.writeErr(w, reply, err)
-			return
-		}
+	s.Log.Printf("DNS TRACE %v", reply.String())
 
-		for _, txt := range txts {
-			reply.Answer = append(reply.Answer, &dns.TXT{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypeTXT,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Txt: splitTXT(txt),
-			})
-		}
-	case dns.TypePTR:
-		rzone, ok := s.r.Zones[q.Name]
-		if !ok {
-			s.writeErr(w, reply, notFound(q.Name))
-			return
-		}
+	s.writeReply(w, m, start, reply)
+}
 
-		for _, name := range rzone.PTR {
-			reply.Answer = append(reply.Answer, &dns.PTR{
-				Hdr: dns.RR_Header{
-					Name:   rname,
-					Rrtype: dns.TypePTR,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Ptr: name,
-			})
-		}
-	case dns.TypeSOA:
-		reply.Answer = []dns.RR{
-			&dns.SOA{
-				Hdr: dns.RR_Header{
-					Name:   q.Name,
-					Rrtype: dns.TypeSOA,
-					Class:  dns.ClassINET,
-					Ttl:    9999,
-				},
-				Ns:      "localhost.",
-				Mbox:    "hostmaster.localhost.",
-				Serial:  1,
-				Refresh: 900,
-				Retry:   900,
-				Expire:  1800,
-				Minttl:  60,
-			},
-		}
-	default:
-		rzone, ok := s.r.Zones[q.Name]
-		if !ok {
-			s.writeErr(w, reply, notFound(q.Name))
-			return
-		}
+// OnQuery registers a callback that is called for every query served by the
+// Server.
+//
+// Callbacks are called synchronously right before the reply is sent to the
+// client, so the client does not see the reply until all callbacks return.
+// This makes it possible to reliably change server state (e.g. zones
+// contents) in response to a specific query.
+func (s *Server) OnQuery(f func(QueryInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onQuery = append(s.onQuery, f)
+}
 
-		reply.Answer = append(reply.Answer, rzone.Misc[dns.Type(q.Qtype)]...)
-	}
+// QueryCount returns the amount of queries for the specified name and type
+// served by the Server so far.
+func (s *Server) QueryCount(name string, qtype uint16) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[queryKey{name: normalizeName(name), qtype: qtype}]
+}
 
-	s.Log.Printf("DNS TRACE %v", reply.String())
+// ResetQueryCounts resets all counters returned by QueryCount to zero and
+// clears the log returned by Queries.
+func (s *Server) ResetQueryCounts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = make(map[queryKey]int)
+	s.statsCounts = make(map[statsKey]int)
+	s.queries = nil
+}
 
-	if err := w.WriteMsg(reply); err != nil {
-		s.Log.Printf("WriteMsg: %v", err)
-	}
+// Queries returns all queries served by the Server so far, in the order they
+// were answered.
+func (s *Server) Queries() []QueryInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]QueryInfo, len(s.queries))
+	copy(res, s.queries)
+	return res
 }
 
 // LocalAddr returns the local endpoint used by the server. It will always be
@@ -388,14 +843,24 @@ func (s *Server) PatchNet(r *net.Resolver) {
 			Timeout: 1 * time.Second,
 		}
 
+		var conn net.Conn
+		var err error
 		switch network {
 		case "udp", "udp4", "udp6":
-			return dialer.DialContext(ctx, "udp4", s.udpServ.PacketConn.LocalAddr().String())
+			if s.ResolvConf.UseVC {
+				conn, err = dialer.DialContext(ctx, "tcp4", s.tcpServ.Listener.Addr().String())
+				break
+			}
+			conn, err = dialer.DialContext(ctx, "udp4", s.udpServ.PacketConn.LocalAddr().String())
 		case "tcp", "tcp4", "tcp6":
-			return dialer.DialContext(ctx, "tcp4", s.tcpServ.Listener.Addr().String())
+			conn, err = dialer.DialContext(ctx, "tcp4", s.tcpServ.Listener.Addr().String())
 		default:
 			panic("PatchNet.Dial: unknown network")
 		}
+		if err != nil {
+			return nil, err
+		}
+		return s.resolvConn(r, conn), nil
 	}
 }
 
@@ -406,13 +871,29 @@ func UnpatchNet(r *net.Resolver) {
 
 // Resolver returns the underlying Resolver object that can be used directly
 // to access Zones content.
+//
+// Zones is the map passed to NewServer. It can be modified in place or
+// replaced with a new map, changes are seen by the following queries (a new
+// map also replaces zones set using AddZone, RemoveZone or SetZones).
+// Zones set using AddZone and RemoveZone are kept separately and are not
+// present in Zones, use ZoneSource().Zones() for the current zones. Modifying
+// Zones is not safe while queries are being served.
 func (s *Server) Resolver() *Resolver {
+	// Caches built from Zones would not see changes made in place.
+	s.r.src.invalidate()
 	return &s.r
 }
 
 func (s *Server) Close() error {
 	s.tcpServ.Shutdown()
 	s.udpServ.Shutdown()
+	s.closeTCPConns()
 	s.stopped = true
+	if s.scenario != nil {
+		s.scenario.stop()
+	}
+	if s.StatsPath != "" {
+		return s.WriteStats(s.StatsPath)
+	}
 	return nil
 }
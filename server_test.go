@@ -5,6 +5,8 @@ import (
 	"net"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/miekg/dns"
@@ -150,15 +152,14 @@ func TestServer_LookupTLSA(t *testing.T) {
 }
 
 func TestServer_Authoritative(t *testing.T) {
-	srv, err := NewServer(map[string]Zone{
+	srv := newTestServer(t, map[string]Zone{
 		"www.example.org.": {
 			CNAME: "foo.bar.com.",
 		},
-	}, true)
-	if err != nil {
-		t.Fatal(err)
-	}
-	srv.Resolver().SkipCNAME = true
+	}, func(s *Server) {
+		s.Authoritative = true
+		s.Resolver().SkipCNAME = true
+	})
 	defer srv.Close()
 
 	msg := new(dns.Msg)
@@ -175,3 +176,355 @@ func TestServer_Authoritative(t *testing.T) {
 		t.Fatal("The authoritative flag should be set")
 	}
 }
+
+func TestServer_NoRecursion(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}
+
+	exchange := func(configure func(s *Server), name string, rd bool) *dns.Msg {
+		t.Helper()
+		srv := newTestServer(t, zones, configure)
+		defer srv.Close()
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeA)
+		msg.RecursionDesired = rd
+		reply, err := dns.Exchange(msg, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		return reply
+	}
+
+	if reply := exchange(func(s *Server) {}, "example.org.", true); !reply.RecursionAvailable {
+		t.Fatal("RA flag should be set by default")
+	}
+
+	noRecursion := func(s *Server) {
+		s.NoRecursion = true
+	}
+	for _, name := range []string{"example.org.", "missing.example.org."} {
+		if reply := exchange(noRecursion, name, true); reply.RecursionAvailable {
+			t.Fatalf("RA flag is set with NoRecursion for %s", name)
+		}
+	}
+
+	refuseRecursion := func(s *Server) {
+		s.NoRecursion = true
+		s.RefuseRecursion = true
+	}
+	if reply := exchange(refuseRecursion, "example.org.", true); reply.Rcode != dns.RcodeRefused {
+		t.Fatal("Wrong rcode, want REFUSED, got", dns.RcodeToString[reply.Rcode])
+	}
+	if reply := exchange(refuseRecursion, "example.org.", false); len(reply.Answer) != 1 {
+		t.Fatal("Wrong amount of records in response:", len(reply.Answer))
+	}
+}
+
+func TestServer_OnQuery(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var (
+		infosLck sync.Mutex
+		infos    []QueryInfo
+	)
+	srv.OnQuery(func(info QueryInfo) {
+		infosLck.Lock()
+		defer infosLck.Unlock()
+		infos = append(infos, info)
+		srv.Resolver().Zones["example.org."] = Zone{
+			A: []string{"5.6.7.8"},
+		}
+	})
+
+	var r net.Resolver
+	srv.PatchNet(&r)
+
+	addrs, err := r.LookupIP(context.Background(), "ip4", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "1.2.3.4" {
+		t.Errorf("Wrong result of first lookup: %v", addrs)
+	}
+
+	addrs, err = r.LookupIP(context.Background(), "ip4", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "5.6.7.8" {
+		t.Errorf("Wrong result of second lookup: %v", addrs)
+	}
+
+	infosLck.Lock()
+	defer infosLck.Unlock()
+	if len(infos) != 2 {
+		t.Fatalf("Wrong amount of callback calls: %v", len(infos))
+	}
+	if infos[0].Question.Name != "example.org." || infos[0].Question.Qtype != dns.TypeA {
+		t.Errorf("Wrong question: %v", infos[0].Question)
+	}
+	if infos[0].Reply == nil || len(infos[0].Reply.Answer) != 1 {
+		t.Errorf("Wrong reply: %v", infos[0].Reply)
+	}
+}
+
+func TestServer_QueryCount(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A:  []string{"1.2.3.4"},
+			MX: []net.MX{{Host: "mx.example.org.", Pref: 10}},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var r net.Resolver
+	srv.PatchNet(&r)
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.LookupIP(context.Background(), "ip4", "example.org"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.LookupMX(context.Background(), "example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := srv.QueryCount("example.org.", dns.TypeA); c != 3 {
+		t.Errorf("Wrong A query count, want 3, got %v", c)
+	}
+	if c := srv.QueryCount("EXAMPLE.org", dns.TypeMX); c != 1 {
+		t.Errorf("Wrong MX query count, want 1, got %v", c)
+	}
+	if c := srv.QueryCount("example.org.", dns.TypeAAAA); c != 0 {
+		t.Errorf("Wrong AAAA query count, want 0, got %v", c)
+	}
+
+	srv.ResetQueryCounts()
+	if c := srv.QueryCount("example.org.", dns.TypeA); c != 0 {
+		t.Errorf("Wrong A query count after reset, want 0, got %v", c)
+	}
+}
+
+func TestServer_NXDOMAINvsNODATA(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+		"gone.example.org.": {
+			NXDOMAIN: true,
+			A:        []string{"1.2.3.4"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	cases := []struct {
+		name      string
+		qtype     uint16
+		wantRcode int
+		wantAns   int
+		wantSOA   bool
+	}{
+		{name: "example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeSuccess, wantAns: 1},
+		{name: "example.org.", qtype: dns.TypeAAAA, wantRcode: dns.RcodeSuccess, wantSOA: true},
+		{name: "example.org.", qtype: dns.TypeTLSA, wantRcode: dns.RcodeSuccess, wantSOA: true},
+		{name: "gone.example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeNameError, wantSOA: true},
+		{name: "missing.example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeNameError, wantSOA: true},
+	}
+	cl := dns.Client{}
+	for _, c := range cases {
+		msg := new(dns.Msg)
+		msg.SetQuestion(c.name, c.qtype)
+		reply, _, err := cl.Exchange(msg, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if reply.Rcode != c.wantRcode {
+			t.Errorf("%s %v: wrong rcode, want %v, got %v", c.name, dns.Type(c.qtype),
+				dns.RcodeToString[c.wantRcode], dns.RcodeToString[reply.Rcode])
+		}
+		if len(reply.Answer) != c.wantAns {
+			t.Errorf("%s %v: wrong amount of answers, want %v, got %v", c.name, dns.Type(c.qtype), c.wantAns, len(reply.Answer))
+		}
+		if hasSOA := len(reply.Ns) == 1 && reply.Ns[0].Header().Rrtype == dns.TypeSOA; hasSOA != c.wantSOA {
+			t.Errorf("%s %v: wrong authority section: %v", c.name, dns.Type(c.qtype), reply.Ns)
+		}
+	}
+
+	_, err = srv.Resolver().LookupHost(context.Background(), "gone.example.org.")
+	if dnsErr, ok := err.(*net.DNSError); !ok || !isNotFound(dnsErr) {
+		t.Errorf("Wrong error for NXDOMAIN zone: %v", err)
+	}
+}
+
+func TestServer_NegativeTTL(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {},
+		"example.net.": {
+			NegativeTTL: 5,
+		},
+	}
+	srv := newTestServer(t, zones, func(s *Server) {})
+	defer srv.Close()
+	withTTL := newTestServer(t, zones, func(s *Server) {
+		s.NegativeTTL = 10
+	})
+	defer withTTL.Close()
+
+	cl := dns.Client{}
+	negativeTTL := func(srv *Server, name string) uint32 {
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeA)
+		reply, _, err := cl.Exchange(msg, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if len(reply.Ns) != 1 {
+			t.Fatalf("Wrong authority section: %v", reply.Ns)
+		}
+		soa := reply.Ns[0].(*dns.SOA)
+		if soa.Hdr.Ttl < soa.Minttl {
+			return soa.Hdr.Ttl
+		}
+		return soa.Minttl
+	}
+
+	if ttl := negativeTTL(srv, "example.com."); ttl != 60 {
+		t.Errorf("Wrong default negative TTL: %v", ttl)
+	}
+
+	if ttl := negativeTTL(withTTL, "example.com."); ttl != 10 {
+		t.Errorf("Wrong server negative TTL for NXDOMAIN: %v", ttl)
+	}
+	if ttl := negativeTTL(withTTL, "example.org."); ttl != 10 {
+		t.Errorf("Wrong server negative TTL for NODATA: %v", ttl)
+	}
+	if ttl := negativeTTL(withTTL, "example.net."); ttl != 5 {
+		t.Errorf("Wrong zone negative TTL: %v", ttl)
+	}
+}
+
+func TestServer_PatchNet_LookupTXT(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	zones := map[string]Zone{
+		"example.org.": {
+			TXT: []string{"v=spf1 -all", long},
+			Misc: map[dns.Type][]dns.RR{
+				dns.Type(dns.TypeTXT): {
+					&dns.TXT{
+						Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 9999},
+						Txt: []string{"v=DKIM1; k=rsa; ", "p=AAAA"},
+					},
+				},
+			},
+		},
+	}
+	srv, err := NewServer(zones, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	want := []string{"v=spf1 -all", long, "v=DKIM1; k=rsa; p=AAAA"}
+
+	got, err := netR.LookupTXT(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong net.Resolver result, want %q, got %q", want, got)
+	}
+
+	got, err = (&Resolver{Zones: zones}).LookupTXT(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong Resolver result, want %q, got %q", want, got)
+	}
+}
+
+func TestServer_ScopedIPv6(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			AAAA: []string{"fe80::1%eth0", "2001:db8::1"},
+		},
+		"broken.example.org.": {
+			AAAA: []string{"fe80::1%"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var r net.Resolver
+	srv.PatchNet(&r)
+
+	// The zone identifier is stripped on the wire.
+	addrs, err := r.LookupIPAddr(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if addr.Zone != "" {
+			t.Fatalf("Zone identifier is not stripped: %v", addr)
+		}
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("Wrong amount of addresses, want %v, got %v", 2, len(addrs))
+	}
+
+	// But preserved by Resolver.
+	ipAddrs, err := srv.Resolver().LookupIPAddr(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipAddrs[0].Zone != "eth0" {
+		t.Fatalf("Wrong zone, want %v, got %v", "eth0", ipAddrs[0].Zone)
+	}
+
+	// Malformed addresses result in SERVFAIL instead of a panic.
+	m := new(dns.Msg)
+	m.SetQuestion("broken.example.org.", dns.TypeAAAA)
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Wrong rcode, want %v, got %v", dns.RcodeToString[dns.RcodeServerFailure], dns.RcodeToString[reply.Rcode])
+	}
+}
+
+// newTestServer starts the Server serving zones after configure is called,
+// so tests set options without racing with queries being served.
+func newTestServer(t *testing.T, zones map[string]Zone, configure func(s *Server)) *Server {
+	t.Helper()
+	srv, err := newServer(NewZoneSource(zones), defaultLogger(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(srv)
+	srv.serve()
+	return srv
+}
@@ -0,0 +1,63 @@
+package mockdns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// DefaultSinkholeTXT is the TXT record sent for sinkholed names if
+// Server.SinkholeTXT is empty.
+const DefaultSinkholeTXT = "sinkholed by mockdns"
+
+// defaultSinkholeAddrs are used if Server.SinkholeAddrs is empty.
+var defaultSinkholeAddrs = []string{"0.0.0.0", "::"}
+
+// Sinkhole marks names as sinkholed, adding zones with Zone.Sinkhole set or
+// setting it in existing ones. It is safe to call concurrently with queries
+// being served.
+func (s *Server) Sinkhole(names ...string) {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, normalizeName(name))
+	}
+	s.r.src.modify(normalized, func(_ string, zone Zone, _ bool) *Zone {
+		zone.Sinkhole = true
+		return &zone
+	})
+}
+
+// sinkholeReply fills the reply to the question for a sinkholed name. A and
+// AAAA queries are answered with SinkholeAddrs and TXT queries with
+// SinkholeTXT, which is also added to the additional section of address
+// answers so blocking can be told apart from real records.
+func (s *Server) sinkholeReply(reply *dns.Msg, q dns.Question, zone Zone) {
+	addrs := s.SinkholeAddrs
+	if len(addrs) == 0 {
+		addrs = defaultSinkholeAddrs
+	}
+	text := s.SinkholeTXT
+	if text == "" {
+		text = DefaultSinkholeTXT
+	}
+	marker := &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   q.Name,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    zone.ttl(),
+		},
+		Txt: splitTXT(text),
+	}
+
+	switch q.Qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		reply.Answer = s.portalRRs(q, addrs, zone.ttl())
+		if len(reply.Answer) != 0 {
+			reply.Extra = []dns.RR{marker}
+		}
+	case dns.TypeTXT:
+		reply.Answer = []dns.RR{marker}
+	}
+	if len(reply.Answer) == 0 {
+		reply.Ns = []dns.RR{mkSOA(q.Name)}
+	}
+}
@@ -0,0 +1,60 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_Sinkhole(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"ads.example.org.": {A: []string{"192.0.2.1"}},
+		"example.org.":     {A: []string{"192.0.2.2"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Sinkhole("ads.example.org", "tracker.example.net")
+
+	for _, name := range []string{"ads.example.org.", "tracker.example.net."} {
+		reply := queryFrom(t, srv, "127.0.0.1", name, dns.TypeA)
+		if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "0.0.0.0" {
+			t.Fatalf("%s: Wrong answer: %v", name, reply.Answer)
+		}
+		if len(reply.Extra) != 1 || reply.Extra[0].(*dns.TXT).Txt[0] != DefaultSinkholeTXT {
+			t.Fatalf("%s: Missing TXT marker: %v", name, reply.Extra)
+		}
+	}
+
+	reply := queryFrom(t, srv, "127.0.0.1", "ads.example.org.", dns.TypeAAAA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.AAAA).AAAA.String() != "::" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	reply = queryFrom(t, srv, "127.0.0.1", "ads.example.org.", dns.TypeTXT)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.TXT).Txt[0] != DefaultSinkholeTXT {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+
+	reply = queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "192.0.2.2" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+}
+
+func TestServer_SinkholeAddrs(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{}, func(s *Server) {
+		s.SinkholeAddrs = []string{"198.51.100.1"}
+	})
+	defer srv.Close()
+	srv.Sinkhole("ads.example.org")
+
+	reply := queryFrom(t, srv, "127.0.0.1", "ads.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "198.51.100.1" {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	reply = queryFrom(t, srv, "127.0.0.1", "ads.example.org.", dns.TypeAAAA)
+	if len(reply.Answer) != 0 || len(reply.Ns) != 1 {
+		t.Fatalf("Not a NODATA reply: %v", reply)
+	}
+}
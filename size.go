@@ -0,0 +1,61 @@
+package mockdns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// limitSize applies MaxUDPSize and MaxTCPSize to the packed reply. It
+// returns the packed reply to send or nil if it should be dropped.
+func (s *Server) limitSize(w dns.ResponseWriter, reply *dns.Msg, packed []byte) ([]byte, error) {
+	if _, isTCP := w.RemoteAddr().(*net.TCPAddr); isTCP {
+		if s.MaxTCPSize != 0 && len(packed) > s.MaxTCPSize {
+			s.Log.Printf("reply size %d exceeds MaxTCPSize, dropping", len(packed))
+			return nil, w.Close()
+		}
+		return packed, nil
+	}
+
+	if s.MaxUDPSize == 0 || len(packed) <= s.MaxUDPSize {
+		return packed, nil
+	}
+	reply.Truncate(s.MaxUDPSize)
+	return reply.Pack()
+}
+
+// limitWireSize is limitSize for replies sent as is, see RespondWithWire.
+// Oversized UDP replies are cut after the question section with the TC bit
+// set, since records of the packet may not be parseable.
+func (s *Server) limitWireSize(w dns.ResponseWriter, packet []byte) ([]byte, error) {
+	if _, isTCP := w.RemoteAddr().(*net.TCPAddr); isTCP {
+		if s.MaxTCPSize != 0 && len(packet) > s.MaxTCPSize {
+			s.Log.Printf("reply size %d exceeds MaxTCPSize, dropping", len(packet))
+			return nil, w.Close()
+		}
+		return packet, nil
+	}
+
+	if s.MaxUDPSize == 0 || len(packet) <= s.MaxUDPSize || len(packet) < dnsHeaderLen {
+		return packet, nil
+	}
+	qdcount := int(packet[4])<<8 | int(packet[5])
+	end := dnsHeaderLen
+	for i := 0; i < qdcount; i++ {
+		_, next, err := dns.UnpackDomainName(packet, end)
+		if err != nil || next+4 > len(packet) || next+4 > s.MaxUDPSize {
+			// Drop the question section as well.
+			end, qdcount = dnsHeaderLen, 0
+			break
+		}
+		end = next + 4
+	}
+	packet = packet[:end]
+	packet[2] |= 0x02 // TC
+	packet[4], packet[5] = byte(qdcount>>8), byte(qdcount)
+	for i := 6; i < dnsHeaderLen; i++ {
+		// ANCOUNT, NSCOUNT and ARCOUNT.
+		packet[i] = 0
+	}
+	return packet, nil
+}
@@ -0,0 +1,56 @@
+package mockdns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_MaxSize(t *testing.T) {
+	var addrs []string
+	for i := 1; i <= 100; i++ {
+		addrs = append(addrs, fmt.Sprintf("192.0.2.%d", i))
+	}
+	zones := map[string]Zone{
+		"example.org.": {A: addrs},
+	}
+	srv := newTestServer(t, zones, func(s *Server) {
+		s.MaxUDPSize = 512
+		s.MaxTCPSize = 1000
+	})
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+
+	reply, err := dns.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Truncated {
+		t.Fatal("TC flag is not set")
+	}
+	reply.Compress = true
+	if reply.Len() > 512 || len(reply.Answer) == 100 {
+		t.Fatalf("Reply is too large: %d", reply.Len())
+	}
+
+	cl := dns.Client{Net: "tcp"}
+	if _, _, err := cl.Exchange(m, srv.LocalAddr().String()); err == nil {
+		t.Fatal("Oversized TCP reply is not dropped")
+	}
+
+	unlimited := newTestServer(t, zones, func(s *Server) {
+		s.MaxUDPSize = 512
+	})
+	defer unlimited.Close()
+	reply, _, err = cl.Exchange(m, unlimited.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Answer) != 100 {
+		t.Fatalf("Wrong amount of records, want %v, got %v", 100, len(reply.Answer))
+	}
+}
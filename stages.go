@@ -0,0 +1,154 @@
+package mockdns
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Stage is the phase of query handling by Server. See OnStage.
+type Stage int
+
+const (
+	// StageParse is the unpacking of the received query.
+	StageParse Stage = iota
+	// StageLookup is everything done to build the reply: query checks and
+	// zone lookups.
+	StageLookup
+	// StageEncode is the packing of the reply.
+	StageEncode
+	// StageWrite is the sending of the packed reply to the client.
+	StageWrite
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageParse:
+		return "parse"
+	case StageLookup:
+		return "lookup"
+	case StageEncode:
+		return "encode"
+	case StageWrite:
+		return "write"
+	}
+	return "unknown"
+}
+
+// StageInfo describes a completed stage of query handling.
+type StageInfo struct {
+	Stage    Stage
+	Query    *dns.Msg
+	Start    time.Time
+	Duration time.Duration
+}
+
+// OnStage registers a callback that is called synchronously at the end of
+// each stage of query handling. It can be used to attribute time spent
+// inside Server (e.g. to exclude it from client benchmarks) or to derive
+// custom metrics.
+//
+// StageParse is measured using the query ID, so its duration may be
+// inaccurate if multiple queries with the same ID are received at once.
+func (s *Server) OnStage(f func(StageInfo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStage = append(s.onStage, f)
+	atomic.StoreInt32(&s.hasStageHooks, 1)
+}
+
+func (s *Server) emitStage(stage Stage, query *dns.Msg, start time.Time) {
+	if atomic.LoadInt32(&s.hasStageHooks) == 0 {
+		return
+	}
+
+	info := StageInfo{
+		Stage:    stage,
+		Query:    query,
+		Start:    start,
+		Duration: time.Since(start),
+	}
+
+	s.mu.Lock()
+	callbacks := s.onStage
+	s.mu.Unlock()
+
+	for _, f := range callbacks {
+		f(info)
+	}
+}
+
+// maxParseTime is the time after which recorded parse starts are discarded,
+// e.g. for queries miekg/dns failed to unpack, that never reach ServeDNS.
+const maxParseTime = time.Second
+
+// acceptMsg is used as MsgAcceptFunc to record the time the query parsing
+// starts at.
+func (s *Server) acceptMsg(dh dns.Header) dns.MsgAcceptAction {
+	action := dns.DefaultMsgAcceptFunc(dh)
+	if action != dns.MsgAccept || atomic.LoadInt32(&s.hasStageHooks) == 0 {
+		return action
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if s.parseStart == nil {
+		s.parseStart = make(map[uint16][]time.Time)
+	}
+	if now.Sub(s.parseSwept) > maxParseTime {
+		for id, starts := range s.parseStart {
+			if starts = freshParseStarts(starts, now); len(starts) == 0 {
+				delete(s.parseStart, id)
+			} else {
+				s.parseStart[id] = starts
+			}
+		}
+		s.parseSwept = now
+	}
+	// Queries with the same ID may be parsed concurrently, starts are
+	// matched in order of acceptance.
+	s.parseStart[dh.Id] = append(s.parseStart[dh.Id], now)
+	s.mu.Unlock()
+
+	return action
+}
+
+// freshParseStarts returns starts without ones older than maxParseTime.
+func freshParseStarts(starts []time.Time, now time.Time) []time.Time {
+	for len(starts) != 0 && now.Sub(starts[0]) > maxParseTime {
+		starts = starts[1:]
+	}
+	return starts
+}
+
+// takeParseStart removes the oldest parse start recorded for the ID and
+// returns it, if any.
+func (s *Server) takeParseStart(id uint16) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	starts := freshParseStarts(s.parseStart[id], time.Now())
+	if len(starts) == 0 {
+		delete(s.parseStart, id)
+		return time.Time{}, false
+	}
+	start := starts[0]
+	if len(starts) == 1 {
+		delete(s.parseStart, id)
+	} else {
+		s.parseStart[id] = starts[1:]
+	}
+	return start, true
+}
+
+// parseDone emits StageParse event for the query, if its start was recorded.
+func (s *Server) parseDone(m *dns.Msg) {
+	if atomic.LoadInt32(&s.hasStageHooks) == 0 {
+		return
+	}
+
+	if start, ok := s.takeParseStart(m.Id); ok {
+		s.emitStage(StageParse, m, start)
+	}
+}
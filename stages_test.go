@@ -0,0 +1,112 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_OnStage(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var (
+		lck    sync.Mutex
+		stages = make(map[Stage]int)
+	)
+	srv.OnStage(func(info StageInfo) {
+		lck.Lock()
+		defer lck.Unlock()
+		if info.Duration < 0 || info.Query == nil || info.Start.IsZero() {
+			t.Errorf("Malformed StageInfo: %+v", info)
+		}
+		stages[info.Stage]++
+	})
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+	if _, err := netR.LookupIP(context.Background(), "ip4", "example.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	// StageWrite event is emitted after the reply is sent, so it can be
+	// delivered after the lookup returns.
+	for i := 0; ; i++ {
+		lck.Lock()
+		done := stages[StageWrite] != 0
+		lck.Unlock()
+		if done || i == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lck.Lock()
+	defer lck.Unlock()
+	for _, stage := range []Stage{StageParse, StageLookup, StageEncode, StageWrite} {
+		if stages[stage] != 1 {
+			t.Errorf("Wrong amount of %v events, want %v, got %v", stage, 1, stages[stage])
+		}
+	}
+}
+
+func TestServer_OnStage_ParseStarts(t *testing.T) {
+	var (
+		lck    sync.Mutex
+		parsed int
+	)
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.OnStage(func(info StageInfo) {
+			lck.Lock()
+			defer lck.Unlock()
+			if info.Stage == StageParse {
+				parsed++
+			}
+		})
+	})
+	defer srv.Close()
+
+	// Start of a query that failed to unpack.
+	srv.mu.Lock()
+	srv.parseStart = map[uint16][]time.Time{1: {time.Now().Add(-2 * maxParseTime)}}
+	srv.mu.Unlock()
+
+	// Queries with the same ID all get StageParse events.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion("example.org.", dns.TypeA)
+			m.Id = 2
+			if _, err := dns.Exchange(m, srv.LocalAddr().String()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lck.Lock()
+	if parsed != 5 {
+		t.Errorf("Wrong result, want %v, got %v", 5, parsed)
+	}
+	lck.Unlock()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.parseStart) != 0 {
+		t.Fatalf("Wrong result, want %v, got %v", map[uint16][]time.Time{}, srv.parseStart)
+	}
+}
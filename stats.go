@@ -0,0 +1,102 @@
+package mockdns
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// statsKey identifies a group of queries in Stats.
+type statsKey struct {
+	name  string
+	qtype uint16
+	rcode int
+}
+
+// Stats is the summary of queries served by Server, see Server.Stats.
+type Stats struct {
+	Queries []StatsEntry `json:"queries"`
+
+	// Latency contains the percentiles of time spent handling queries, see
+	// LatencyPercentiles.
+	Latency StatsLatency `json:"latency"`
+
+	// UnusedZones lists names in zones that were never queried directly.
+	// Zones can still be used as CNAME targets.
+	UnusedZones []string `json:"unused_zones"`
+}
+
+// StatsEntry is the amount of queries for the name and type answered with
+// the response code.
+type StatsEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Rcode string `json:"rcode"`
+	Count int    `json:"count"`
+}
+
+// StatsLatency contains latency percentiles in nanoseconds.
+type StatsLatency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+}
+
+// Stats returns the summary of queries served by the Server so far (or since
+// the last ResetQueryCounts call). Queries are sorted by name, type and
+// response code.
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	entries := make([]StatsEntry, 0, len(s.statsCounts))
+	for key, count := range s.statsCounts {
+		entries = append(entries, StatsEntry{
+			Name:  key.name,
+			Type:  dns.Type(key.qtype).String(),
+			Rcode: dns.RcodeToString[key.rcode],
+			Count: count,
+		})
+	}
+	queried := make(map[string]bool, len(s.counts))
+	for key := range s.counts {
+		queried[key.name] = true
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Rcode < b.Rcode
+	})
+
+	unused := []string{}
+	s.r.rangeZoneNames(func(name string) {
+		if !queried[normalizeName(name)] {
+			unused = append(unused, name)
+		}
+	})
+	sort.Strings(unused)
+
+	ps := s.LatencyPercentiles(50, 90, 99)
+	return Stats{
+		Queries:     entries,
+		Latency:     StatsLatency{P50: ps[0], P90: ps[1], P99: ps[2]},
+		UnusedZones: unused,
+	}
+}
+
+// WriteStats writes Stats to the file at path as JSON.
+func (s *Server) WriteStats(path string) error {
+	blob, err := json.MarshalIndent(s.Stats(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(blob, '\n'), 0644)
+}
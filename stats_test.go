@@ -0,0 +1,68 @@
+package mockdns
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestServer_Stats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockdns-stats-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "stats.json")
+
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+		"unused.example.org.": {
+			A: []string{"192.0.2.2"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.StatsPath = path
+
+	var r net.Resolver
+	srv.PatchNet(&r)
+	r.LookupIPAddr(context.Background(), "example.org")
+	r.LookupIPAddr(context.Background(), "missing.example.org")
+
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	if err := json.Unmarshal(blob, &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []StatsEntry{
+		{Name: "example.org.", Type: "A", Rcode: "NOERROR", Count: 1},
+		{Name: "example.org.", Type: "AAAA", Rcode: "NOERROR", Count: 1},
+		{Name: "missing.example.org.", Type: "A", Rcode: "NXDOMAIN", Count: 1},
+		{Name: "missing.example.org.", Type: "AAAA", Rcode: "NXDOMAIN", Count: 1},
+	}
+	if !reflect.DeepEqual(stats.Queries, want) {
+		t.Errorf("Wrong queries, want %v, got %v", want, stats.Queries)
+	}
+	if want := []string{"unused.example.org."}; !reflect.DeepEqual(stats.UnusedZones, want) {
+		t.Errorf("Wrong unused zones, want %v, got %v", want, stats.UnusedZones)
+	}
+	if stats.Latency.P99 == 0 {
+		t.Error("Latency is not recorded")
+	}
+}
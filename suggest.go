@@ -0,0 +1,90 @@
+package mockdns
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the amount of names reported by Server on a miss if
+// SuggestZones is set.
+const maxSuggestions = 3
+
+// ClosestZones returns up to n names from Zones that are closest to name by
+// edit distance. Only names that look like typos (e.g. missing trailing
+// dot, different TLD) are returned, so the result is empty if nothing is
+// similar enough.
+//
+// It is intended to be used in failure messages of tests.
+func (r *Resolver) ClosestZones(name string, n int) []string {
+	name = strings.ToLower(name)
+
+	// Allow roughly one typo per 4 characters, but at least 2 edits so short
+	// names still get suggestions.
+	maxDist := len(name) / 4
+	if maxDist < 2 {
+		maxDist = 2
+	}
+
+	type candidate struct {
+		name string
+		dist int
+	}
+	var candidates []candidate
+	r.rangeZoneNames(func(zoneName string) {
+		if zoneName == name {
+			return
+		}
+		if d := editDistance(name, strings.ToLower(zoneName)); d <= maxDist {
+			candidates = append(candidates, candidate{name: zoneName, dist: d})
+		}
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	res := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		res = append(res, c.name)
+	}
+	return res
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
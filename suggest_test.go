@@ -0,0 +1,73 @@
+package mockdns
+
+import (
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"example.org.", "example.org.", 0},
+		{"example.org", "example.org.", 1},
+		{"example.net.", "example.org.", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.want {
+			t.Errorf("editDistance(%q, %q): want %v, got %v", c.a, c.b, c.want, got)
+		}
+	}
+}
+
+func TestResolver_ClosestZones(t *testing.T) {
+	r := Resolver{Zones: map[string]Zone{
+		"example.org.":     {},
+		"www.example.org.": {},
+		"example.com.":     {},
+		"unrelated.test.":  {},
+	}}
+
+	got := r.ClosestZones("example.net.", 3)
+	want := []string{"example.com.", "example.org."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, got)
+	}
+
+	got = r.ClosestZones("Example.org", 1)
+	want = []string{"example.org."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result, want %v, got %v", want, got)
+	}
+
+	if got := r.ClosestZones("something.else.", 3); len(got) != 0 {
+		t.Errorf("Unexpected suggestions: %v", got)
+	}
+}
+
+func TestServer_SuggestZones(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}
+	for _, suggest := range []bool{false, true} {
+		var buf lockedBuffer
+		srv := newTestServer(t, zones, func(s *Server) {
+			s.Log = log.New(&buf, "", 0)
+			s.SuggestZones = suggest
+		})
+		defer srv.Close()
+
+		queryFrom(t, srv, "127.0.0.1", "example.orf.", dns.TypeA)
+		logged := strings.Contains(buf.String(), "did you mean: example.org.?")
+		if logged != suggest {
+			t.Fatalf("Wrong result, want %v, got %v (log: %q)", suggest, logged, buf.String())
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package mockdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tcpListener enforces Server.MaxTCPConns and tracks accepted connections.
+type tcpListener struct {
+	net.Listener
+	s *Server
+}
+
+func (l *tcpListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		c := &tcpConn{Conn: conn, s: l.s}
+		if l.s.acquireTCPConn(c) {
+			return c, nil
+		}
+
+		l.s.Log.Printf("TCP connection from %v rejected: too many connections", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+type tcpConn struct {
+	net.Conn
+	s    *Server
+	once sync.Once
+
+	// queries is the amount of queries served over the connection, guarded
+	// by Server.mu.
+	queries int
+
+	// wmu serializes writes of pipelined replies.
+	wmu sync.Mutex
+}
+
+// Write writes b according to Server.TCPWriteSize and TCPWriteDelay, or
+// writes its part and closes the connection for FaultTCPClose and
+// FaultTCPReset.
+func (c *tcpConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	switch f := c.s.Fault(); f {
+	case FaultTCPClose, FaultTCPReset:
+		n, err := c.write(b[:len(b)/2])
+		if tcp, ok := c.Conn.(*net.TCPConn); ok && f == FaultTCPReset {
+			tcp.SetLinger(0)
+		}
+		c.Close()
+		if err == nil {
+			err = errors.New("connection closed mid-reply by fault")
+		}
+		return n, err
+	}
+	return c.write(b)
+}
+
+func (c *tcpConn) write(b []byte) (int, error) {
+	size := c.s.TCPWriteSize
+	if size <= 0 {
+		return c.Conn.Write(b)
+	}
+
+	written := 0
+	for written < len(b) {
+		if written != 0 && c.s.TCPWriteDelay != 0 {
+			time.Sleep(c.s.TCPWriteDelay)
+		}
+		end := written + size
+		if end > len(b) {
+			end = len(b)
+		}
+		n, err := c.Conn.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *tcpConn) Close() error {
+	c.once.Do(func() {
+		c.s.releaseTCPConn(c.RemoteAddr())
+	})
+	return c.Conn.Close()
+}
+
+func (s *Server) acquireTCPConn(c *tcpConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.MaxTCPConns > 0 && len(s.tcpConns) >= s.MaxTCPConns {
+		return false
+	}
+	s.tcpConns[c.RemoteAddr().String()] = c
+	if len(s.tcpConns) > s.peakTCPConns {
+		s.peakTCPConns = len(s.tcpConns)
+	}
+	return true
+}
+
+func (s *Server) releaseTCPConn(addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tcpConns, addr.String())
+}
+
+// countTCPQuery closes the TCP connection the reply was sent over once
+// MaxTCPQueries is reached.
+func (s *Server) countTCPQuery(w dns.ResponseWriter) {
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	c, ok := s.tcpConns[w.RemoteAddr().String()]
+	if !ok {
+		// Already closed, e.g. by FaultTCPClose.
+		s.mu.Unlock()
+		return
+	}
+	c.queries++
+	exceeded := s.MaxTCPQueries > 0 && c.queries >= s.MaxTCPQueries
+	s.mu.Unlock()
+
+	if exceeded {
+		w.Close()
+	}
+}
+
+// TCPConns returns the amount of currently open TCP connections.
+func (s *Server) TCPConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tcpConns)
+}
+
+// closeTCPConns closes connections not tracked by the miekg/dns server
+// after being hijacked for pipelining.
+func (s *Server) closeTCPConns() {
+	s.mu.Lock()
+	conns := make([]*tcpConn, 0, len(s.tcpConns))
+	for _, c := range s.tcpConns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// acquireTCPWorker waits for a free worker if TCPWorkers is set and returns
+// the function releasing it.
+func (s *Server) acquireTCPWorker() func() {
+	s.workersOnce.Do(func() {
+		if s.TCPWorkers > 0 {
+			s.tcpWorkers = make(chan struct{}, s.TCPWorkers)
+		}
+	})
+	if s.tcpWorkers == nil {
+		return func() {}
+	}
+	s.tcpWorkers <- struct{}{}
+	return func() { <-s.tcpWorkers }
+}
+
+// serveTCP handles queries received over TCP by the miekg/dns server. With
+// TCPPipeline, the connection is taken over after the first query, since
+// miekg/dns reads the next query only after the reply to the previous
+// one is sent.
+func (s *Server) serveTCP(w dns.ResponseWriter, m *dns.Msg) {
+	release := s.acquireTCPWorker()
+	s.ServeDNS(w, m)
+	release()
+
+	if s.TCPPipeline <= 1 {
+		return
+	}
+	s.mu.Lock()
+	c, ok := s.tcpConns[w.RemoteAddr().String()]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	w.Hijack()
+	go s.servePipelined(c)
+}
+
+// pipelineIdleTimeout is the time pipelined connections are kept open
+// without queries, same as for connections served by miekg/dns.
+const pipelineIdleTimeout = 8 * time.Second
+
+// servePipelined reads queries from c and handles up to TCPPipeline of them
+// concurrently.
+func (s *Server) servePipelined(c *tcpConn) {
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	inFlight := make(chan struct{}, s.TCPPipeline)
+	w := pipelinedWriter{c: c}
+
+	for {
+		c.SetReadDeadline(time.Now().Add(pipelineIdleTimeout))
+		var length uint16
+		if err := binary.Read(c, binary.BigEndian, &length); err != nil {
+			return
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(c, b); err != nil {
+			return
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			s.servePipelinedMsg(w, b)
+		}()
+	}
+}
+
+// servePipelinedMsg handles the query as miekg/dns would, including
+// Server.acceptMsg checks.
+func (s *Server) servePipelinedMsg(w pipelinedWriter, b []byte) {
+	if len(b) < 12 {
+		return
+	}
+	dh := dns.Header{
+		Id:      binary.BigEndian.Uint16(b[0:]),
+		Bits:    binary.BigEndian.Uint16(b[2:]),
+		Qdcount: binary.BigEndian.Uint16(b[4:]),
+		Ancount: binary.BigEndian.Uint16(b[6:]),
+		Nscount: binary.BigEndian.Uint16(b[8:]),
+		Arcount: binary.BigEndian.Uint16(b[10:]),
+	}
+
+	m := new(dns.Msg)
+	action := s.acceptMsg(dh)
+	if action == dns.MsgAccept {
+		if err := m.Unpack(b); err != nil {
+			// The query will not reach ServeDNS.
+			s.takeParseStart(dh.Id)
+			action = dns.MsgReject
+		}
+	}
+	switch action {
+	case dns.MsgAccept:
+	case dns.MsgReject, dns.MsgRejectNotImplemented:
+		m.Id = dh.Id
+		m.Opcode = int(dh.Bits>>11) & 0xF
+		m.SetRcodeFormatError(m)
+		if action == dns.MsgRejectNotImplemented {
+			m.Rcode = dns.RcodeNotImplemented
+		}
+		m.Question, m.Answer, m.Ns, m.Extra = nil, nil, nil, nil
+		w.WriteMsg(m)
+		return
+	default:
+		return
+	}
+
+	release := s.acquireTCPWorker()
+	defer release()
+	s.ServeDNS(w, m)
+}
+
+// pipelinedWriter is the dns.ResponseWriter for connections served by
+// servePipelined.
+type pipelinedWriter struct {
+	c *tcpConn
+}
+
+func (w pipelinedWriter) LocalAddr() net.Addr  { return w.c.LocalAddr() }
+func (w pipelinedWriter) RemoteAddr() net.Addr { return w.c.RemoteAddr() }
+
+func (w pipelinedWriter) WriteMsg(m *dns.Msg) error {
+	b, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (w pipelinedWriter) Write(b []byte) (int, error) {
+	if len(b) > dns.MaxMsgSize {
+		return 0, errors.New("message too large")
+	}
+	msg := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(msg, uint16(len(b)))
+	copy(msg[2:], b)
+	n, err := w.c.Write(msg)
+	if n > 2 {
+		n -= 2
+	} else {
+		n = 0
+	}
+	return n, err
+}
+
+func (w pipelinedWriter) Close() error        { return w.c.Close() }
+func (w pipelinedWriter) TsigStatus() error   { return nil }
+func (w pipelinedWriter) TsigTimersOnly(bool) {}
+func (w pipelinedWriter) Hijack()             {}
@@ -0,0 +1,200 @@
+package mockdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func exchangeConn(conn *dns.Conn, name string) error {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if err := conn.WriteMsg(m); err != nil {
+		return err
+	}
+	_, err := conn.ReadMsg()
+	return err
+}
+
+func TestServer_MaxTCPConns(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.MaxTCPConns = 1
+	})
+	defer srv.Close()
+
+	first, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	if err := exchangeConn(first, "example.org."); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.TCPConns(); n != 1 {
+		t.Fatalf("Wrong TCPConns, want %v, got %v", 1, n)
+	}
+
+	second, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if err := exchangeConn(second, "example.org."); err == nil {
+		t.Fatal("Connection above the limit is served")
+	}
+
+	first.Close()
+	for i := 0; srv.TCPConns() != 0; i++ {
+		if i == 100 {
+			t.Fatal("Closed connection is not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	third, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	if err := exchangeConn(third, "example.org."); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_MaxTCPQueries(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.MaxTCPQueries = 2
+	})
+	defer srv.Close()
+
+	conn, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := exchangeConn(conn, "example.org."); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exchangeConn(conn, "example.org."); err == nil {
+		t.Fatal("Query above the limit is served")
+	}
+}
+
+func TestServer_TCPWriteThrottling(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(s *Server) {
+		s.TCPWriteSize = 10
+		s.TCPWriteDelay = 20 * time.Millisecond
+	})
+	defer srv.Close()
+
+	conn, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The reply is 47 bytes with the length prefix, so it is written in 5
+	// chunks.
+	start := time.Now()
+	if err := exchangeConn(conn, "example.org."); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("Reply is not throttled: %v", d)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	conn.SetDeadline(time.Now().Add(30 * time.Millisecond))
+	if err := conn.WriteMsg(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ReadMsg(); err == nil {
+		t.Fatal("Read deadline is not exceeded")
+	}
+}
+
+func TestServer_TCPPipeline(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"slow.example.org.": {A: []string{"192.0.2.1"}, Latency: FixedLatency(200 * time.Millisecond)},
+		"fast.example.org.": {A: []string{"192.0.2.2"}},
+	}, func(s *Server) {
+		s.TCPPipeline = 2
+	})
+	defer srv.Close()
+
+	conn, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	// The first query is served before the connection is taken over.
+	if err := exchangeConn(conn, "fast.example.org."); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"slow.example.org.", "fast.example.org."} {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if err := conn.WriteMsg(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"fast.example.org.", "slow.example.org."} {
+		reply, err := conn.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reply.Question[0].Name; got != want {
+			t.Fatalf("Wrong result, want %v, got %v", want, got)
+		}
+	}
+}
+
+func TestServer_TCPWorkers(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"slow.example.org.": {A: []string{"192.0.2.1"}, Latency: FixedLatency(200 * time.Millisecond)},
+		"fast.example.org.": {A: []string{"192.0.2.2"}},
+	}, func(s *Server) {
+		s.TCPWorkers = 1
+	})
+	defer srv.Close()
+
+	slow, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	fast, err := dns.Dial("tcp", srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+
+	start := time.Now()
+	m := new(dns.Msg)
+	m.SetQuestion("slow.example.org.", dns.TypeA)
+	if err := slow.WriteMsg(m); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := exchangeConn(fast, "fast.example.org."); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("Query is served without a free worker: %v", d)
+	}
+}
@@ -0,0 +1,91 @@
+package mockdns
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/miekg/dns"
+)
+
+// TemplateData is the data available to record templates, see
+// Server.Templates.
+type TemplateData struct {
+	// QName is the queried name, as sent by the client.
+	QName string
+	// QType is the queried type mnemonic, e.g. "TXT".
+	QType string
+	// Client is the IP address of the client.
+	Client string
+	// ID is the message ID of the query.
+	ID uint16
+	// Counter is incremented for each reply containing templates,
+	// starting from 1.
+	Counter uint64
+}
+
+// templateRR returns rr with text/template placeholders in its data
+// expanded. It returns rr itself if it contains no placeholders.
+func (s *Server) templateRR(rr dns.RR, data *TemplateData) (dns.RR, error) {
+	text := rr.String()
+	if !strings.Contains(text, "{{") {
+		return rr, nil
+	}
+
+	var tmpl *template.Template
+	if cached, ok := s.templates.Load(text); ok {
+		tmpl = cached.(*template.Template)
+	} else {
+		var err error
+		tmpl, err = template.New("rr").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template: %v", err)
+		}
+		s.templates.Store(text, tmpl)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("template: %v", err)
+	}
+	expanded, err := dns.NewRR(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("template: %s: %v", b.String(), err)
+	}
+	return expanded, nil
+}
+
+// expandTemplates replaces records with placeholders in the reply answer
+// and additional sections with expanded copies.
+func (s *Server) expandTemplates(reply *dns.Msg, q dns.Question, client string) error {
+	data := TemplateData{
+		QName:  q.Name,
+		QType:  dns.TypeToString[q.Qtype],
+		Client: client,
+		ID:     reply.Id,
+	}
+	counted := false
+
+	for _, section := range []*[]dns.RR{&reply.Answer, &reply.Extra} {
+		w := sectionWriter{section: section}
+		for i, rr := range *section {
+			if !strings.Contains(rr.String(), "{{") {
+				continue
+			}
+			if !counted {
+				s.mu.Lock()
+				s.templateCounter++
+				data.Counter = s.templateCounter
+				s.mu.Unlock()
+				counted = true
+			}
+
+			expanded, err := s.templateRR(rr, &data)
+			if err != nil {
+				return err
+			}
+			w.set(i, expanded)
+		}
+	}
+	return nil
+}
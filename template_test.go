@@ -0,0 +1,50 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_Templates(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"echo.example.org.":  {TXT: []string{"{{ .QName }} {{ .QType }} from {{ .Client }}"}},
+		"token.example.org.": {TXT: []string{"token-{{ .Counter }}"}},
+		"bad.example.org.":   {TXT: []string{"{{ .Missing }}"}},
+		"plain.example.org.": {TXT: []string{"plain"}},
+	}, func(s *Server) {
+		s.Templates = true
+	})
+	defer srv.Close()
+
+	txt := func(reply *dns.Msg) string {
+		t.Helper()
+		if len(reply.Answer) != 1 {
+			t.Fatalf("Wrong answer: %v", reply)
+		}
+		return reply.Answer[0].(*dns.TXT).Txt[0]
+	}
+
+	reply := queryFrom(t, srv, "127.0.0.1", "Echo.Example.ORG.", dns.TypeTXT)
+	if got, want := txt(reply), "Echo.Example.ORG. TXT from 127.0.0.1"; got != want {
+		t.Errorf("Wrong result, want %v, got %v", want, got)
+	}
+
+	first := txt(queryFrom(t, srv, "127.0.0.1", "token.example.org.", dns.TypeTXT))
+	second := txt(queryFrom(t, srv, "127.0.0.1", "token.example.org.", dns.TypeTXT))
+	if first == second {
+		t.Errorf("Counter is not incremented: %v, %v", first, second)
+	}
+	if second != "token-3" {
+		t.Errorf("Wrong result, want %v, got %v", "token-3", second)
+	}
+
+	if got := txt(queryFrom(t, srv, "127.0.0.1", "plain.example.org.", dns.TypeTXT)); got != "plain" {
+		t.Errorf("Wrong result, want %v, got %v", "plain", got)
+	}
+
+	reply = queryFrom(t, srv, "127.0.0.1", "bad.example.org.", dns.TypeTXT)
+	if reply.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Wrong rcode, want %v, got %v", dns.RcodeServerFailure, reply.Rcode)
+	}
+}
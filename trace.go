@@ -0,0 +1,123 @@
+package mockdns
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// Tracer creates spans around Resolver lookups and queries handled by
+// Server.
+//
+// The interface is intentionally minimal so it can be implemented on top of
+// OpenTelemetry (or any other tracing library) without mockdns depending on
+// it. An OpenTelemetry implementation would call trace.Tracer.Start and wrap
+// the resulting trace.Span.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation created by Tracer.
+type Span interface {
+	SetAttribute(key, value string)
+
+	// End completes the span. err is the error returned by the traced
+	// operation, if any.
+	End(err error)
+}
+
+// Attribute keys set on spans.
+const (
+	AttrQuestionName  = "dns.question.name"
+	AttrQuestionType  = "dns.question.type"
+	AttrQuestionClass = "dns.question.class"
+	AttrResponseCode  = "dns.response.code"
+)
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key, value string) {}
+func (nopSpan) End(err error)                  {}
+
+// startSpan starts the span for Resolver lookup method. qtype can be zero for
+// lookups that are not limited to a single record type.
+func (r *Resolver) startSpan(ctx context.Context, method, name string, qtype uint16) (context.Context, Span) {
+	var span Span = nopSpan{}
+	if r.Tracer != nil {
+		ctx, span = r.Tracer.Start(ctx, "mockdns.Resolver."+method)
+		span.SetAttribute(AttrQuestionName, name)
+		if qtype != 0 {
+			span.SetAttribute(AttrQuestionType, dns.Type(qtype).String())
+		}
+	}
+
+	if r.Trace != nil {
+		if r.Trace.LookupStart != nil {
+			r.Trace.LookupStart(method, name)
+		}
+		span = traceSpan{Span: span, trace: r.Trace, method: method, name: name}
+	}
+	return ctx, span
+}
+
+func (s *Server) startSpan(m *dns.Msg) Span {
+	if s.Tracer == nil {
+		return nopSpan{}
+	}
+
+	_, span := s.Tracer.Start(context.Background(), "mockdns.Server.ServeDNS")
+	if len(m.Question) != 0 {
+		q := m.Question[0]
+		span.SetAttribute(AttrQuestionName, q.Name)
+		span.SetAttribute(AttrQuestionType, dns.Type(q.Qtype).String())
+		span.SetAttribute(AttrQuestionClass, dns.Class(q.Qclass).String())
+	}
+	return span
+}
+
+// ResolverTrace is a set of hooks called at stages of Resolver lookups,
+// similar to net/http/httptrace. It makes it possible to check intermediate
+// resolution steps, not just the final result. Any hook can be nil.
+//
+// Hooks are called synchronously from the goroutine doing the lookup.
+type ResolverTrace struct {
+	// LookupStart is called when a Lookup* method starts.
+	LookupStart func(method, name string)
+
+	// LookupDone is called when a Lookup* method returns, err is the
+	// returned error.
+	LookupDone func(method, name string, err error)
+
+	// ZoneMatched is called when a zone is found for the queried name or
+	// a CNAME target.
+	ZoneMatched func(name string, zone Zone)
+
+	// CNAMEFollowed is called when a CNAME is followed during a lookup.
+	CNAMEFollowed func(from, to string)
+}
+
+// traceSpan calls ResolverTrace.LookupDone when the lookup ends.
+type traceSpan struct {
+	Span
+	trace        *ResolverTrace
+	method, name string
+}
+
+func (s traceSpan) End(err error) {
+	s.Span.End(err)
+	if s.trace.LookupDone != nil {
+		s.trace.LookupDone(s.method, s.name, err)
+	}
+}
+
+func (r *Resolver) traceZone(name string, zone Zone) {
+	if r.Trace != nil && r.Trace.ZoneMatched != nil {
+		r.Trace.ZoneMatched(name, zone)
+	}
+}
+
+func (r *Resolver) traceCNAME(from, to string) {
+	if r.Trace != nil && r.Trace.CNAMEFollowed != nil {
+		r.Trace.CNAMEFollowed(from, to)
+	}
+}
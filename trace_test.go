@@ -0,0 +1,163 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type testSpan struct {
+	t     *testTracer
+	name  string
+	attrs map[string]string
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key, value string) {
+	s.t.lck.Lock()
+	defer s.t.lck.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.t.lck.Lock()
+	s.err = err
+	s.ended = true
+	s.t.lck.Unlock()
+	s.t.ended.Done()
+}
+
+type testTracer struct {
+	lck   sync.Mutex
+	spans []*testSpan
+	ended sync.WaitGroup
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+	t.ended.Add(1)
+	span := &testSpan{t: t, name: name, attrs: map[string]string{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestResolver_Tracer(t *testing.T) {
+	tracer := &testTracer{}
+	r := Resolver{
+		Zones: map[string]Zone{
+			"example.org.": {
+				MX: []net.MX{{Host: "mx.example.org.", Pref: 10}},
+			},
+		},
+		Tracer: tracer,
+	}
+
+	if _, err := r.LookupMX(context.Background(), "example.org."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.LookupHost(context.Background(), "example.com."); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Wrong amount of spans: %v", len(tracer.spans))
+	}
+	mx := tracer.spans[0]
+	if mx.name != "mockdns.Resolver.LookupMX" || !mx.ended || mx.err != nil {
+		t.Errorf("Wrong MX span: %+v", mx)
+	}
+	if mx.attrs[AttrQuestionName] != "example.org." || mx.attrs[AttrQuestionType] != "MX" {
+		t.Errorf("Wrong MX span attributes: %v", mx.attrs)
+	}
+	host := tracer.spans[1]
+	if host.name != "mockdns.Resolver.LookupHost" || !host.ended || host.err == nil {
+		t.Errorf("Wrong LookupHost span: %+v", host)
+	}
+}
+
+func TestServer_Tracer(t *testing.T) {
+	tracer := &testTracer{}
+	srv := newTestServer(t, map[string]Zone{}, func(s *Server) {
+		s.Tracer = tracer
+	})
+	defer srv.Close()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.org.", dns.TypeAAAA)
+	cl := dns.Client{}
+	if _, _, err := cl.Exchange(msg, srv.LocalAddr().String()); err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	// Span is ended after the reply is sent.
+	tracer.ended.Wait()
+
+	tracer.lck.Lock()
+	defer tracer.lck.Unlock()
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Wrong amount of spans: %v", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != "mockdns.Server.ServeDNS" || !span.ended {
+		t.Errorf("Wrong span: %+v", span)
+	}
+	want := map[string]string{
+		AttrQuestionName:  "example.org.",
+		AttrQuestionType:  "AAAA",
+		AttrQuestionClass: "IN",
+		AttrResponseCode:  "NXDOMAIN",
+	}
+	for k, v := range want {
+		if span.attrs[k] != v {
+			t.Errorf("Wrong %s attribute, want %v, got %v", k, v, span.attrs[k])
+		}
+	}
+}
+
+func TestResolver_Trace(t *testing.T) {
+	var events []string
+	r := Resolver{
+		Zones: map[string]Zone{
+			"example.org.":        {CNAME: "target.example.org."},
+			"target.example.org.": {A: []string{"192.0.2.1"}},
+		},
+		Trace: &ResolverTrace{
+			LookupStart: func(method, name string) {
+				events = append(events, "start "+method+" "+name)
+			},
+			LookupDone: func(method, name string, err error) {
+				events = append(events, "done "+method+" "+name)
+			},
+			ZoneMatched: func(name string, zone Zone) {
+				events = append(events, "zone "+name)
+			},
+			CNAMEFollowed: func(from, to string) {
+				events = append(events, "cname "+from+" "+to)
+			},
+		},
+	}
+
+	if _, err := r.LookupMX(context.Background(), "example.org"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"start LookupMX example.org",
+		"zone example.org.",
+		"cname example.org. target.example.org.",
+		"zone target.example.org.",
+		"done LookupMX example.org",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("Wrong result, want %v, got %v", want, events)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mockdns
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// zeroTTLs sets TTLs of all records in the reply to zero, see
+// Server.ZeroTTL.
+func zeroTTLs(reply *dns.Msg) {
+	mapRecords(reply, func(rr dns.RR) {
+		rr.Header().Ttl = 0
+		if soa, ok := rr.(*dns.SOA); ok {
+			soa.Minttl = 0
+		}
+	})
+}
+
+type rrsetKey struct {
+	name          string
+	rrtype, class uint16
+}
+
+// jitterTTLs randomizes TTLs of all records in the reply, see
+// Server.TTLJitter. Records of the same RRset get the same TTL, since TTLs
+// must not differ within an RRset (RFC 2181, Section 5.2).
+func (s *Server) jitterTTLs(reply *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ttlRand == nil {
+		s.ttlRand = rand.New(rand.NewSource(s.TTLJitterSeed))
+	}
+
+	ttls := make(map[rrsetKey]uint32)
+	mapRecords(reply, func(rr dns.RR) {
+		hdr := rr.Header()
+		key := rrsetKey{name: strings.ToLower(hdr.Name), rrtype: hdr.Rrtype, class: hdr.Class}
+		if ttl, ok := ttls[key]; ok {
+			hdr.Ttl = ttl
+			return
+		}
+
+		band := float64(hdr.Ttl) * s.TTLJitter
+		ttl := float64(hdr.Ttl) + band*(2*s.ttlRand.Float64()-1)
+		if ttl < 0 {
+			ttl = 0
+		}
+		hdr.Ttl = uint32(ttl + 0.5)
+		ttls[key] = hdr.Ttl
+	})
+}
+
+// clampTTLs limits TTLs of all records in the reply, see Server.MinTTL and
+// Server.MaxTTL.
+func (s *Server) clampTTLs(reply *dns.Msg) {
+	clamp := func(ttl uint32) uint32 {
+		if ttl < s.MinTTL {
+			ttl = s.MinTTL
+		}
+		if s.MaxTTL != 0 && ttl > s.MaxTTL {
+			ttl = s.MaxTTL
+		}
+		return ttl
+	}
+	mapRecords(reply, func(rr dns.RR) {
+		rr.Header().Ttl = clamp(rr.Header().Ttl)
+		if soa, ok := rr.(*dns.SOA); ok {
+			soa.Minttl = clamp(soa.Minttl)
+		}
+	})
+}
+
+// mapRecords calls f for copies of all records in the reply, except OPT,
+// and replaces records with them, see sectionWriter.
+func mapRecords(reply *dns.Msg, f func(dns.RR)) {
+	for _, section := range []*[]dns.RR{&reply.Answer, &reply.Ns, &reply.Extra} {
+		w := sectionWriter{section: section}
+		for i, rr := range *section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			rr = dns.Copy(rr)
+			f(rr)
+			w.set(i, rr)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package mockdns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_ZeroTTL(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			CNAME: "target.example.org.",
+		},
+		"target.example.org.": {
+			A: []string{"192.0.2.1"},
+		},
+	}, func(s *Server) {
+		s.ZeroTTL = true
+	})
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if len(reply.Answer) != 2 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	for _, rr := range reply.Answer {
+		if rr.Header().Ttl != 0 {
+			t.Errorf("Non-zero TTL: %v", rr)
+		}
+	}
+
+	reply = queryFrom(t, srv, "127.0.0.1", "missing.example.org.", dns.TypeA)
+	if len(reply.Ns) != 1 {
+		t.Fatalf("Wrong authority: %v", reply.Ns)
+	}
+	if soa := reply.Ns[0].(*dns.SOA); soa.Hdr.Ttl != 0 || soa.Minttl != 0 {
+		t.Errorf("Non-zero negative TTL: %v", soa)
+	}
+
+	// Cached answers are not modified.
+	set, err := srv.answer("", &srv.r, "example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.answer[0].Header().Ttl == 0 {
+		t.Error("Shared answer is modified")
+	}
+}
+
+func TestServer_TTLJitter(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {
+			TTL: 300,
+			A:   []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		},
+	}, func(s *Server) {
+		s.TTLJitter = 0.1
+		s.TTLJitterSeed = 42
+	})
+	defer srv.Close()
+
+	ttls := make(map[uint32]bool)
+	for i := 0; i < 20; i++ {
+		reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+		ttl := reply.Answer[0].Header().Ttl
+		if ttl < 270 || ttl > 330 {
+			t.Fatalf("TTL is out of band: %v", ttl)
+		}
+		for _, rr := range reply.Answer[1:] {
+			if rr.Header().Ttl != ttl {
+				t.Fatalf("TTLs differ within RRset: %v", reply.Answer)
+			}
+		}
+		ttls[ttl] = true
+	}
+	if len(ttls) < 2 {
+		t.Fatalf("TTLs are not randomized: %v", ttls)
+	}
+
+	// Shared records are not modified.
+	if ttl := srv.compiledRRs("example.org.", srv.r.Zones["example.org."]).byType[dns.TypeA][0].Header().Ttl; ttl != 300 {
+		t.Fatalf("Zone record is modified: TTL %v", ttl)
+	}
+}
+
+func TestServer_ClampTTL(t *testing.T) {
+	zones := map[string]Zone{
+		"example.org.": {
+			TTL:   3600,
+			CNAME: "target.example.org.",
+		},
+		"target.example.org.": {
+			TTL: 10,
+			A:   []string{"192.0.2.1"},
+		},
+	}
+	srv := newTestServer(t, zones, func(s *Server) {
+		s.MinTTL = 60
+		s.MaxTTL = 300
+	})
+	defer srv.Close()
+
+	reply := queryFrom(t, srv, "127.0.0.1", "example.org.", dns.TypeA)
+	if len(reply.Answer) != 2 {
+		t.Fatalf("Wrong answer: %v", reply.Answer)
+	}
+	if ttl := reply.Answer[0].Header().Ttl; ttl != 300 {
+		t.Errorf("Wrong result, want %v, got %v", 300, ttl)
+	}
+	if ttl := reply.Answer[1].Header().Ttl; ttl != 60 {
+		t.Errorf("Wrong result, want %v, got %v", 60, ttl)
+	}
+
+	fixed := newTestServer(t, zones, func(s *Server) {
+		s.MinTTL, s.MaxTTL = 5, 5
+	})
+	defer fixed.Close()
+	reply = queryFrom(t, fixed, "127.0.0.1", "missing.example.org.", dns.TypeA)
+	if len(reply.Ns) != 1 {
+		t.Fatalf("Wrong authority: %v", reply.Ns)
+	}
+	if soa := reply.Ns[0].(*dns.SOA); soa.Hdr.Ttl != 5 || soa.Minttl != 5 {
+		t.Errorf("Wrong negative TTL: %v", soa)
+	}
+}
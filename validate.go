@@ -0,0 +1,183 @@
+package mockdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ValidateZones checks zones for configurations that are not valid in DNS
+// and cause confusing mock behavior. All found problems are returned.
+//
+// Currently, it reports names that have a CNAME and other records, see
+// RFC 1034, Section 3.6.2.
+//
+// It is intended to be used in tests of fixtures themselves.
+func ValidateZones(zones map[string]Zone) []error {
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		zone := zones[name]
+
+		if zone.CNAME != "" {
+			if other := recordTypes(zone); len(other) != 0 {
+				errs = append(errs, fmt.Errorf("%s: CNAME cannot coexist with other records (%s)", name, strings.Join(other, ", ")))
+			}
+		}
+	}
+
+	return errs
+}
+
+// recordTypes returns the list of record types, except CNAME, present in
+// the zone.
+func recordTypes(zone Zone) []string {
+	var types []string
+	add := func(present bool, rrtype uint16) {
+		if present {
+			types = append(types, dns.Type(rrtype).String())
+		}
+	}
+	add(len(zone.A) != 0, dns.TypeA)
+	add(len(zone.AAAA) != 0, dns.TypeAAAA)
+	add(len(zone.TXT) != 0, dns.TypeTXT)
+	add(len(zone.PTR) != 0, dns.TypePTR)
+	add(len(zone.MX) != 0, dns.TypeMX)
+	add(len(zone.NS) != 0, dns.TypeNS)
+	add(len(zone.SRV) != 0, dns.TypeSRV)
+
+	misc := make([]string, 0, len(zone.Misc))
+	for rrtype, rrs := range zone.Misc {
+		switch uint16(rrtype) {
+		case dns.TypeRRSIG, dns.TypeNSEC:
+			// DNSSEC records are allowed to be present with CNAME.
+			continue
+		}
+		if len(rrs) != 0 {
+			misc = append(misc, rrtype.String())
+		}
+	}
+	sort.Strings(misc)
+
+	return append(types, misc...)
+}
+
+// CheckFCrDNS checks that zones are consistent for forward-confirmed reverse
+// DNS: every A and AAAA address has a PTR record pointing back to the name
+// and every PTR record points to a name that has the address. All found
+// problems are returned.
+//
+// Lookups are done using Resolver, so CNAMEs of PTR targets are followed.
+func CheckFCrDNS(zones map[string]Zone) []error {
+	r := &Resolver{Zones: zones}
+	ctx := context.Background()
+
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		zone := zones[name]
+		fqdn := normalizeName(name)
+
+		for _, addr := range append(append([]string(nil), zone.A...), zone.AAAA...) {
+			ip, _ := parseIPZone(addr)
+			if ip == nil {
+				errs = append(errs, fmt.Errorf("%s: malformed address %s", name, addr))
+				continue
+			}
+			ptrs, _ := r.LookupAddr(ctx, ip.String())
+			if len(ptrs) == 0 {
+				errs = append(errs, fmt.Errorf("%s: %s has no PTR record", name, addr))
+			} else if !containsName(ptrs, fqdn) {
+				errs = append(errs, fmt.Errorf("%s: PTR of %s points to %s", name, addr, strings.Join(ptrs, ", ")))
+			}
+		}
+
+		if len(zone.PTR) == 0 {
+			continue
+		}
+		ip := parseReverseName(fqdn)
+		if ip == nil {
+			continue
+		}
+		for _, ptr := range zone.PTR {
+			addrs, _ := r.LookupIPAddr(ctx, ptr)
+			found := false
+			for _, addr := range addrs {
+				if addr.IP.Equal(ip) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("%s: PTR target %s does not have address %v", name, ptr, ip))
+			}
+		}
+	}
+
+	return errs
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if normalizeName(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseReverseName returns the address for the reverse lookup name, see
+// ReverseName. nil is returned if name is not a reverse name of an address.
+func parseReverseName(name string) net.IP {
+	name = normalizeName(name)
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa."):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa."), ".")
+		if len(labels) != net.IPv4len {
+			return nil
+		}
+		ip := make(net.IP, net.IPv4len)
+		for i, l := range labels {
+			b, err := strconv.ParseUint(l, 10, 8)
+			if err != nil {
+				return nil
+			}
+			ip[net.IPv4len-1-i] = byte(b)
+		}
+		return ip
+	case strings.HasSuffix(name, ".ip6.arpa."):
+		labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa."), ".")
+		if len(labels) != net.IPv6len*2 {
+			return nil
+		}
+		ip := make(net.IP, net.IPv6len)
+		for i, l := range labels {
+			n, err := strconv.ParseUint(l, 16, 4)
+			if err != nil || len(l) != 1 {
+				return nil
+			}
+			if i%2 == 0 {
+				ip[net.IPv6len-1-i/2] |= byte(n)
+			} else {
+				ip[net.IPv6len-1-i/2] |= byte(n) << 4
+			}
+		}
+		return ip
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package mockdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestValidateZones(t *testing.T) {
+	errs := ValidateZones(map[string]Zone{
+		"example.org.": {
+			A: []string{"1.2.3.4"},
+		},
+		"www.example.org.": {
+			CNAME: "example.org.",
+		},
+		"bad.example.org.": {
+			CNAME: "example.org.",
+			A:     []string{"1.2.3.4"},
+			MX:    []net.MX{{Host: "example.org.", Pref: 10}},
+			Misc: map[dns.Type][]dns.RR{
+				dns.Type(dns.TypeRRSIG): {&dns.RRSIG{}},
+				dns.Type(dns.TypeTLSA):  {&dns.TLSA{}},
+			},
+		},
+	})
+
+	if len(errs) != 1 {
+		t.Fatalf("Wrong amount of errors: %v", errs)
+	}
+	want := "bad.example.org.: CNAME cannot coexist with other records (A, MX, TLSA)"
+	if errs[0].Error() != want {
+		t.Errorf("Wrong error, want %q, got %q", want, errs[0])
+	}
+}
+
+func TestCheckFCrDNS(t *testing.T) {
+	zones := map[string]Zone{
+		"mx.example.org.": {
+			A:    []string{"192.0.2.1"},
+			AAAA: []string{"2001:db8::1"},
+		},
+		"1.2.0.192.in-addr.arpa.": {
+			PTR: []string{"mx.example.org."},
+		},
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": {
+			PTR: []string{"MX.example.org"},
+		},
+	}
+	if errs := CheckFCrDNS(zones); len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	zones["mail.example.org."] = Zone{A: []string{"192.0.2.2"}}
+	zones["2.2.0.192.in-addr.arpa."] = Zone{PTR: []string{"other.example.org."}}
+	zones["3.2.0.192.in-addr.arpa."] = Zone{PTR: []string{"mx.example.org."}}
+	zones["lonely.example.org."] = Zone{A: []string{"192.0.2.4"}}
+
+	errs := CheckFCrDNS(zones)
+	want := []string{
+		"2.2.0.192.in-addr.arpa.: PTR target other.example.org. does not have address 192.0.2.2",
+		"3.2.0.192.in-addr.arpa.: PTR target mx.example.org. does not have address 192.0.2.3",
+		"lonely.example.org.: 192.0.2.4 has no PTR record",
+		"mail.example.org.: PTR of 192.0.2.2 points to other.example.org.",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Wrong errors, want %v, got %v", want, errs)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("Wrong error %d, want %v, got %v", i, want[i], err)
+		}
+	}
+}
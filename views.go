@@ -0,0 +1,95 @@
+package mockdns
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// View is the set of zones served to clients from specific networks
+// (split-horizon DNS). See AddView.
+type View struct {
+	Name  string
+	Nets  []*net.IPNet
+	Zones map[string]Zone
+}
+
+// AddView adds the view with the specified name, replacing the existing one,
+// if any. Clients with addresses from cidrs networks are served zones from
+// the view instead of Server zones. Views are checked in the order they were
+// added, the first matching one is used. Replacing a view increments SOA
+// serials of its changed zones, see Server.SOASerial.
+//
+// Other Resolver options (e.g. Hosts) are shared between views. zones should
+// not be modified after the call, AddView should be called again instead.
+func (s *Server) AddView(name string, zones map[string]Zone, cidrs ...string) error {
+	v := View{Name: name, Zones: zones}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("view %s: %v", name, err)
+		}
+		v.Nets = append(v.Nets, ipNet)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	atomic.AddUint32(&s.viewGen, 1)
+	for i, existing := range s.views {
+		if existing.Name == name {
+			s.r.src.mu.Lock()
+			s.r.src.bumpViewSerials(name, changedZones(existing.Zones, zones))
+			s.r.src.mu.Unlock()
+			s.views[i] = v
+			return nil
+		}
+	}
+	s.views = append(s.views, v)
+	return nil
+}
+
+// RemoveView removes the view with the specified name.
+func (s *Server) RemoveView(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	atomic.AddUint32(&s.viewGen, 1)
+	for i, v := range s.views {
+		if v.Name == name {
+			s.views = append(s.views[:i:i], s.views[i+1:]...)
+			return
+		}
+	}
+}
+
+// resolverFor returns the Resolver to use for the query and the name of its
+// view. The Server Resolver is returned if no view or region matches.
+func (s *Server) resolverFor(addr net.Addr, m *dns.Msg) (string, *Resolver) {
+	s.mu.Lock()
+	views := s.views
+	s.mu.Unlock()
+
+	reg := s.regionFor(addr, m)
+	if len(views) == 0 && reg == "" {
+		return "", &s.r
+	}
+
+	r := s.r
+	r.Region = reg
+	viewName := ""
+	if ip := addrIP(addr); ip != nil {
+	views:
+		for _, v := range views {
+			for _, ipNet := range v.Nets {
+				if ipNet.Contains(ip) {
+					r.Zones = v.Zones
+					r.src = nil
+					viewName = v.Name
+					break views
+				}
+			}
+		}
+	}
+	return viewName, &r
+}
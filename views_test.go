@@ -0,0 +1,71 @@
+package mockdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func queryFrom(t *testing.T, srv *Server, localIP string, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+
+	cl := dns.Client{
+		Dialer: &net.Dialer{
+			LocalAddr: &net.UDPAddr{IP: net.ParseIP(localIP)},
+		},
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestServer_Views(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"app.example.org.": {A: []string{"203.0.113.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	err = srv.AddView("internal", map[string]Zone{
+		"app.example.org.":      {A: []string{"10.0.0.1"}},
+		"intranet.example.org.": {A: []string{"10.0.0.2"}},
+	}, "127.0.0.2/32", "10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := queryFrom(t, srv, "127.0.0.1", "app.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "203.0.113.1" {
+		t.Errorf("Wrong external answer: %v", reply.Answer)
+	}
+	reply = queryFrom(t, srv, "127.0.0.1", "intranet.example.org.", dns.TypeA)
+	if reply.Rcode != dns.RcodeNameError {
+		t.Errorf("Internal name is visible externally: %v", reply)
+	}
+
+	reply = queryFrom(t, srv, "127.0.0.2", "app.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Errorf("Wrong internal answer: %v", reply.Answer)
+	}
+	reply = queryFrom(t, srv, "127.0.0.2", "intranet.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 {
+		t.Errorf("Wrong internal answer: %v", reply.Answer)
+	}
+
+	srv.RemoveView("internal")
+	reply = queryFrom(t, srv, "127.0.0.2", "app.example.org.", dns.TypeA)
+	if len(reply.Answer) != 1 || reply.Answer[0].(*dns.A).A.String() != "203.0.113.1" {
+		t.Errorf("Removed view is used: %v", reply.Answer)
+	}
+
+	if err := srv.AddView("bad", nil, "not-a-cidr"); err == nil {
+		t.Error("Expected error for malformed CIDR")
+	}
+}
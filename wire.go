@@ -0,0 +1,124 @@
+package mockdns
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// RespondWithWire makes the Server reply to queries with the question of
+// packet by sending packet byte-for-byte, except for its ID which is
+// replaced with the query ID. This makes it possible to replay captured
+// responses exactly, including name compression and malformed records as
+// long as the header and question section can be parsed.
+//
+// Otherwise, it works the same way as RespondWith. MaxUDPSize and
+// MaxTCPSize apply to the packet as is: if it exceeds MaxUDPSize, only the
+// header and question section are sent with the TC bit set.
+func (s *Server) RespondWithWire(packet []byte) error {
+	msg, err := unpackFixture(packet)
+	if err != nil {
+		return err
+	}
+	if len(msg.Question) != 1 {
+		return fmt.Errorf("wire fixture: want 1 question, got %d", len(msg.Question))
+	}
+	q := msg.Question[0]
+
+	wire := make([]byte, len(packet))
+	copy(wire, packet)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injected = append(s.injected, injectedReply{
+		match: MatchQuestion(q.Name, q.Qtype),
+		msg:   msg,
+		wire:  wire,
+	})
+	return nil
+}
+
+// LoadWireFixtures calls RespondWithWire for the contents of each file.
+// Files can contain either the raw packet or its hex encoding, optionally
+// split by whitespace (e.g. as printed by xxd -p).
+func (s *Server) LoadWireFixtures(paths ...string) error {
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := s.RespondWithWire(decodeFixture(data)); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// decodeFixture returns the packet hex-decoded if data contains only hex
+// digits and whitespace, data as is otherwise.
+func decodeFixture(data []byte) []byte {
+	text := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, string(data))
+	packet, err := hex.DecodeString(text)
+	if err != nil || len(text) == 0 {
+		return data
+	}
+	return packet
+}
+
+// unpackFixture parses packet, falling back to the header and question
+// section if records can't be parsed.
+func unpackFixture(packet []byte) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(packet); err == nil {
+		return msg, nil
+	}
+
+	if len(packet) < 12 {
+		return nil, fmt.Errorf("wire fixture: packet too short: %d bytes", len(packet))
+	}
+	bits := uint16(packet[2])<<8 | uint16(packet[3])
+	qdcount := int(packet[4])<<8 | int(packet[5])
+
+	msg = new(dns.Msg)
+	msg.Id = uint16(packet[0])<<8 | uint16(packet[1])
+	msg.Response = bits&(1<<15) != 0
+	msg.Rcode = int(bits & 0xF)
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, err := dns.UnpackDomainName(packet, off)
+		if err != nil || next+4 > len(packet) {
+			return nil, fmt.Errorf("wire fixture: malformed question section")
+		}
+		msg.Question = append(msg.Question, dns.Question{
+			Name:   name,
+			Qtype:  uint16(packet[next])<<8 | uint16(packet[next+1]),
+			Qclass: uint16(packet[next+2])<<8 | uint16(packet[next+3]),
+		})
+		off = next + 4
+	}
+	return msg, nil
+}
+
+// writeWire sends wire as the reply to query, applying size limits and
+// fuzzing the same way as for packed replies.
+func (s *Server) writeWire(w dns.ResponseWriter, query *dns.Msg, wire []byte) error {
+	start := time.Now()
+	packet := make([]byte, len(wire))
+	copy(packet, wire)
+	packet[0], packet[1] = byte(query.Id>>8), byte(query.Id)
+	packet, err := s.limitWireSize(w, packet)
+	if err != nil || packet == nil {
+		return err
+	}
+	return s.writePacked(w, query, packet, start)
+}
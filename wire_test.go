@@ -0,0 +1,162 @@
+package mockdns
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_LoadWireFixtures(t *testing.T) {
+	captured := new(dns.Msg)
+	captured.Id = 0x1234
+	captured.Response = true
+	captured.Compress = true
+	captured.Question = []dns.Question{{Name: "example.org.", Qtype: dns.TypeMX, Qclass: dns.ClassINET}}
+	rr, err := dns.NewRR("example.org. 300 IN MX 10 mx.example.org.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured.Answer = []dns.RR{rr}
+	packet, err := captured.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Trailing garbage is kept and does not prevent loading.
+	packet = append(packet, 0xFF)
+
+	dir, err := ioutil.TempDir("", "mockdns-wire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binPath := filepath.Join(dir, "mx.bin")
+	hexPath := filepath.Join(dir, "mx.hex")
+	if err := ioutil.WriteFile(binPath, packet, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hexText := hex.EncodeToString(packet)
+	hexText = hexText[:10] + "\n" + hexText[10:] + "\n"
+	if err := ioutil.WriteFile(hexPath, []byte(hexText), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{binPath, hexPath} {
+		srv, err := NewServer(map[string]Zone{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		if err := srv.LoadWireFixtures(path); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("udp", srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		m := new(dns.Msg)
+		m.SetQuestion("EXAMPLE.org.", dns.TypeMX)
+		query, err := m.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(query); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 512)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := append([]byte{byte(m.Id >> 8), byte(m.Id)}, packet[2:]...)
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("Wrong result, want %x, got %x", want, buf[:n])
+		}
+	}
+}
+
+func TestServer_RespondWithWire_Malformed(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	if err := srv.RespondWithWire([]byte{1, 2, 3}); err == nil {
+		t.Fatal("Expected error for short packet")
+	}
+	// No questions.
+	if err := srv.RespondWithWire(make([]byte, 12)); err == nil {
+		t.Fatal("Expected error for packet without questions")
+	}
+}
+
+func TestServer_RespondWithWire_Truncated(t *testing.T) {
+	captured := new(dns.Msg)
+	captured.SetQuestion("example.org.", dns.TypeTXT)
+	captured.Response = true
+	for i := 0; i < 10; i++ {
+		rr, err := dns.NewRR("example.org. 300 IN TXT \"" + strings.Repeat("a", 100) + "\"")
+		if err != nil {
+			t.Fatal(err)
+		}
+		captured.Answer = append(captured.Answer, rr)
+	}
+	packet, err := captured.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stagesMu sync.Mutex
+	var stages []Stage
+	srv := newTestServer(t, map[string]Zone{}, func(s *Server) {
+		s.MaxUDPSize = 512
+		if err := s.RespondWithWire(packet); err != nil {
+			t.Fatal(err)
+		}
+		s.OnStage(func(info StageInfo) {
+			stagesMu.Lock()
+			defer stagesMu.Unlock()
+			stages = append(stages, info.Stage)
+		})
+	})
+	defer srv.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeTXT)
+	cl := dns.Client{UDPSize: dns.MaxMsgSize}
+	reply, _, err := cl.Exchange(m, srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Truncated {
+		t.Fatal("Wrong result, want truncated reply")
+	}
+	if len(reply.Answer) != 0 {
+		t.Fatalf("Wrong result, want %v, got %v", 0, len(reply.Answer))
+	}
+	if len(reply.Question) != 1 || reply.Question[0] != m.Question[0] {
+		t.Fatalf("Wrong result, want %v, got %v", m.Question, reply.Question)
+	}
+
+	// StageWrite may be emitted after the reply is received.
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	want := []Stage{StageParse, StageLookup, StageEncode}
+	if len(stages) < len(want) || !reflect.DeepEqual(stages[:len(want)], want) {
+		t.Fatalf("Wrong result, want %v, got %v", want, stages)
+	}
+}
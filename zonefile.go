@@ -0,0 +1,126 @@
+package mockdns
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// AddZoneFile parses the RFC 1035 zone file from r and adds its records to
+// zones. origin is used for relative names unless the file sets $ORIGIN,
+// file is used in error messages only.
+//
+// A, AAAA, CNAME, MX, NS, SRV, PTR and single-string TXT records are stored
+// in the corresponding Zone fields, all other records (including TXT with
+// multiple character-strings) are stored in Misc. The TTL of the first record
+// of the name is used as Zone.TTL, Misc records keep their own TTLs.
+// Records in the RFC 3597 generic syntax (TYPEnnn \# ...) are supported,
+// including ones of unknown types.
+func AddZoneFile(zones map[string]Zone, r io.Reader, origin, file string) error {
+	zp := dns.NewZoneParser(r, dns.Fqdn(origin), file)
+	zp.SetIncludeAllowed(false)
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		addRR(zones, rr)
+	}
+	return zp.Err()
+}
+
+// AddZonesYAML parses the YAML (or JSON) document from r mapping names to
+// their records and adds them to zones, the same way as AddZoneFile. Records
+// are in the zone file format without the owner name, either a single one or
+// a list:
+//
+//	example.org.:
+//	  - 300 IN A 192.0.2.1
+//	  - 300 IN MX 10 mx.example.org.
+//	www.example.org.: 300 IN CNAME example.org.
+//
+// Names must be fully qualified. file is used in error messages only.
+func AddZonesYAML(zones map[string]Zone, r io.Reader, file string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := unmarshalDocument(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var text strings.Builder
+	for _, name := range names {
+		var records []interface{}
+		switch v := doc[name].(type) {
+		case string:
+			records = []interface{}{v}
+		case []interface{}:
+			records = v
+		default:
+			return fmt.Errorf("%s: %s: want records, got %v", file, name, v)
+		}
+		for _, rr := range records {
+			rr, ok := rr.(string)
+			if !ok {
+				return fmt.Errorf("%s: %s: malformed record: %v", file, name, rr)
+			}
+			text.WriteString(name + " " + rr + "\n")
+		}
+	}
+	return AddZoneFile(zones, strings.NewReader(text.String()), ".", file)
+}
+
+// addRR adds rr to the zone named after its owner.
+func addRR(zones map[string]Zone, rr dns.RR) {
+	name := normalizeName(rr.Header().Name)
+	zone, ok := zones[name]
+	if !ok {
+		zone.TTL = rr.Header().Ttl
+	}
+
+	switch rr := rr.(type) {
+	case *dns.A:
+		zone.A = append(zone.A, rr.A.String())
+	case *dns.AAAA:
+		zone.AAAA = append(zone.AAAA, rr.AAAA.String())
+	case *dns.CNAME:
+		zone.CNAME = rr.Target
+	case *dns.MX:
+		zone.MX = append(zone.MX, net.MX{Host: rr.Mx, Pref: rr.Preference})
+	case *dns.NS:
+		zone.NS = append(zone.NS, net.NS{Host: rr.Ns})
+	case *dns.SRV:
+		zone.SRV = append(zone.SRV, net.SRV{
+			Target:   rr.Target,
+			Port:     rr.Port,
+			Priority: rr.Priority,
+			Weight:   rr.Weight,
+		})
+	case *dns.PTR:
+		zone.PTR = append(zone.PTR, rr.Ptr)
+	case *dns.TXT:
+		if len(rr.Txt) == 1 {
+			zone.TXT = append(zone.TXT, rr.Txt[0])
+			break
+		}
+		zones[name] = zone
+		addMisc(zones, name, rr)
+		return
+	default:
+		zones[name] = zone
+		addMisc(zones, name, rr)
+		return
+	}
+
+	zones[name] = zone
+}
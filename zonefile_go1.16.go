@@ -0,0 +1,38 @@
+//go:build go1.16
+// +build go1.16
+
+package mockdns
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// LoadZonesFS loads zone files matching glob (see fs.Glob) from fsys, e.g.
+// embed.FS, using AddZoneFile.
+//
+// The origin of each file is its base name with the ".zone" or ".db"
+// extension removed, so "testdata/example.org.zone" has the origin
+// "example.org.". $ORIGIN in the file takes precedence.
+func LoadZonesFS(fsys fs.FS, glob string) (map[string]Zone, error) {
+	files, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make(map[string]Zone)
+	for _, file := range files {
+		f, err := fsys.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		origin := strings.TrimSuffix(strings.TrimSuffix(path.Base(file), ".zone"), ".db")
+		err = AddZoneFile(zones, f, origin, file)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
@@ -0,0 +1,36 @@
+//go:build go1.16
+// +build go1.16
+
+package mockdns
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadZonesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/example.org.zone": {Data: []byte(testZoneFile)},
+		"testdata/db.example.net":   {Data: []byte("$ORIGIN example.net.\n@ 300 IN A 192.0.2.3\n")},
+		"testdata/README":           {Data: []byte("not a zone")},
+	}
+
+	zones, err := LoadZonesFS(fsys, "testdata/*.zone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := zones["mx.example.org."]; !ok {
+		t.Fatalf("Zones are not loaded: %v", zones)
+	}
+	if _, ok := zones["example.net."]; ok {
+		t.Fatal("File not matching glob is loaded")
+	}
+
+	zones, err = LoadZonesFS(fsys, "testdata/db.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := zones["example.net."].A; len(a) != 1 || a[0] != "192.0.2.3" {
+		t.Fatalf("Wrong A, want %v, got %v", "192.0.2.3", a)
+	}
+}
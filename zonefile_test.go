@@ -0,0 +1,101 @@
+package mockdns
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testZoneFile = `$TTL 300
+@	IN	A	192.0.2.1
+@	IN	AAAA	2001:db8::1
+@	IN	MX	10 mx
+@	IN	TXT	"v=spf1 -all"
+@	IN	TXT	"part1" "part2"
+www	IN	CNAME	@
+_smtp._tcp	IN	SRV	0 5 25 mx
+mx	600	IN	A	192.0.2.2
+caa	IN	CAA	0 issue "ca.example.net"
+`
+
+func TestAddZoneFile(t *testing.T) {
+	zones := make(map[string]Zone)
+	if err := AddZoneFile(zones, strings.NewReader(testZoneFile), "example.org", "test.zone"); err != nil {
+		t.Fatal(err)
+	}
+
+	zone := zones["example.org."]
+	if zone.TTL != 300 {
+		t.Errorf("Wrong TTL, want %v, got %v", 300, zone.TTL)
+	}
+	if want := []string{"192.0.2.1"}; !reflect.DeepEqual(zone.A, want) {
+		t.Errorf("Wrong A, want %v, got %v", want, zone.A)
+	}
+	if want := []net.MX{{Host: "mx.example.org.", Pref: 10}}; !reflect.DeepEqual(zone.MX, want) {
+		t.Errorf("Wrong MX, want %v, got %v", want, zone.MX)
+	}
+	if want := []string{"v=spf1 -all"}; !reflect.DeepEqual(zone.TXT, want) {
+		t.Errorf("Wrong TXT, want %v, got %v", want, zone.TXT)
+	}
+	if len(zone.Misc[dns.Type(dns.TypeTXT)]) != 1 {
+		t.Errorf("TXT with multiple strings is not stored in Misc: %v", zone.Misc)
+	}
+
+	if cname := zones["www.example.org."].CNAME; cname != "example.org." {
+		t.Errorf("Wrong CNAME, want %v, got %v", "example.org.", cname)
+	}
+	if srv := zones["_smtp._tcp.example.org."].SRV; len(srv) != 1 || srv[0].Target != "mx.example.org." || srv[0].Port != 25 {
+		t.Errorf("Wrong SRV: %v", srv)
+	}
+	if ttl := zones["mx.example.org."].TTL; ttl != 600 {
+		t.Errorf("Wrong TTL, want %v, got %v", 600, ttl)
+	}
+	if len(zones["caa.example.org."].Misc[dns.Type(dns.TypeCAA)]) != 1 {
+		t.Errorf("CAA is not stored in Misc")
+	}
+
+	err := AddZoneFile(zones, strings.NewReader("@ IN A not-an-ip\n"), "example.org", "bad.zone")
+	if err == nil || !strings.Contains(err.Error(), "bad.zone") {
+		t.Errorf("Wrong error for malformed file: %v", err)
+	}
+}
+
+func TestAddZonesYAML(t *testing.T) {
+	zones := make(map[string]Zone)
+	err := AddZonesYAML(zones, strings.NewReader(`
+example.org.:
+  - 300 IN A 192.0.2.1
+  - 300 IN TXT 123
+www.example.org.: 300 IN CNAME example.org.
+`), "zones.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Zone{
+		"example.org.": {
+			TTL: 300,
+			A:   []string{"192.0.2.1"},
+			TXT: []string{"123"},
+		},
+		"www.example.org.": {
+			TTL:   300,
+			CNAME: "example.org.",
+		},
+	}
+	if !reflect.DeepEqual(zones, want) {
+		t.Fatalf("Wrong result, want %+v, got %+v", want, zones)
+	}
+
+	for _, doc := range []string{
+		"example.org.: {a: 1}\n",
+		"example.org.: [[300 IN A 192.0.2.1]]\n",
+		"example.org.: 300 IN A bad\n",
+	} {
+		if err := AddZonesYAML(make(map[string]Zone), strings.NewReader(doc), "zones.yaml"); err == nil {
+			t.Errorf("Expected error for %s", doc)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package mockdns
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneSource holds the immutable snapshot of zones that can be replaced
+// atomically, so lookups never wait for a lock. It can be shared by multiple
+// Servers (see NewServerWithSource) so changes are visible on all of them at
+// once, as with anycast endpoints.
+type ZoneSource struct {
+	// mu serializes writers, readers just load the snapshot.
+	mu   sync.Mutex
+	snap atomic.Value // *zoneSnapshot
+
+	// serials contains SOA serials of changed zones, see Serial. initDate
+	// is the SerialDate serial of zones that did not change.
+	serials  atomic.Value // map[serialKey]zoneSerial
+	initDate uint32
+
+	// gen is incremented on each change, so caches built from older zones
+	// are discarded.
+	gen uint32
+}
+
+// zoneSnapshot is the state of ZoneSource. Zones added or removed one by one
+// are kept separately from the base map, so it is used directly and can be
+// still modified in place (e.g. using Server.Resolver).
+type zoneSnapshot struct {
+	base map[string]Zone
+	// changes contains zones set since base was set, nil for removed ones.
+	// It is never modified.
+	changes map[string]*Zone
+}
+
+func (snap *zoneSnapshot) get(name string) (Zone, bool) {
+	if zone, ok := snap.changes[name]; ok {
+		if zone == nil {
+			return Zone{}, false
+		}
+		return *zone, true
+	}
+	zone, ok := snap.base[name]
+	return zone, ok
+}
+
+// rangeNames calls f for names of all zones.
+func (snap *zoneSnapshot) rangeNames(f func(name string)) {
+	for name := range snap.base {
+		if _, ok := snap.changes[name]; !ok {
+			f(name)
+		}
+	}
+	for name, zone := range snap.changes {
+		if zone != nil {
+			f(name)
+		}
+	}
+}
+
+// zones returns zones of the snapshot as a single map.
+func (snap *zoneSnapshot) zones() map[string]Zone {
+	if len(snap.changes) == 0 {
+		return snap.base
+	}
+	zones := make(map[string]Zone, len(snap.base)+len(snap.changes))
+	snap.rangeNames(func(name string) {
+		zones[name], _ = snap.get(name)
+	})
+	return zones
+}
+
+// NewZoneSource returns the ZoneSource with the initial zones. The map is
+// used directly until SetZones is called.
+func NewZoneSource(zones map[string]Zone) *ZoneSource {
+	src := &ZoneSource{initDate: dateSerial(time.Now())}
+	if zones == nil {
+		zones = map[string]Zone{}
+	}
+	src.snap.Store(&zoneSnapshot{base: zones})
+	src.serials.Store(map[serialKey]zoneSerial{})
+	return src
+}
+
+func (src *ZoneSource) load() *zoneSnapshot {
+	return src.snap.Load().(*zoneSnapshot)
+}
+
+// Zones returns the current zones. It must not be modified.
+func (src *ZoneSource) Zones() map[string]Zone {
+	return src.load().zones()
+}
+
+// modify sets zones with the specified names to values returned by f, nil
+// removes the zone. f gets the current zone, if any.
+func (src *ZoneSource) modify(names []string, f func(name string, zone Zone, ok bool) *Zone) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	old := src.load()
+	changes := make(map[string]*Zone, len(old.changes)+len(names))
+	for name, zone := range old.changes {
+		changes[name] = zone
+	}
+	var changed []string
+	for _, name := range names {
+		zone, ok := old.get(name)
+		updated := f(name, zone, ok)
+		if updated == nil && !ok {
+			continue
+		}
+		if updated == nil || !ok || !sameZoneFull(zone, *updated) {
+			changed = append(changed, name)
+		}
+		changes[name] = updated
+	}
+	src.snap.Store(&zoneSnapshot{base: old.base, changes: changes})
+	src.bumpSerials(changed)
+	atomic.AddUint32(&src.gen, 1)
+}
+
+// replace sets zones to the map, which is used directly.
+func (src *ZoneSource) replace(zones map[string]Zone) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.replaceLocked(zones)
+}
+
+func (src *ZoneSource) replaceLocked(zones map[string]Zone) {
+	old := src.load().zones()
+	src.snap.Store(&zoneSnapshot{base: zones})
+	src.bumpSerials(changedZones(old, zones))
+	atomic.AddUint32(&src.gen, 1)
+}
+
+// changedZones returns names of zones that differ between the maps.
+func changedZones(old, zones map[string]Zone) []string {
+	var changed []string
+	for name, zone := range zones {
+		if oldZone, ok := old[name]; !ok || !sameZoneFull(oldZone, zone) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := zones[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// invalidate discards caches built from zones, since they may be about to
+// be modified in place.
+func (src *ZoneSource) invalidate() {
+	atomic.AddUint32(&src.gen, 1)
+}
+
+func (src *ZoneSource) generation() uint32 {
+	if src == nil {
+		return 0
+	}
+	return atomic.LoadUint32(&src.gen)
+}
+
+// snapshot returns the zones to use for lookups. If Zones was assigned a new
+// map since the last call (e.g. using Server.Resolver), it replaces zones of
+// the ZoneSource.
+func (r *Resolver) snapshot() *zoneSnapshot {
+	if r.seen != nil && !sameMap(r.Zones, r.seen.Load().(map[string]Zone)) {
+		r.src.adopt(r)
+	}
+	return r.src.load()
+}
+
+// adopt replaces zones with r.Zones if it was not done by a concurrent
+// lookup already.
+func (src *ZoneSource) adopt(r *Resolver) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	zones := r.Zones
+	if sameMap(zones, r.seen.Load().(map[string]Zone)) {
+		return
+	}
+	r.seen.Store(zones)
+	src.replaceLocked(zones)
+}
+
+// sameMap reports whether a and b are the same map, not just equal ones.
+func sameMap(a, b map[string]Zone) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// getZone returns the zone with the specified key in zones used for lookups.
+func (r *Resolver) getZone(name string) (Zone, bool) {
+	if r.src != nil {
+		return r.snapshot().get(name)
+	}
+	zone, ok := r.Zones[name]
+	return zone, ok
+}
+
+// rangeZoneNames calls f for names of all zones used for lookups.
+func (r *Resolver) rangeZoneNames(f func(name string)) {
+	if r.src != nil {
+		r.snapshot().rangeNames(f)
+		return
+	}
+	for name := range r.Zones {
+		f(name)
+	}
+}
+
+// AddZone adds the zone, replacing the existing one with the same name.
+func (src *ZoneSource) AddZone(name string, zone Zone) {
+	src.modify([]string{normalizeName(name)}, func(string, Zone, bool) *Zone {
+		return &zone
+	})
+}
+
+// RemoveZone removes the zone.
+func (src *ZoneSource) RemoveZone(name string) {
+	name = normalizeName(name)
+	src.modify([]string{name, strings.TrimSuffix(name, ".")}, func(string, Zone, bool) *Zone {
+		return nil
+	})
+}
+
+// SetZones replaces all zones. The map is copied.
+func (src *ZoneSource) SetZones(zones map[string]Zone) {
+	cpy := make(map[string]Zone, len(zones))
+	for name, zone := range zones {
+		cpy[name] = zone
+	}
+	src.replace(cpy)
+}
+
+// AddZone adds the zone to the Server, replacing the existing one with the
+// same name. It is safe to call concurrently with queries being served.
+func (s *Server) AddZone(name string, zone Zone) {
+	s.r.src.AddZone(name, zone)
+}
+
+// RemoveZone removes the zone from the Server. It is safe to call
+// concurrently with queries being served.
+func (s *Server) RemoveZone(name string) {
+	s.r.src.RemoveZone(name)
+}
+
+// SetZones replaces all zones of the Server. The map is copied, so later
+// changes to Resolver().Zones are not seen, unless a new map is assigned to
+// it. It is safe to call concurrently with queries being served.
+func (s *Server) SetZones(zones map[string]Zone) {
+	s.r.src.SetZones(zones)
+}
+
+// ZoneSource returns the ZoneSource used by the Server. Changes made using
+// it are visible immediately, the same as with Server methods.
+func (s *Server) ZoneSource() *ZoneSource {
+	return s.r.src
+}
+
+// addMisc appends records to Misc of the zone with the specified name in
+// zones, creating the zone if needed. The zone is copied so maps shared with
+// other zones are not modified.
+func addMisc(zones map[string]Zone, name string, rrs ...dns.RR) {
+	name = normalizeName(name)
+	zone := zones[name]
+
+	misc := make(map[dns.Type][]dns.RR, len(zone.Misc)+1)
+	for t, rrs := range zone.Misc {
+		misc[t] = rrs
+	}
+	for _, rr := range rrs {
+		t := dns.Type(rr.Header().Rrtype)
+		misc[t] = append(misc[t][:len(misc[t]):len(misc[t])], rr)
+	}
+	zone.Misc = misc
+
+	zones[name] = zone
+}
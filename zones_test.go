@@ -0,0 +1,191 @@
+package mockdns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServer_AddRemoveZone(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	srv.AddZone("Example.NET", Zone{A: []string{"192.0.2.2"}})
+	addrs, err := netR.LookupHost(context.Background(), "example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "192.0.2.2" {
+		t.Fatalf("Wrong result: %v", addrs)
+	}
+
+	srv.RemoveZone("example.org")
+	if _, err := netR.LookupHost(context.Background(), "example.org"); err == nil {
+		t.Fatal("Removed zone is still served")
+	}
+
+	srv.SetZones(map[string]Zone{
+		"example.com.": {A: []string{"192.0.2.3"}},
+	})
+	if _, err := netR.LookupHost(context.Background(), "example.net"); err == nil {
+		t.Fatal("Zone is still served after SetZones")
+	}
+	if _, err := netR.LookupHost(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_AddZone_Concurrent(t *testing.T) {
+	srv, err := NewServer(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	// Views make ServeDNS copy the Server Resolver.
+	if err := srv.AddView("other", map[string]Zone{}, "198.51.100.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	var netR net.Resolver
+	srv.PatchNet(&netR)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := netR.LookupHost(context.Background(), "example.org"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		srv.AddZone("example.org.", Zone{A: []string{"192.0.2.1"}})
+		srv.AddZone("example.net.", Zone{A: []string{"192.0.2.2"}})
+	}
+	wg.Wait()
+}
+
+func TestZoneSource_Shared(t *testing.T) {
+	src := NewZoneSource(map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	})
+	c, err := NewClusterWithSource(2, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	lookup := func(srv *Server, name string) *dns.Msg {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		reply, err := dns.Exchange(m, srv.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	c.Servers[0].AddZone("new.example.org.", Zone{A: []string{"192.0.2.2"}})
+	for i, srv := range c.Servers {
+		if reply := lookup(srv, "new.example.org."); len(reply.Answer) != 1 {
+			t.Fatalf("Zone added via server 0 is not visible on server %d: %v", i, reply)
+		}
+	}
+
+	src.RemoveZone("example.org.")
+	for i, srv := range c.Servers {
+		if reply := lookup(srv, "example.org."); reply.Rcode != dns.RcodeNameError {
+			t.Fatalf("Removed zone is visible on server %d: %v", i, reply)
+		}
+	}
+
+	if c.Servers[1].ZoneSource() != src {
+		t.Fatal("Wrong ZoneSource")
+	}
+}
+
+func TestServer_ResolverZones(t *testing.T) {
+	srv := newTestServer(t, map[string]Zone{
+		"example.org.": {A: []string{"192.0.2.1"}},
+	}, func(*Server) {})
+	defer srv.Close()
+
+	lookup := func(name string) []string {
+		t.Helper()
+		addrs, err := srv.Resolver().LookupHost(context.Background(), name)
+		if err != nil {
+			return nil
+		}
+		return addrs
+	}
+
+	srv.AddZone("example.net.", Zone{A: []string{"192.0.2.2"}})
+
+	// In place changes are seen after AddZone.
+	srv.Resolver().Zones["example.com."] = Zone{A: []string{"192.0.2.3"}}
+	if addrs := lookup("example.com"); len(addrs) != 1 || addrs[0] != "192.0.2.3" {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"192.0.2.3"}, addrs)
+	}
+	if addrs := lookup("example.net"); len(addrs) != 1 || addrs[0] != "192.0.2.2" {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"192.0.2.2"}, addrs)
+	}
+
+	// Cached answers see in place changes too.
+	answer := func(name string) string {
+		t.Helper()
+		set, err := srv.answer("", &srv.r, name, dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(set.answer) != 1 {
+			return ""
+		}
+		return set.answer[0].(*dns.A).A.String()
+	}
+	if got := answer("example.com."); got != "192.0.2.3" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.3", got)
+	}
+	srv.Resolver().Zones["example.com."] = Zone{A: []string{"192.0.2.5"}}
+	if got := answer("example.com."); got != "192.0.2.5" {
+		t.Fatalf("Wrong result, want %v, got %v", "192.0.2.5", got)
+	}
+
+	// A new map replaces all zones.
+	srv.Resolver().Zones = map[string]Zone{
+		"example.edu.": {A: []string{"192.0.2.4"}},
+	}
+	if addrs := lookup("example.edu"); len(addrs) != 1 || addrs[0] != "192.0.2.4" {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"192.0.2.4"}, addrs)
+	}
+	for _, name := range []string{"example.org", "example.net", "example.com"} {
+		if addrs := lookup(name); addrs != nil {
+			t.Fatalf("Wrong result for %s, want %v, got %v", name, nil, addrs)
+		}
+	}
+	if zones := srv.ZoneSource().Zones(); len(zones) != 1 {
+		t.Fatalf("Wrong result, want %v, got %v", 1, len(zones))
+	}
+
+	srv.AddZone("example.net.", Zone{A: []string{"192.0.2.2"}})
+	if addrs := lookup("example.edu"); len(addrs) != 1 {
+		t.Fatalf("Wrong result, want %v, got %v", []string{"192.0.2.4"}, addrs)
+	}
+}